@@ -33,9 +33,19 @@ var (
 	}
 
 	rootOpts struct {
-		kubeconfig  string
-		regionName  string
-		allowedASGs string
+		kubeconfig                      string
+		regionName                      string
+		allowedASGs                     string
+		approveServingCerts             bool
+		requireInstanceIdentityDocument bool
+		awsAccountID                    string
+		instanceIdentityCertFile        string
+		verifySignedCertificates        bool
+		verifyCAFile                    string
+		signerName                      string
+		enabledPolicies                 []string
+		policyMode                      string
+		maxRetries                      int
 	}
 )
 
@@ -43,6 +53,16 @@ func init() {
 	cmdRoot.Flags().StringVar(&rootOpts.kubeconfig, "kubeconfig", "", "kubeconfig file with acces to cluster. (testing only)")
 	cmdRoot.Flags().StringVar(&rootOpts.regionName, "region-name", "", "When empty uses metadata service to extract.")
 	cmdRoot.Flags().StringVar(&rootOpts.allowedASGs, "allowed-asgs", "", "A comma separated string of allowed ASGs")
+	cmdRoot.Flags().BoolVar(&rootOpts.approveServingCerts, "approve-serving-certs", false, "Also auto-approve kubelet serving (server auth) CSRs whose DNS/IP SANs match the requesting EC2 instance")
+	cmdRoot.Flags().BoolVar(&rootOpts.requireInstanceIdentityDocument, "require-instance-identity-document", false, "Require new-node CSRs to carry a verified EC2 instance identity document extension (see aws.BuildInstanceIdentityExtension), rather than trusting the bootstrap token's instance-id claim alone")
+	cmdRoot.Flags().StringVar(&rootOpts.awsAccountID, "aws-account-id", "", "Expected AWS account ID of a verified instance identity document. Required with --require-instance-identity-document")
+	cmdRoot.Flags().StringVar(&rootOpts.instanceIdentityCertFile, "instance-identity-cert-file", "", "Path to a PEM-encoded AWS public certificate to verify instance identity documents against. Required with --require-instance-identity-document")
+	cmdRoot.Flags().BoolVar(&rootOpts.verifySignedCertificates, "verify-signed-certificates", false, "Re-verify a CSR's issued certificate against --verify-ca-file once the signer populates it, denying the CSR if verification fails")
+	cmdRoot.Flags().StringVar(&rootOpts.verifyCAFile, "verify-ca-file", "", "Path to a PEM CA bundle signed certificates must chain to. Required with --verify-signed-certificates")
+	cmdRoot.Flags().StringVar(&rootOpts.signerName, "signer-name", "", "When set, restrict the CSR informer to CSRs whose spec.signerName equals this value, via a field selector. Leave unset to watch every CSR regardless of signerName (pre-existing behavior).")
+	cmdRoot.Flags().StringArrayVar(&rootOpts.enabledPolicies, "enable-policy", []string{}, "Additionally require a CSR to pass a named ApprovalPolicy check before it's approved: asg-membership, kubelet-serving. May be repeated. Leave unset to approve based on the recognizer chain alone (pre-existing behavior).")
+	cmdRoot.Flags().StringVar(&rootOpts.policyMode, "policy-mode", "AND", "How multiple --enable-policy checks are combined: AND (all must allow) or OR (any may allow).")
+	cmdRoot.Flags().IntVar(&rootOpts.maxRetries, "max-retries", 0, "Give up and mark a CSR Denied with reason ExceededRetries once it has failed processing and been retried this many times. 0 (default) retries indefinitely, the pre-existing behavior.")
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -65,8 +85,18 @@ func run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error empty allowed asg list")
 	}
 	arc := awsapprover.Config{
-		RegionName:  rootOpts.regionName,
-		AllowedASGs: aasgs,
+		RegionName:                      rootOpts.regionName,
+		AllowedASGs:                     aasgs,
+		ApproveServingCerts:             rootOpts.approveServingCerts,
+		RequireInstanceIdentityDocument: rootOpts.requireInstanceIdentityDocument,
+		AWSAccountID:                    rootOpts.awsAccountID,
+		InstanceIdentityCertFile:        rootOpts.instanceIdentityCertFile,
+		VerifySignedCertificates:        rootOpts.verifySignedCertificates,
+		VerifyCAFile:                    rootOpts.verifyCAFile,
+		SignerName:                      rootOpts.signerName,
+		EnabledPolicies:                 rootOpts.enabledPolicies,
+		PolicyMode:                      awsapprover.PolicyMode(rootOpts.policyMode),
+		MaxRetries:                      rootOpts.maxRetries,
 	}
 
 	client := kubernetes.NewForConfigOrDie(config)
@@ -116,6 +146,22 @@ func validateRootOpts(cmd *cobra.Command, args []string) error {
 	if rootOpts.allowedASGs == "" {
 		return errors.New("missing required flag: --allowed-asgs")
 	}
+	if rootOpts.requireInstanceIdentityDocument {
+		if rootOpts.awsAccountID == "" {
+			return errors.New("missing required flag: --aws-account-id (required with --require-instance-identity-document)")
+		}
+		if rootOpts.instanceIdentityCertFile == "" {
+			return errors.New("missing required flag: --instance-identity-cert-file (required with --require-instance-identity-document)")
+		}
+	}
+	if rootOpts.verifySignedCertificates && rootOpts.verifyCAFile == "" {
+		return errors.New("missing required flag: --verify-ca-file (required with --verify-signed-certificates)")
+	}
+	switch awsapprover.PolicyMode(rootOpts.policyMode) {
+	case awsapprover.PolicyAND, awsapprover.PolicyOR:
+	default:
+		return fmt.Errorf("invalid --policy-mode %q: must be AND or OR", rootOpts.policyMode)
+	}
 	return nil
 }
 
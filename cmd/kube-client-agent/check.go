@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/kubecsr/pkg/certcheck"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkCmd = &cobra.Command{
+		Use:     "check --FLAGS",
+		Short:   "report certificate expiry",
+		Long:    "This command walks the agent's assets directory and reports each certificate's subject, issuer, validity window, and days remaining, exiting non-zero if any of them are within the warning window",
+		PreRunE: validateCheckOpts,
+		RunE:    runCmdCheck,
+	}
+
+	checkOpts struct {
+		assetsDir string
+		warn      string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.PersistentFlags().StringVar(&checkOpts.assetsDir, "assetsdir", "", "Directory location for the agent where it stores signed certs")
+	checkCmd.PersistentFlags().StringVar(&checkOpts.warn, "warn", "720h", "Warn, and exit non-zero, if any certificate has less than this long remaining before it expires")
+}
+
+func validateCheckOpts(cmd *cobra.Command, args []string) error {
+	if checkOpts.assetsDir == "" {
+		return errors.New("missing required flag: --assetsdir")
+	}
+	return nil
+}
+
+// runCmdCheck reports the expiry of every certificate in the assets
+// directory and fails if any of them are within the warning window
+func runCmdCheck(cmd *cobra.Command, args []string) error {
+	warn, err := time.ParseDuration(checkOpts.warn)
+	if err != nil {
+		return fmt.Errorf("error parsing duration for --warn: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(checkOpts.assetsDir)
+	if err != nil {
+		return fmt.Errorf("error reading assets directory %q: %v", checkOpts.assetsDir, err)
+	}
+
+	var infos []*certcheck.CertInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".crt") {
+			continue
+		}
+		info, err := certcheck.Inspect(filepath.Join(checkOpts.assetsDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("error inspecting certificate: %v", err)
+		}
+		infos = append(infos, info)
+	}
+
+	if err := certcheck.WriteReport(os.Stdout, infos); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+
+	expiring := certcheck.Expiring(infos, warn)
+	for _, ci := range expiring {
+		fmt.Fprintf(os.Stderr, "warning: %s (subject=%q) expires in %d days, within the %s warning window\n", ci.Path, ci.Subject, ci.DaysRemaining(), checkOpts.warn)
+	}
+	if len(expiring) > 0 {
+		return fmt.Errorf("%d certificate(s) are within the warning window", len(expiring))
+	}
+
+	return nil
+}
@@ -14,6 +14,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+
+	signer "github.com/coreos/kubecsr/pkg/certsigner"
 )
 
 var (
@@ -26,11 +28,18 @@ var (
 	}
 
 	mountSecretOpts struct {
-		commonName string
-		assetsDir  string
+		commonName     string
+		assetsDir      string
+		profilesConfig string
+		watch          bool
 	}
 )
 
+func init() {
+	mountSecretCmd.PersistentFlags().StringVar(&mountSecretOpts.profilesConfig, "profiles-config", "", "Path to a JSON file of additional ProfileRules used to compute the Secret name. If unset, the built-in peer/server/metric matching is used.")
+	mountSecretCmd.PersistentFlags().BoolVar(&mountSecretOpts.watch, "watch", false, "Keep running and rewrite the cert/key files to --assetsdir whenever the backing Secret is updated, instead of exiting after the first successful mount.")
+}
+
 func validateMountSecretOpts(cmd *cobra.Command, args []string) error {
 	if mountSecretOpts.commonName == "" {
 		return fmt.Errorf("missing required flag: --commonname")
@@ -46,30 +55,56 @@ func runCmdMountSecret(cmd *cobra.Command, args []string) error {
 	return mountSecret()
 }
 
-// mount will secret will look for secret in the form of
-// <profile>-<podFQDN>, where profile can be peer, server
-// and metric and mount the certs as commonname.crt/commonname.key
-// this will run as init container in etcd pod managed by CEO.
-func mountSecret() error {
-	var err error
+// newInClusterClient returns a Kubernetes clientset configured from the
+// in-cluster service account, as used by both the one-shot and --watch
+// mount-secret paths.
+func newInClusterClient() (kubernetes.Interface, error) {
 	inClusterConfig, err := rest.InClusterConfig()
 	if err != nil {
-		return fmt.Errorf("error creating in cluster client config: %v", err)
+		return nil, fmt.Errorf("error creating in cluster client config: %v", err)
 	}
 
 	client, err := kubernetes.NewForConfig(inClusterConfig)
 	if err != nil {
-		return fmt.Errorf("error creating client: %v", err)
+		return nil, fmt.Errorf("error creating client: %v", err)
+	}
+	return client, nil
+}
+
+const mountSecretNamespace = "openshift-etcd"
+
+// mountSecret will look for secret in the form of <profile>-<podFQDN>,
+// where profile can be peer, server and metric and mount the certs as
+// commonname.crt/commonname.key. With --watch unset this runs once, as an
+// init container in the etcd pod managed by CEO; with --watch set it keeps
+// running and hot-reloads the files whenever the Secret is updated.
+func mountSecret() error {
+	client, err := newInClusterClient()
+	if err != nil {
+		return err
+	}
+
+	var rules []signer.ProfileRule
+	if mountSecretOpts.profilesConfig != "" {
+		rules, err = signer.LoadProfileRules(mountSecretOpts.profilesConfig)
+		if err != nil {
+			return fmt.Errorf("error loading profile rules: %v", err)
+		}
+	}
+
+	secretName := getSecretName(mountSecretOpts.commonName, rules)
+
+	if mountSecretOpts.watch {
+		return watchSecret(client, mountSecretNamespace, secretName, mountSecretOpts.assetsDir, mountSecretOpts.commonName)
 	}
 
 	duration := 10 * time.Second
 	var s *v1.Secret
 	// wait forever for success and retry every duration interval
 	err = wait.PollInfinite(duration, func() (bool, error) {
-		fmt.Println(requestOpts.commonName)
-		s, err = client.CoreV1().Secrets("openshift-etcd").Get(getSecretName(mountSecretOpts.commonName), metav1.GetOptions{})
+		s, err = client.CoreV1().Secrets(mountSecretNamespace).Get(secretName, metav1.GetOptions{})
 		if err != nil {
-			glog.Errorf("error in getting secret %s/%s: %v", "openshift-etcd", getSecretName(mountSecretOpts.commonName), err)
+			glog.Errorf("error in getting secret %s/%s: %v", mountSecretNamespace, secretName, err)
 			return false, err
 		}
 		err = ensureCertKeys(s.Data)
@@ -97,7 +132,11 @@ func mountSecret() error {
 	return nil
 }
 
-func getSecretName(commonName string) string {
+func getSecretName(commonName string, rules []signer.ProfileRule) string {
+	if name, ok := signer.SecretNameForCN(commonName, rules); ok {
+		return name
+	}
+
 	prefix := ""
 	if strings.Contains(commonName, "peer") {
 		prefix = "peer"
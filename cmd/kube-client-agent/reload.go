@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// buildReloadFunc returns a func() error that runs reloadCmd (if set) and/or
+// sends SIGHUP to the pid recorded in pidFile (if set) every time the
+// renewal loop refreshes a certificate. It returns nil if neither flag is
+// set, since agent.ReloadFunc and renewer.ReloadFunc both treat a nil
+// ReloadFunc as "nothing to do"; the unnamed return type lets the same
+// built func satisfy either one.
+func buildReloadFunc(reloadCmd, pidFile string) func() error {
+	if reloadCmd == "" && pidFile == "" {
+		return nil
+	}
+	return func() error {
+		if reloadCmd != "" {
+			if out, err := exec.Command("/bin/sh", "-c", reloadCmd).CombinedOutput(); err != nil {
+				return fmt.Errorf("error running --reload-cmd %q: %v: %s", reloadCmd, err, out)
+			}
+		}
+		if pidFile != "" {
+			if err := signalPidFile(pidFile); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// signalPidFile sends SIGHUP to the process whose pid is recorded in
+// pidFile.
+func signalPidFile(pidFile string) error {
+	pidBytes, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return fmt.Errorf("error reading pid file %s: %v", pidFile, err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+	if err != nil {
+		return fmt.Errorf("error parsing pid from %s: %v", pidFile, err)
+	}
+	if err := syscall.Kill(pid, syscall.SIGHUP); err != nil {
+		return fmt.Errorf("error sending SIGHUP to pid %d from %s: %v", pid, pidFile, err)
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	agent "github.com/coreos/kubecsr/pkg/certagent"
+	"github.com/coreos/kubecsr/pkg/renewer"
 	"github.com/spf13/cobra"
 )
 
@@ -27,6 +30,12 @@ var (
 		assetsDir   string
 		kubeconfig  string
 		maxRetry    int
+		signerName  string
+		renewBefore string
+		rotate      bool
+		reloadCmd   string
+		pidFile     string
+		serverURL   string
 	}
 )
 
@@ -39,6 +48,12 @@ func init() {
 	requestCmd.PersistentFlags().StringVar(&requestOpts.assetsDir, "assetsdir", "", "Directory location for the agent where it stores signed certs")
 	requestCmd.PersistentFlags().StringVar(&requestOpts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to connect to apiserver. If \"\", InClusterConfig is used which uses the service account kubernetes gives to pods.")
 	requestCmd.PersistentFlags().IntVar(&requestOpts.maxRetry, "max-retry", 0, "If value is greater than 0 wait 10 seconds for success and retry N times.")
+	requestCmd.PersistentFlags().StringVar(&requestOpts.signerName, "signer-name", "", "Spec.SignerName to stamp on the CSR. Required by signers running on certificates.k8s.io/v1 clusters; leave unset for v1beta1-only signers.")
+	requestCmd.PersistentFlags().StringVar(&requestOpts.renewBefore, "renew-before", "0s", "If greater than 0, keep running after the first certificate is issued and request a fresh one this long before the current one's NotAfter, instead of exiting.")
+	requestCmd.PersistentFlags().BoolVar(&requestOpts.rotate, "rotate", false, "Keep running after the first certificate is issued and automatically rotate it at a jittered fraction of its lifetime, kubelet-manager style, instead of exiting. Takes precedence over --renew-before.")
+	requestCmd.PersistentFlags().StringVar(&requestOpts.reloadCmd, "reload-cmd", "", "Command to run, via /bin/sh -c, after each certificate renewal. Only used with --renew-before or --rotate.")
+	requestCmd.PersistentFlags().StringVar(&requestOpts.pidFile, "pid-file", "", "If set, send SIGHUP to the pid recorded in this file after each certificate renewal. Only used with --renew-before or --rotate.")
+	requestCmd.PersistentFlags().StringVar(&requestOpts.serverURL, "server-url", "", "Base URL of a CertServer to request/rotate a certificate against directly over HTTP (see pkg/renewer), bypassing the Kubernetes API entirely. Useful for bootstrapping (e.g. etcd) before a cluster's apiserver exists. Mutually exclusive with --kubeconfig; requires --rotate.")
 }
 
 func validateRequestOpts(cmd *cobra.Command, args []string) error {
@@ -54,9 +69,19 @@ func validateRequestOpts(cmd *cobra.Command, args []string) error {
 	if requestOpts.assetsDir == "" {
 		return errors.New("missing required flag: --assetsdir")
 	}
-	if requestOpts.kubeconfig == "" {
+	if requestOpts.serverURL != "" {
+		if requestOpts.kubeconfig != "" {
+			return errors.New("--server-url and --kubeconfig are mutually exclusive")
+		}
+		if !requestOpts.rotate {
+			return errors.New("--server-url requires --rotate")
+		}
+	} else if requestOpts.kubeconfig == "" {
 		return errors.New("missing required flag: --kubeconfig")
 	}
+	if _, err := time.ParseDuration(requestOpts.renewBefore); err != nil {
+		return fmt.Errorf("invalid --renew-before: %v", err)
+	}
 	return nil
 
 }
@@ -84,11 +109,48 @@ func runCmdRequest(cmd *cobra.Command, args []string) error {
 		IPAddresses: ips,
 		AssetsDir:   requestOpts.assetsDir,
 		MaxRetry:    requestOpts.maxRetry,
+		SignerName:  requestOpts.signerName,
+	}
+
+	if requestOpts.serverURL != "" {
+		// validateRequestOpts requires --rotate whenever --server-url is
+		// set, so the CertServer HTTP path always runs the rotation
+		// manager, never a single one-shot request.
+		config.CSRName = requestOpts.commonName
+		r, err := renewer.New(renewer.Config{ServerURL: requestOpts.serverURL, CSR: config})
+		if err != nil {
+			return fmt.Errorf("error creating renewer: %s", err)
+		}
+		reload := buildReloadFunc(requestOpts.reloadCmd, requestOpts.pidFile)
+		if err := r.Start(context.Background(), reload); err != nil {
+			return fmt.Errorf("error running certificate rotation manager: %s", err)
+		}
+		return nil
 	}
+
 	a, err := agent.NewAgent(config, requestOpts.kubeconfig)
 	if err != nil {
 		return fmt.Errorf("error creating agent: %s", err)
 	}
+
+	if requestOpts.rotate {
+		reload := buildReloadFunc(requestOpts.reloadCmd, requestOpts.pidFile)
+		if err := a.Start(context.Background(), reload); err != nil {
+			return fmt.Errorf("error running certificate rotation manager: %s", err)
+		}
+		return nil
+	}
+
+	// renew-before is validated as a duration in validateRequestOpts.
+	renewBefore, _ := time.ParseDuration(requestOpts.renewBefore)
+	if renewBefore > 0 {
+		reload := buildReloadFunc(requestOpts.reloadCmd, requestOpts.pidFile)
+		if err := a.RunRenewalLoop(renewBefore, reload); err != nil {
+			return fmt.Errorf("error running certificate renewal loop: %s", err)
+		}
+		return nil
+	}
+
 	if err := a.RequestCertificate(); err != nil {
 		return fmt.Errorf("error requesting certificate: %s", err)
 	}
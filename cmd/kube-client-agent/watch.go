@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// watchSecret keeps commonName's cert/key files in assetsDir in sync with
+// the named Secret for as long as the process runs. It watches the Secret
+// for updates (e.g. the signer rotating the certificate), rewrites the
+// cert/key files whenever new data arrives, and uses fsnotify to confirm
+// the write has landed on disk before logging the reload, so the etcd
+// container can pick up the refreshed files without a restart.
+func watchSecret(client kubernetes.Interface, namespace, secretName, assetsDir, commonName string) error {
+	certFile := path.Join(assetsDir, commonName+".crt")
+	keyFile := path.Join(assetsDir, commonName+".key")
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating fsnotify watcher: %v", err)
+	}
+	defer fsWatcher.Close()
+	if err := fsWatcher.Add(assetsDir); err != nil {
+		return fmt.Errorf("error watching %s: %v", assetsDir, err)
+	}
+
+	for {
+		w, err := client.CoreV1().Secrets(namespace).Watch(metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", secretName).String(),
+		})
+		if err != nil {
+			return fmt.Errorf("error starting watch on secret %s/%s: %v", namespace, secretName, err)
+		}
+
+		for event := range w.ResultChan() {
+			if event.Type == watch.Deleted {
+				glog.Warningf("secret %s/%s deleted; keeping last known certs on disk", namespace, secretName)
+				continue
+			}
+
+			secret, ok := event.Object.(*v1.Secret)
+			if !ok {
+				continue
+			}
+			if err := ensureCertKeys(secret.Data); err != nil {
+				glog.Errorf("secret %s/%s update did not contain usable cert data: %v", namespace, secretName, err)
+				continue
+			}
+
+			if err := ioutil.WriteFile(certFile, secret.Data["tls.crt"], 0644); err != nil {
+				glog.Errorf("unable to write to %s: %v", certFile, err)
+				continue
+			}
+			if err := ioutil.WriteFile(keyFile, secret.Data["tls.key"], 0644); err != nil {
+				glog.Errorf("unable to write to %s: %v", keyFile, err)
+				continue
+			}
+			waitForReload(fsWatcher, certFile, keyFile)
+		}
+
+		glog.Warningf("watch on secret %s/%s closed; restarting", namespace, secretName)
+	}
+}
+
+// waitForReload blocks until fsnotify confirms both certFile and keyFile
+// have been written, logging each as it is observed.
+func waitForReload(w *fsnotify.Watcher, certFile, keyFile string) {
+	pending := map[string]bool{certFile: true, keyFile: true}
+	for len(pending) > 0 {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&fsnotify.Write == 0 || !pending[ev.Name] {
+				continue
+			}
+			glog.Infof("reloaded %s", ev.Name)
+			delete(pending, ev.Name)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			glog.Errorf("fsnotify error watching cert directory: %v", err)
+			return
+		}
+	}
+}
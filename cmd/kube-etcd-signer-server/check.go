@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coreos/kubecsr/pkg/certcheck"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkCmd = &cobra.Command{
+		Use:     "check --FLAGS",
+		Short:   "report certificate expiry",
+		Long:    "This command inspects the signer's CA and server certificates and reports each one's subject, issuer, validity window, and days remaining, exiting non-zero if any of them are within the warning window",
+		PreRunE: validateCheckOpts,
+		RunE:    runCmdCheck,
+	}
+
+	checkOpts struct {
+		caCrtFile  string
+		mCACrtFile string
+		sCrtFiles  []string
+		warn       string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.PersistentFlags().StringVar(&checkOpts.caCrtFile, "cacrt", "", "CA certificate file for signer")
+	checkCmd.PersistentFlags().StringVar(&checkOpts.mCACrtFile, "metric-cacrt", "", "CA certificate file for metrics signer")
+	checkCmd.PersistentFlags().StringArrayVar(&checkOpts.sCrtFiles, "servcrt", []string{}, "Server certificate file for signer")
+	checkCmd.PersistentFlags().StringVar(&checkOpts.warn, "warn", "720h", "Warn, and exit non-zero, if any certificate has less than this long remaining before it expires")
+}
+
+// validateCheckOpts validates the user flag values given to the check command
+func validateCheckOpts(cmd *cobra.Command, args []string) error {
+	if checkOpts.caCrtFile == "" && checkOpts.mCACrtFile == "" && len(checkOpts.sCrtFiles) == 0 {
+		return errors.New("no certificate flags passed, at least one of --cacrt, --metric-cacrt or --servcrt is required")
+	}
+	return nil
+}
+
+// runCmdCheck reports the expiry of the configured certificates and fails
+// if any of them are within the warning window
+func runCmdCheck(cmd *cobra.Command, args []string) error {
+	warn, err := time.ParseDuration(checkOpts.warn)
+	if err != nil {
+		return fmt.Errorf("error parsing duration for --warn: %v", err)
+	}
+
+	var files []string
+	if checkOpts.caCrtFile != "" {
+		files = append(files, checkOpts.caCrtFile)
+	}
+	if checkOpts.mCACrtFile != "" {
+		files = append(files, checkOpts.mCACrtFile)
+	}
+	files = append(files, checkOpts.sCrtFiles...)
+
+	var infos []*certcheck.CertInfo
+	for _, f := range files {
+		info, err := certcheck.Inspect(f)
+		if err != nil {
+			return fmt.Errorf("error inspecting certificate: %v", err)
+		}
+		infos = append(infos, info)
+	}
+
+	if err := certcheck.WriteReport(os.Stdout, infos); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+
+	expiring := certcheck.Expiring(infos, warn)
+	for _, ci := range expiring {
+		fmt.Fprintf(os.Stderr, "warning: %s (subject=%q) expires in %d days, within the %s warning window\n", ci.Path, ci.Subject, ci.DaysRemaining(), checkOpts.warn)
+	}
+	if len(expiring) > 0 {
+		return fmt.Errorf("%d certificate(s) are within the warning window", len(expiring))
+	}
+
+	return nil
+}
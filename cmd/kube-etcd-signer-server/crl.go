@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	signer "github.com/coreos/kubecsr/pkg/certsigner"
+	"github.com/coreos/kubecsr/pkg/certsigner/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	crlCmd = &cobra.Command{
+		Use:     "crl --FLAGS",
+		Short:   "generate CRLs from a file-backed audit log",
+		Long:    "This command reads a --audit-log written by `serve`, together with an optional admin-maintained revoked-serials file, and writes a root.crl (and, if configured, a metric.crl) to --crl-dir. It is the offline counterpart to the CertDB-backed CRL/OCSP serving `serve` does when --certdb is configured, for operators who use --audit-log instead of a certdb.",
+		PreRunE: validateCrlOpts,
+		RunE:    runCmdCrl,
+	}
+
+	crlOpts struct {
+		auditLogFile string
+		revokedFile  string
+		caCrtFile    string
+		caKeyFile    string
+		mCACrtFile   string
+		mCAKeyFile   string
+		crlDir       string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(crlCmd)
+	crlCmd.PersistentFlags().StringVar(&crlOpts.auditLogFile, "audit-log", "", "Path to the append-only JSON-lines audit log written by `serve --audit-log`")
+	crlCmd.PersistentFlags().StringVar(&crlOpts.revokedFile, "revoked-serials", "", "Optional path to an admin-maintained list of additionally revoked serials, one per line as \"<hex serial>[ <RFC3339 revokedAt>]\"")
+	crlCmd.PersistentFlags().StringVar(&crlOpts.caCrtFile, "cacrt", "", "CA certificate file for signer")
+	crlCmd.PersistentFlags().StringVar(&crlOpts.caKeyFile, "cakey", "", "CA private key file for signer")
+	crlCmd.PersistentFlags().StringVar(&crlOpts.mCACrtFile, "metric-cacrt", "", "CA certificate file for metrics signer")
+	crlCmd.PersistentFlags().StringVar(&crlOpts.mCAKeyFile, "metric-cakey", "", "CA private key file for metrics signer")
+	crlCmd.PersistentFlags().StringVar(&crlOpts.crlDir, "crl-dir", "", "Directory to write root.crl/metric.crl into")
+}
+
+// validateCrlOpts validates the user flag values given to the crl command
+func validateCrlOpts(cmd *cobra.Command, args []string) error {
+	if crlOpts.auditLogFile == "" {
+		return errors.New("missing required flag: --audit-log")
+	}
+	if crlOpts.crlDir == "" {
+		return errors.New("missing required flag: --crl-dir")
+	}
+	if crlOpts.caCrtFile == "" && crlOpts.mCACrtFile == "" {
+		return errors.New("no CA flags passed, at least one of --cacrt/--cakey or --metric-cacrt/--metric-cakey is required")
+	}
+	if (crlOpts.caCrtFile == "") != (crlOpts.caKeyFile == "") {
+		return errors.New("--cacrt and --cakey must be passed together")
+	}
+	if (crlOpts.mCACrtFile == "") != (crlOpts.mCAKeyFile == "") {
+		return errors.New("--metric-cacrt and --metric-cakey must be passed together")
+	}
+	return nil
+}
+
+// runCmdCrl generates a CRL per configured CA from the file-backed audit
+// log and admin-maintained revoked-serials file.
+func runCmdCrl(cmd *cobra.Command, args []string) error {
+	auditStore, err := store.NewFileStore(crlOpts.auditLogFile)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %v", err)
+	}
+	defer auditStore.Close()
+
+	records, err := auditStore.List()
+	if err != nil {
+		return fmt.Errorf("error listing audit log records: %v", err)
+	}
+
+	adminRevoked, err := signer.LoadRevokedSerialsFile(crlOpts.revokedFile)
+	if err != nil {
+		return fmt.Errorf("error loading revoked-serials file: %v", err)
+	}
+
+	cas, err := signer.LoadRevokeCAs(&signer.SignerCAFiles{
+		CACert:       crlOpts.caCrtFile,
+		CAKey:        crlOpts.caKeyFile,
+		MetricCACert: crlOpts.mCACrtFile,
+		MetricCAKey:  crlOpts.mCAKeyFile,
+	})
+	if err != nil {
+		return fmt.Errorf("error loading CAs: %v", err)
+	}
+
+	for _, ca := range cas {
+		der, err := signer.GenerateCRL(ca, records, adminRevoked)
+		if err != nil {
+			return fmt.Errorf("error generating CRL for %s CA: %v", ca.Name, err)
+		}
+		out := filepath.Join(crlOpts.crlDir, ca.Name+".crl")
+		if err := ioutil.WriteFile(out, der, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %v", out, err)
+		}
+		crl, err := x509.ParseCRL(der)
+		if err != nil {
+			return fmt.Errorf("error parsing generated CRL for %s CA: %v", ca.Name, err)
+		}
+		fmt.Printf("wrote %s (%d revoked)\n", out, len(crl.TBSCertList.RevokedCertificates))
+	}
+
+	return nil
+}
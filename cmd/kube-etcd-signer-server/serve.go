@@ -6,7 +6,23 @@ import (
 	"time"
 
 	signer "github.com/coreos/kubecsr/pkg/certsigner"
+	"github.com/coreos/kubecsr/pkg/certsigner/store"
+	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// cloudProviderNone leaves CloudVerifier unset, preserving the signer's
+// behavior from before --cloud-provider existed.
+const cloudProviderNone = "none"
+
+// Component names accepted by the repeatable --disable flag, following
+// the pattern k3s uses for disabling individual control-plane components.
+const (
+	componentMetricsSigner = "metrics-signer"
+	componentPeerSigner    = "peer-signer"
+	componentServerSigner  = "server-signer"
+	componentHealthCheck   = "health-check"
 )
 
 var (
@@ -19,20 +35,28 @@ var (
 	}
 
 	serveOpts struct {
-		caCrtFile     string
-		caKeyFile     string
-		mCACrtFile    string
-		mCAKeyFile    string
-		mCASigner     bool
-		sCrtFiles     []string
-		sKeyFiles     []string
-		addr          string
-		peerCertDur   string
-		serverCertDur string
-		metricCertDur string
-		csrDir        string
+		caCrtFile      string
+		caKeyFile      string
+		mCACrtFile     string
+		mCAKeyFile     string
+		mCASigner      bool
+		sCrtFiles      []string
+		sKeyFiles      []string
+		addr           string
+		peerCertDur    string
+		serverCertDur  string
+		metricCertDur  string
+		csrDir         string
+		auditLogFile   string
+		enableCSRStore bool
+		csrStoreTTL    time.Duration
 
 		insecureHealthCheckAddr string
+		disable                 []string
+
+		cloudProvider       string
+		cloudProviderConfig string
+		cloudInstanceGroup  string
 	}
 )
 
@@ -50,19 +74,40 @@ func init() {
 	serveCmd.PersistentFlags().StringVar(&serveOpts.peerCertDur, "peercertdur", "8760h", "Certificate duration for etcd peer certs (defaults to 365 days)")
 	serveCmd.PersistentFlags().StringVar(&serveOpts.serverCertDur, "servercertdur", "8760h", "Certificate duration for etcd server certs (defaults to 365 days)")
 	serveCmd.PersistentFlags().StringVar(&serveOpts.csrDir, "csrdir", "", "Directory location where signer will save CSRs.")
+	serveCmd.PersistentFlags().StringVar(&serveOpts.auditLogFile, "audit-log", "", "Path to an append-only JSON-lines log of issued/revoked certificates. A durable audit trail without standing up Mongo; revocation/CRL generation against it is done offline via the `crl` subcommand. Leave unset to disable.")
+	serveCmd.PersistentFlags().StringArrayVar(&serveOpts.disable, "disable", []string{}, "Disable an optional signer component so its CA material isn't required: metrics-signer, peer-signer, server-signer, health-check. May be repeated.")
+	serveCmd.PersistentFlags().StringVar(&serveOpts.cloudProvider, "cloud-provider", cloudProviderNone, "Cloud provider to verify a CSR's node name against before signing (aws, azure, gce, baremetal), or \"none\" to sign any node name as today.")
+	serveCmd.PersistentFlags().StringVar(&serveOpts.cloudProviderConfig, "cloud-provider-config", "", "Path to the --cloud-provider's configuration file, if it requires one.")
+	serveCmd.PersistentFlags().StringVar(&serveOpts.cloudInstanceGroup, "cloud-instance-group", "", "Instance group/ASG/VMSS every signed CSR's node name must belong to. Required unless --cloud-provider=none.")
+	serveCmd.PersistentFlags().BoolVar(&serveOpts.enableCSRStore, "enable-csr-store", false, "Additionally record every signed CSR in an in-memory store, and enable the certificates.k8s.io/v1 watch=true endpoint so agents can receive their signed certificate without polling. --csrdir keeps working as before either way.")
+	serveCmd.PersistentFlags().DurationVar(&serveOpts.csrStoreTTL, "csr-store-ttl", 5*time.Minute, "How long a signed CSR is kept in the in-memory store. Only takes effect with --enable-csr-store.")
 }
 
 // validateServeOpts validates the user flag values given to the signer server
 func validateServeOpts(cmd *cobra.Command, args []string) error {
-	caPair := 0
-	if serveOpts.caCrtFile != "" && serveOpts.caKeyFile != "" {
-		caPair++
+	disabled := sets.NewString(serveOpts.disable...)
+	for _, c := range disabled.List() {
+		switch c {
+		case componentMetricsSigner, componentPeerSigner, componentServerSigner, componentHealthCheck:
+		default:
+			return fmt.Errorf("unknown --disable value %q", c)
+		}
+	}
+
+	// rootEnabled tracks the peer and server signers together: both are
+	// backed by the same --cacrt/--cakey pair, so that pair is only
+	// optional once neither signer needs it.
+	rootEnabled := !disabled.Has(componentPeerSigner) || !disabled.Has(componentServerSigner)
+	metricsEnabled := !disabled.Has(componentMetricsSigner)
+
+	if !rootEnabled && !metricsEnabled {
+		return errors.New("--disable leaves no signer enabled: at least one of the peer, server, or metrics signers must stay enabled")
 	}
-	if serveOpts.mCACrtFile != "" && serveOpts.mCAKeyFile != "" {
-		caPair++
+	if rootEnabled && (serveOpts.caCrtFile == "" || serveOpts.caKeyFile == "") {
+		return errors.New("missing required flags: --cacrt and --cakey (or pass --disable=peer-signer and --disable=server-signer)")
 	}
-	if caPair == 0 {
-		return errors.New("no signer CA flags passed one cert/key pair is required")
+	if metricsEnabled && (serveOpts.mCACrtFile == "" || serveOpts.mCAKeyFile == "") {
+		return errors.New("missing required flags: --metric-cacrt and --metric-cakey (or pass --disable=metrics-signer)")
 	}
 
 	if cl, kl := len(serveOpts.sCrtFiles), len(serveOpts.sKeyFiles); cl == 0 || kl == 0 {
@@ -73,6 +118,9 @@ func validateServeOpts(cmd *cobra.Command, args []string) error {
 	if serveOpts.csrDir == "" {
 		return errors.New("missing required flag: --csrdir")
 	}
+	if serveOpts.cloudProvider != cloudProviderNone && serveOpts.cloudInstanceGroup == "" {
+		return errors.New("missing required flag: --cloud-instance-group (or pass --cloud-provider=none)")
+	}
 	return nil
 }
 
@@ -92,16 +140,43 @@ func runCmdServe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error parsing duration for etcd metric cert: %v", err)
 	}
 
+	disabled := sets.NewString(serveOpts.disable...)
 	ca := signer.SignerCAFiles{
-		CACert:       serveOpts.caCrtFile,
-		CAKey:        serveOpts.caKeyFile,
-		MetricCACert: serveOpts.mCACrtFile,
-		MetricCAKey:  serveOpts.mCAKeyFile,
+		CACert:               serveOpts.caCrtFile,
+		CAKey:                serveOpts.caKeyFile,
+		MetricCACert:         serveOpts.mCACrtFile,
+		MetricCAKey:          serveOpts.mCAKeyFile,
+		DisablePeerSigner:    disabled.Has(componentPeerSigner),
+		DisableServerSigner:  disabled.Has(componentServerSigner),
+		DisableMetricsSigner: disabled.Has(componentMetricsSigner),
 	}
 	servercerts := make([]signer.CertKey, len(serveOpts.sCrtFiles))
 	for idx := range serveOpts.sCrtFiles {
 		servercerts[idx] = signer.CertKey{CertFile: serveOpts.sCrtFiles[idx], KeyFile: serveOpts.sKeyFiles[idx]}
 	}
+	var cloudVerifier signer.CloudVerifier
+	if serveOpts.cloudProvider != cloudProviderNone {
+		cloud, err := cloudprovider.InitCloudProvider(serveOpts.cloudProvider, serveOpts.cloudProviderConfig)
+		if err != nil {
+			return fmt.Errorf("error starting cloud provider %s: %v", serveOpts.cloudProvider, err)
+		}
+		cloudVerifier = signer.NewCloudInstanceGroupVerifier(cloud, serveOpts.cloudInstanceGroup)
+	}
+
+	var auditStore store.Store
+	if serveOpts.auditLogFile != "" {
+		fileStore, err := store.NewFileStore(serveOpts.auditLogFile)
+		if err != nil {
+			return fmt.Errorf("error opening audit log: %v", err)
+		}
+		auditStore = fileStore
+	}
+
+	var csrStore signer.CSRStore
+	if serveOpts.enableCSRStore {
+		csrStore = signer.NewMemoryCSRStore(serveOpts.csrStoreTTL)
+	}
+
 	c := signer.Config{
 		SignerCAFiles:          ca,
 		ServerCertKeys:         servercerts,
@@ -110,8 +185,12 @@ func runCmdServe(cmd *cobra.Command, args []string) error {
 		EtcdPeerCertDuration:   pCertDur,
 		EtcdServerCertDuration: sCertDur,
 		CSRDir:                 serveOpts.csrDir,
+		Store:                  auditStore,
+		CSRStore:               csrStore,
 
 		InsecureHealthCheckAddress: serveOpts.insecureHealthCheckAddr,
+		DisableHealthCheck:         disabled.Has(componentHealthCheck),
+		CloudVerifier:              cloudVerifier,
 	}
 
 	if err := signer.StartSignerServer(c); err != nil {
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	nodesigner "github.com/coreos/kubecsr/pkg/signer"
+)
+
+var (
+	rootCmd = &cobra.Command{
+		Use:               "kube-node-signer",
+		Short:             "Runs an approver's companion signer, issuing certificates for node CSRs it has already approved",
+		Long:              "",
+		PersistentPreRunE: validateRootOpts,
+		RunE:              runCmdRoot,
+	}
+
+	rootOpts struct {
+		kubeconfig    string
+		caCrtFile     string
+		caKeyFile     string
+		certDur       string
+		backend       string
+		backendConfig string
+	}
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&rootOpts.kubeconfig, "kubeconfig", "", "kubeconfig file with access to cluster. (testing only)")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.caCrtFile, "cacrt", "", "CA certificate file for the local-ca backend")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.caKeyFile, "cakey", "", "CA private key file for the local-ca backend")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.certDur, "certdur", "8760h", "Certificate duration for the local-ca backend (defaults to 365 days)")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.backend, "backend", nodesigner.LocalCABackendName, "The signing backend to use: local-ca, vault, or grpc")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.backendConfig, "backend-config", "", "Config file for the chosen backend. Required for vault and grpc; built from --cacrt/--cakey/--certdur for local-ca if omitted.")
+}
+
+// validateRootOpts validates the user flag values given to kube-node-signer.
+func validateRootOpts(cmd *cobra.Command, args []string) error {
+	if rootOpts.backend == nodesigner.LocalCABackendName && rootOpts.backendConfig == "" {
+		if rootOpts.caCrtFile == "" || rootOpts.caKeyFile == "" {
+			return errors.New("either --backend-config, or both --cacrt and --cakey, are required for the local-ca backend")
+		}
+	}
+	return nil
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		glog.Exitf("Error executing kube-node-signer: %v", err)
+	}
+}
+
+// runCmdRoot wires up the configured signer backend and runs the signer
+// controller until stopped.
+func runCmdRoot(cmd *cobra.Command, args []string) error {
+	backend, err := newSigner()
+	if err != nil {
+		return err
+	}
+
+	config, err := clientConfig()
+	if err != nil {
+		return err
+	}
+	client := kubernetes.NewForConfigOrDie(config)
+
+	c, err := nodesigner.New(client, backend)
+	if err != nil {
+		return fmt.Errorf("error creating signer controller: %v", err)
+	}
+
+	return c.Run(2, wait.NeverStop)
+}
+
+// newSigner initializes the configured backend. local-ca builds its config
+// from --cacrt/--cakey/--certdur when --backend-config isn't given; the
+// other backends always require --backend-config.
+func newSigner() (nodesigner.Signer, error) {
+	if rootOpts.backend == nodesigner.LocalCABackendName && rootOpts.backendConfig == "" {
+		return nodesigner.NewLocalCASigner(nodesigner.LocalCAConfig{
+			CACertFile:   rootOpts.caCrtFile,
+			CAKeyFile:    rootOpts.caKeyFile,
+			CertDuration: rootOpts.certDur,
+		})
+	}
+
+	return nodesigner.InitSigner(rootOpts.backend, rootOpts.backendConfig)
+}
+
+func clientConfig() (*rest.Config, error) {
+	if rootOpts.kubeconfig != "" {
+		return clientcmd.BuildConfigFromFlags("", rootOpts.kubeconfig)
+	}
+	return rest.InClusterConfig()
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/coreos/kubecsr/pkg/nodeapprover"
 	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
+	"github.com/coreos/kubecsr/pkg/nodeapprover/identity"
 	"github.com/golang/glog"
 
 	"k8s.io/api/core/v1"
@@ -25,10 +28,102 @@ import (
 
 var (
 	kubeconfig    = flag.String("kubeconfig", "", "kubeconfig file with acces to cluster. (testing only)")
-	cloudProvider = flag.String("cloud-provider", "", "The provider for cloud services.  Empty string for no provider.")
-	cloudConfig   = flag.String("cloud-config", "", "The path to the cloud provider configuration file.  Empty string for no configuration file.")
+	cloudProvider = flag.String("cloud-provider", "", "The provider(s) for cloud services, comma separated (e.g. \"aws,azure\"). Empty string for no provider.")
+	cloudConfig   = flag.String("cloud-config", "", "The path(s) to the cloud provider configuration file(s), comma separated and matched by position to --cloud-provider. Empty string for no configuration file.")
+
+	identityProviders identityProviderFlag
+	cloudPrefixes     cloudPrefixFlag
 )
 
+func init() {
+	flag.Var(&identityProviders, "identity-provider", "An identity provider to consult, in addition to the cloud provider, as 'name' or 'name=configFile'. May be repeated; providers are tried in the order given.")
+	flag.Var(&cloudPrefixes, "cloud-prefix", "Pin a node-name prefix to one of the --cloud-provider names, as 'prefix=name' (e.g. 'ip-=aws'), so CSRs for that node are only checked against that cloud. May be repeated.")
+}
+
+// identityProviderFlag collects repeated --identity-provider flag values.
+type identityProviderFlag []string
+
+func (f *identityProviderFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *identityProviderFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// cloudPrefixFlag collects repeated --cloud-prefix flag values into a
+// prefix->cloud-name map.
+type cloudPrefixFlag map[string]string
+
+func (f *cloudPrefixFlag) String() string {
+	var pairs []string
+	for prefix, name := range *f {
+		pairs = append(pairs, prefix+"="+name)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f *cloudPrefixFlag) Set(value string) error {
+	idx := strings.Index(value, "=")
+	if idx == -1 {
+		return fmt.Errorf("expected \"prefix=name\", got %q", value)
+	}
+	if *f == nil {
+		*f = cloudPrefixFlag{}
+	}
+	(*f)[value[:idx]] = value[idx+1:]
+	return nil
+}
+
+// buildCloudProvider constructs a single cloudprovider.Interface, or a
+// cloudprovider.MultiCloud aggregating one per comma-separated
+// --cloud-provider/--cloud-config entry.
+func buildCloudProvider(providerNames, configPaths string) (cloudprovider.Interface, error) {
+	names := strings.Split(providerNames, ",")
+	paths := make([]string, len(names))
+	if configPaths != "" {
+		splitPaths := strings.Split(configPaths, ",")
+		for i := range names {
+			if i < len(splitPaths) {
+				paths[i] = splitPaths[i]
+			}
+		}
+	}
+
+	clouds := make([]cloudprovider.NamedCloud, 0, len(names))
+	for i, name := range names {
+		cloud, err := cloudprovider.InitCloudProvider(name, paths[i])
+		if err != nil {
+			return nil, fmt.Errorf("error starting cloud provider %s: %v", name, err)
+		}
+		clouds = append(clouds, cloudprovider.NamedCloud{Name: name, Cloud: cloud})
+	}
+
+	if len(clouds) == 1 {
+		return clouds[0].Cloud, nil
+	}
+	return cloudprovider.NewMultiCloud(clouds...), nil
+}
+
+// buildIdentityProviders constructs an identity.IdentityProvider for each
+// --identity-provider flag value, in the order given.
+func buildIdentityProviders(specs identityProviderFlag) ([]identity.IdentityProvider, error) {
+	providers := make([]identity.IdentityProvider, 0, len(specs))
+	for _, spec := range specs {
+		name, configFile := spec, ""
+		if idx := strings.Index(spec, "="); idx != -1 {
+			name, configFile = spec[:idx], spec[idx+1:]
+		}
+		p, err := identity.InitIdentityProvider(name, configFile)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing identity provider %q: %v", name, err)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
 func main() {
 	flag.Set("logtostderr", "true")
 	flag.Parse()
@@ -49,13 +144,19 @@ func main() {
 		return
 	}
 
-	cloud, err := cloudprovider.InitCloudProvider(*cloudProvider, *cloudConfig)
+	cloud, err := buildCloudProvider(*cloudProvider, *cloudConfig)
 	if err != nil {
-		glog.Errorf("error starting cloud provider %s: %v", "aws", err)
+		glog.Errorf("error starting cloud providers: %v", err)
 		return
 	}
 	client := kubernetes.NewForConfigOrDie(config)
 
+	extraIdentityProviders, err := buildIdentityProviders(identityProviders)
+	if err != nil {
+		glog.Errorf("error building identity providers: %v", err)
+		return
+	}
+
 	id, err := os.Hostname()
 	if err != nil {
 		glog.Errorf("error %v", err)
@@ -87,7 +188,7 @@ func main() {
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(stop <-chan struct{}) {
 				glog.Info("Became leader: starting node-csr-approver.")
-				ar, err := approver.New(client, cloud)
+				ar, err := approver.New(client, cloud, cloudPrefixes, extraIdentityProviders...)
 				if err != nil {
 					glog.Fatalf("error creating approver %v", err)
 				}
@@ -1,23 +1,45 @@
+// Package aws is the original, AWS-only CSR approver: its Approver and
+// recognizers call AWS-specific helpers (awsCloud.instanceID,
+// awsCloud.autoScalingGroupID) directly rather than going through a
+// cloudprovider.Interface. It predates, and is kept alongside,
+// pkg/nodeapprover's provider-agnostic Approver, which AWS and Azure
+// clusters can now share instead of duplicating the recognizer pipeline
+// per cloud; prefer that package for new or multi-cloud deployments.
+//
+// A second, parallel cloudprovider.Interface-style abstraction scoped to
+// just this package was considered (to decouple isValidNewNode/
+// isValidNode/isValidASG from awsCloud), but rejected: it would duplicate
+// pkg/nodeapprover/cloudprovider's registry and GCE/Azure stubs under a
+// different interface shape, splitting multi-cloud support across two
+// incompatible provider models instead of one. GCE/Azure bootstrapping
+// belongs in pkg/nodeapprover, which already has GetInstanceIDByNodeName/
+// GetInstanceGroupByNodeName providers for aws and azure; this package
+// stays a thin AWS-only approver for existing single-cloud deployments.
+//
+// CSRs are read and approved through csrClient (see csrclient.go), which
+// picks certificates.k8s.io/v1 or v1beta1 at runtime depending on what the
+// API server serves; recognizers in csr.go always see the v1beta1-shaped
+// type regardless of which API is in play.
 package aws
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"time"
 
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
-
 	"github.com/golang/glog"
 	"github.com/juju/ratelimit"
 
+	authorizationv1 "k8s.io/api/authorization/v1"
 	certificates "k8s.io/api/certificates/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
-	certificateslisters "k8s.io/client-go/listers/certificates/v1beta1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -26,18 +48,115 @@ import (
 type Config struct {
 	RegionName  string
 	AllowedASGs []string
+
+	// ApproveServingCerts opts into auto-approving kubelet serving
+	// (server auth) CSRs in addition to the client-cert CSRs this
+	// approver has always handled. Off by default: a wrongly-approved
+	// serving cert lets a node impersonate another node's kubelet API,
+	// so operators must opt in explicitly.
+	ApproveServingCerts bool
+
+	// RequireInstanceIdentityDocument opts into requiring new-node CSRs
+	// (see isValidNewNode) to carry a verifyInstanceIdentity extension,
+	// rather than trusting the bootstrap token's instance-id claim alone.
+	// Off by default for backwards compatibility with existing bootstrap
+	// tooling that doesn't embed the extension yet; when on,
+	// AWSAccountID and InstanceIdentityCertFile are required.
+	RequireInstanceIdentityDocument bool
+
+	// AWSAccountID is the expected accountId field of a verified instance
+	// identity document. Only consulted when RequireInstanceIdentityDocument is set.
+	AWSAccountID string
+
+	// InstanceIdentityCertFile is a path to a PEM-encoded AWS public
+	// certificate to verify instance identity documents' PKCS7 signature
+	// against. AWS publishes a certificate per partition/region
+	// alongside the IMDS instance-identity-document docs; this is left
+	// as operator-supplied file input, the same way pkg/certsigner
+	// treats CA material, rather than baking a specific region's
+	// certificate bytes into the binary. Only consulted when
+	// RequireInstanceIdentityDocument is set.
+	InstanceIdentityCertFile string
+
+	// SARGroup, SARResource, and SARVerb set the ResourceAttributes
+	// authorize checks via SubjectAccessReview, alongside each
+	// recognizer's own Subresource (see RecognizerPermission). Default to
+	// the same certificates.k8s.io/certificatesigningrequests "create"
+	// check upstream kube-controller-manager's CSR approver performs;
+	// left overridable for operators with a different RBAC policy.
+	SARGroup    string
+	SARResource string
+	SARVerb     string
+
+	// VerifySignedCertificates opts into re-checking a CSR's issued
+	// certificate (see Approver.verifySigned) once the signer populates
+	// status.certificate: the chain must verify against VerifyCAFile with
+	// the expected client/server EKU, and the cert's CN/SANs must match
+	// what was requested. A CSR whose signed certificate fails this is
+	// patched Denied instead of being left Approved. Off by default since
+	// it requires VerifyCAFile; existing deployments that trust their
+	// signer unconditionally are unaffected.
+	VerifySignedCertificates bool
+
+	// VerifyCAFile is a path to a PEM bundle of CA certificates that a
+	// signed certificate must chain to. Required when
+	// VerifySignedCertificates is set.
+	VerifyCAFile string
+
+	// SignerName, when set, restricts the CSR informer to CSRs whose
+	// spec.signerName equals this value, via a field selector, so this
+	// Approver only ever sees (and spends a SubjectAccessReview on) CSRs
+	// it's actually responsible for. Left empty (the pre-signerName-aware
+	// behavior) watches every CSR regardless of signerName, same as
+	// before this field existed.
+	SignerName string
+
+	// EnabledPolicies names additional ApprovalPolicy checks (see
+	// PolicyNameASGMembership, PolicyNameKubeletServing) a CSR must also
+	// pass, on top of the recognizer chain and authorize's
+	// SubjectAccessReview, before handle approves it. Left empty (the
+	// default), no ApprovalPolicy runs and approval behaves exactly as it
+	// did before ApprovalPolicy existed.
+	EnabledPolicies []string
+
+	// PolicyMode selects how EnabledPolicies are combined: PolicyAND
+	// (default) requires all of them to allow a CSR, PolicyOR requires
+	// only one.
+	PolicyMode PolicyMode
+
+	// MaxRetries bounds how many times processNextItem retries a CSR that
+	// keeps failing syncFunc before giving up and denying it with reason
+	// ExceededRetries, mirroring upstream kube-controller-manager's CSR
+	// approver giving up on a request it can't make progress on rather
+	// than retrying it forever. Zero (the default) retries indefinitely,
+	// the pre-existing behavior.
+	MaxRetries int
 }
 
 // Approver approvers CSRs.
 type Approver struct {
-	kubeClient  kubernetes.Interface
-	aws         *awsCloud
-	allowedASGs sets.String
-
-	csrInformer cache.SharedIndexInformer
-	csrLister   certificateslisters.CertificateSigningRequestLister
-	csrSynced   cache.InformerSynced
-	queue       workqueue.RateLimitingInterface
+	kubeClient          kubernetes.Interface
+	aws                 *awsCloud
+	allowedASGs         sets.String
+	approveServingCerts bool
+
+	requireInstanceIdentityDocument bool
+	awsAccountID                    string
+	instanceIdentityCert            *x509.Certificate
+
+	sarGroup    string
+	sarResource string
+	sarVerb     string
+
+	verifySignedCertificates bool
+	verifyRoots              *x509.CertPool
+
+	policy     ApprovalPolicy
+	maxRetries int
+
+	csr       csrClient
+	csrSynced cache.InformerSynced
+	queue     workqueue.RateLimitingInterface
 }
 
 // New return a new Approver.
@@ -53,38 +172,97 @@ func New(client kubernetes.Interface, config Config) (*Approver, error) {
 	ar.aws = c
 
 	ar.allowedASGs = sets.NewString(config.AllowedASGs...)
+	ar.approveServingCerts = config.ApproveServingCerts
+
+	ar.sarGroup = config.SARGroup
+	if ar.sarGroup == "" {
+		ar.sarGroup = "certificates.k8s.io"
+	}
+	ar.sarResource = config.SARResource
+	if ar.sarResource == "" {
+		ar.sarResource = "certificatesigningrequests"
+	}
+	ar.sarVerb = config.SARVerb
+	if ar.sarVerb == "" {
+		ar.sarVerb = "create"
+	}
+
+	ar.requireInstanceIdentityDocument = config.RequireInstanceIdentityDocument
+	if ar.requireInstanceIdentityDocument {
+		if config.AWSAccountID == "" {
+			return nil, fmt.Errorf("AWSAccountID is required when RequireInstanceIdentityDocument is set")
+		}
+		if config.InstanceIdentityCertFile == "" {
+			return nil, fmt.Errorf("InstanceIdentityCertFile is required when RequireInstanceIdentityDocument is set")
+		}
+		cert, err := loadCertificate(config.InstanceIdentityCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading InstanceIdentityCertFile: %v", err)
+		}
+		ar.awsAccountID = config.AWSAccountID
+		ar.instanceIdentityCert = cert
+	}
+
+	ar.verifySignedCertificates = config.VerifySignedCertificates
+	if ar.verifySignedCertificates {
+		if config.VerifyCAFile == "" {
+			return nil, fmt.Errorf("VerifyCAFile is required when VerifySignedCertificates is set")
+		}
+		roots, err := loadCertPool(config.VerifyCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading VerifyCAFile: %v", err)
+		}
+		ar.verifyRoots = roots
+	}
+
+	if len(config.EnabledPolicies) > 0 {
+		policies := make([]ApprovalPolicy, 0, len(config.EnabledPolicies))
+		for _, name := range config.EnabledPolicies {
+			switch name {
+			case PolicyNameASGMembership:
+				policies = append(policies, ar.ASGMembershipPolicy())
+			case PolicyNameKubeletServing:
+				policies = append(policies, ar.KubeletServingPolicy())
+			default:
+				return nil, fmt.Errorf("unknown EnabledPolicies entry %q", name)
+			}
+		}
+		ar.policy = NewCompositePolicy(config.PolicyMode, policies...)
+	}
+
+	ar.maxRetries = config.MaxRetries
 
 	ar.queue = workqueue.NewRateLimitingQueue(workqueue.NewMaxOfRateLimiter(
 		workqueue.NewItemExponentialFailureRateLimiter(200*time.Millisecond, 100*time.Second),
 		&workqueue.BucketRateLimiter{Bucket: ratelimit.NewBucketWithRate(float64(10), int64(100))},
 	))
 
-	csrInformer := cache.NewSharedIndexInformer(
-		&cache.ListWatch{
-			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-				return client.CertificatesV1beta1().CertificateSigningRequests().List(options)
-			},
-			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-				return client.CertificatesV1beta1().CertificateSigningRequests().Watch(options)
-			},
-		},
-		&certificates.CertificateSigningRequest{},
-		3*time.Minute,
-		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
-	)
-	csrInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	csr, err := newCSRClient(client, 3*time.Minute, config.SignerName)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up CSR client: %v", err)
+	}
+	ar.csr = csr
+	ar.csr.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			csr := obj.(*certificates.CertificateSigningRequest)
-			glog.V(4).Infof("Adding certificate request %s", csr.Name)
+			glog.V(4).Infof("Adding certificate request %s", csrObjectName(obj))
 			ar.enqueueCertificateRequest(obj)
 		},
 	})
-	ar.csrLister = certificateslisters.NewCertificateSigningRequestLister(csrInformer.GetIndexer())
-	ar.csrSynced = csrInformer.HasSynced
+	ar.csrSynced = ar.csr.HasSynced()
 
 	return ar, nil
 }
 
+// csrObjectName returns obj's name for logging, regardless of whether it is
+// a v1 or v1beta1 CertificateSigningRequest.
+func csrObjectName(obj interface{}) string {
+	accessor, err := apimeta.Accessor(obj)
+	if err != nil {
+		return fmt.Sprintf("%v", obj)
+	}
+	return accessor.GetName()
+}
+
 // Run starts the Approver's consumers.
 func (ar *Approver) Run(stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
@@ -93,7 +271,7 @@ func (ar *Approver) Run(stopCh <-chan struct{}) {
 	glog.Info("Starting kube-aws-approver")
 	defer glog.Info("Shutting down kube-aws-approver")
 
-	go ar.csrInformer.Run(stopCh)
+	go ar.csr.Run(stopCh)
 
 	if !cache.WaitForCacheSync(stopCh, ar.csrSynced) {
 		utilruntime.HandleError(fmt.Errorf("error timeout waiting for caches"))
@@ -114,22 +292,52 @@ func (ar *Approver) processNextItem() bool {
 	}
 	defer ar.queue.Done(key)
 
-	if err := ar.syncFunc(key.(string)); err != nil {
-		ar.queue.AddRateLimited(key)
-		glog.V(4).Infof("Sync %v failed with : %v", key, err)
+	err := ar.syncFunc(key.(string))
+	if err == nil {
+		ar.queue.Forget(key)
 		return true
 	}
 
-	ar.queue.Forget(key)
+	if ar.maxRetries > 0 && ar.queue.NumRequeues(key) >= ar.maxRetries {
+		glog.Errorf("csr %v still failing after %d retries, giving up and denying it: %v", key, ar.maxRetries, err)
+		if derr := ar.denyExceededRetries(key.(string)); derr != nil {
+			glog.Errorf("error denying csr %v after exceeding retries: %v", key, derr)
+		}
+		ar.queue.Forget(key)
+		return true
+	}
+
+	ar.queue.AddRateLimited(key)
+	glog.V(4).Infof("Sync %v failed with : %v", key, err)
 	return true
 }
 
+// denyExceededRetries marks the CSR named key Denied with reason
+// ExceededRetries, once processNextItem has retried syncFunc on it
+// ar.maxRetries times without success.
+func (ar *Approver) denyExceededRetries(key string) error {
+	csr, err := ar.csr.Get(key)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	csr = csr.DeepCopy()
+
+	if approved, denied := getCertApprovalCondition(&csr.Status); approved || denied {
+		return nil
+	}
+
+	return ar.deny(csr, "ExceededRetries", fmt.Sprintf("csr still failed to process after %d retries", ar.maxRetries))
+}
+
 func (ar *Approver) syncFunc(key string) error {
 	startTime := time.Now()
 	defer func() {
 		glog.V(4).Infof("Finished syncing certificate request %q (%v)", key, time.Now().Sub(startTime))
 	}()
-	csr, err := ar.csrLister.Get(key)
+	csr, err := ar.csr.Get(key)
 	if apierrors.IsNotFound(err) {
 		glog.V(3).Infof("csr has been deleted: %v", key)
 		return nil
@@ -138,13 +346,24 @@ func (ar *Approver) syncFunc(key string) error {
 		return err
 	}
 
-	if csr.Status.Certificate != nil {
-		// no need to do anything because it already has a cert
-		return nil
-	}
 	// need to operate on a copy so we don't mutate the csr in the shared cache
 	csr = csr.DeepCopy()
 
+	if len(csr.Status.Certificate) != 0 {
+		if !ar.verifySignedCertificates {
+			return nil
+		}
+		if _, denied := getCertApprovalCondition(&csr.Status); denied {
+			// already denied on a previous sync; nothing more to do.
+			return nil
+		}
+		if err := ar.verifySigned(csr); err != nil {
+			glog.Errorf("signed certificate for csr %s failed verification: %v", csr.GetName(), err)
+			return ar.denySigned(csr, err.Error())
+		}
+		return nil
+	}
+
 	return ar.handle(csr)
 }
 
@@ -156,3 +375,65 @@ func (ar *Approver) enqueueCertificateRequest(obj interface{}) {
 	}
 	ar.queue.Add(key)
 }
+
+// authorize reports whether csr's requester is authorized, via a
+// SubjectAccessReview against the API server, to create a CSR for the
+// given certificates.k8s.io subresource -- mirroring the
+// "nodeclient"/"selfnodeclient" subresource checks upstream
+// kube-controller-manager's CSR approver performs before auto-approving.
+// This runs in addition to, not instead of, the recognizer chain (node
+// identity, instance-id, ASG membership, ...) in csr.go.
+func (ar *Approver) authorize(csr *certificates.CertificateSigningRequest, subresource string) bool {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range csr.Spec.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   csr.Spec.Username,
+			UID:    csr.Spec.UID,
+			Groups: csr.Spec.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:       ar.sarGroup,
+				Resource:    ar.sarResource,
+				Subresource: subresource,
+				Verb:        ar.sarVerb,
+			},
+		},
+	}
+
+	result, err := ar.kubeClient.AuthorizationV1().SubjectAccessReviews().Create(sar)
+	if err != nil {
+		glog.Errorf("error creating SubjectAccessReview for %q: %v", csr.Spec.Username, err)
+		return false
+	}
+	return result.Status.Allowed
+}
+
+// loadCertificate reads and parses a single PEM-encoded certificate from path.
+func loadCertificate(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// loadCertPool reads a PEM bundle of one or more CA certificates from path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,83 @@
+package aws
+
+import (
+	"testing"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeCSRClient is a minimal csrClient test double: it only exercises Get
+// and UpdateApproval, the two methods denyExceededRetries and processNextItem
+// actually call.
+type fakeCSRClient struct {
+	csr           *certificates.CertificateSigningRequest
+	updateCalls   int
+	lastCondition certificates.CertificateSigningRequestCondition
+}
+
+func (f *fakeCSRClient) Get(name string) (*certificates.CertificateSigningRequest, error) {
+	if f.csr == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "certificatesigningrequests"}, name)
+	}
+	return f.csr, nil
+}
+
+func (f *fakeCSRClient) UpdateApproval(csr *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error) {
+	f.updateCalls++
+	f.lastCondition = csr.Status.Conditions[len(csr.Status.Conditions)-1]
+	f.csr = csr
+	return csr, nil
+}
+
+func (f *fakeCSRClient) AddEventHandler(handler cache.ResourceEventHandlerFuncs) {}
+func (f *fakeCSRClient) Run(stopCh <-chan struct{})                             {}
+func (f *fakeCSRClient) HasSynced() cache.InformerSynced                       { return func() bool { return true } }
+
+func TestDenyExceededRetries(t *testing.T) {
+	csr := makeTestCSR(t, csrBuilder{cn: "system:node:foo"})
+	fc := &fakeCSRClient{csr: csr}
+	ar := &Approver{csr: fc, maxRetries: 5}
+
+	if err := ar.denyExceededRetries(csr.ObjectMeta.Name); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.updateCalls != 1 {
+		t.Fatalf("expected 1 UpdateApproval call, got %d", fc.updateCalls)
+	}
+	if fc.lastCondition.Type != certificates.CertificateDenied {
+		t.Errorf("expected Denied condition, got %v", fc.lastCondition.Type)
+	}
+	if fc.lastCondition.Reason != "ExceededRetries" {
+		t.Errorf("expected reason ExceededRetries, got %q", fc.lastCondition.Reason)
+	}
+}
+
+// TestDenyExceededRetriesAlreadyDecided confirms a CSR that's already been
+// approved or denied (e.g. by a concurrent sync) is left untouched.
+func TestDenyExceededRetriesAlreadyDecided(t *testing.T) {
+	csr := makeTestCSR(t, csrBuilder{cn: "system:node:foo"})
+	csr.Status.Conditions = append(csr.Status.Conditions, certificates.CertificateSigningRequestCondition{
+		Type: certificates.CertificateApproved,
+	})
+	fc := &fakeCSRClient{csr: csr}
+	ar := &Approver{csr: fc, maxRetries: 5}
+
+	if err := ar.denyExceededRetries(csr.ObjectMeta.Name); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fc.updateCalls != 0 {
+		t.Fatalf("expected no UpdateApproval call for an already-decided csr, got %d", fc.updateCalls)
+	}
+}
+
+// TestDenyExceededRetriesNotFound confirms a CSR deleted out from under us
+// (the same race syncFunc already tolerates) is a no-op, not an error.
+func TestDenyExceededRetriesNotFound(t *testing.T) {
+	ar := &Approver{csr: &fakeCSRClient{}, maxRetries: 5}
+	if err := ar.denyExceededRetries("gone"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
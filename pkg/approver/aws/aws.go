@@ -13,8 +13,9 @@ import (
 )
 
 type awsCloud struct {
-	ec2 *ec2.EC2
-	asg *autoscaling.AutoScaling
+	ec2        *ec2.EC2
+	asg        *autoscaling.AutoScaling
+	regionName string
 }
 
 func newAWSCloud(regionName string) (*awsCloud, error) {
@@ -47,12 +48,33 @@ func newAWSCloud(regionName string) (*awsCloud, error) {
 	asg := autoscaling.New(session.New(awsConfig))
 
 	return &awsCloud{
-		ec2: ec2,
-		asg: asg,
+		ec2:        ec2,
+		asg:        asg,
+		regionName: regionName,
 	}, nil
 }
 
+// region returns the AWS region this cloud resolves instances in, i.e.
+// the region passed to newAWSCloud or, if that was empty, the one
+// auto-detected from the instance metadata service.
+func (c *awsCloud) region() string {
+	return c.regionName
+}
+
 func (c *awsCloud) instanceID(nodeName string) (string, error) {
+	instance, err := c.instance(nodeName)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(instance.InstanceId), nil
+}
+
+// instance returns the EC2 instance registered under nodeName's
+// private DNS name, for recognizers that need more than just the
+// instance ID (e.g. isValidServingSANs, which needs the instance's
+// addresses).
+func (c *awsCloud) instance(nodeName string) (*ec2.Instance, error) {
 	privateDNSName := nodeName
 	filters := []*ec2.Filter{
 		newEC2Filter("private-dns-name", privateDNSName),
@@ -63,17 +85,17 @@ func (c *awsCloud) instanceID(nodeName string) (string, error) {
 	}
 	instances, err := c.describeInstances(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(instances) == 0 {
-		return "", fmt.Errorf("no instance found for %s", nodeName)
+		return nil, fmt.Errorf("no instance found for %s", nodeName)
 	}
 	if len(instances) > 1 {
-		return "", fmt.Errorf("multiple instances found for name: %s", nodeName)
+		return nil, fmt.Errorf("multiple instances found for name: %s", nodeName)
 	}
 
-	return aws.StringValue(instances[0].InstanceId), nil
+	return instances[0], nil
 }
 
 func (c *awsCloud) autoScalingGroupID(nodeName string) (string, error) {
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/glog"
 	certificates "k8s.io/api/certificates/v1beta1"
 	"k8s.io/api/core/v1"
@@ -35,7 +37,7 @@ func (ar *Approver) handle(csr *certificates.CertificateSigningRequest) error {
 		rs := csrr.recognizers
 		approved := true
 		for _, r := range rs {
-			if rerr := r(csr, x509cr); err != nil {
+			if rerr := r(csr, x509cr); rerr != nil {
 				glog.V(4).Infof("handle: %v", rerr)
 				approved = false
 				break
@@ -45,13 +47,29 @@ func (ar *Approver) handle(csr *certificates.CertificateSigningRequest) error {
 			continue
 		}
 
+		if !ar.authorize(csr, csrr.permission.Subresource) {
+			glog.V(4).Infof("handle: csr %s matched %s but was denied by SubjectAccessReview for subresource %q", csr.GetName(), csrr.successMessage, csrr.permission.Subresource)
+			continue
+		}
+
+		if ar.policy != nil {
+			allowed, reason, err := ar.policy.Validate(csr)
+			if err != nil {
+				return fmt.Errorf("error evaluating approval policy for csr %s: %v", csr.GetName(), err)
+			}
+			if !allowed {
+				glog.V(4).Infof("handle: csr %s matched %s but was denied by approval policy: %s", csr.GetName(), csrr.successMessage, reason)
+				return ar.denyPolicy(csr, reason)
+			}
+		}
+
 		glog.V(4).Infof("csr %s was approved! message: %s", csr.GetName(), csrr.successMessage)
 		csr.Status.Conditions = append(csr.Status.Conditions, certificates.CertificateSigningRequestCondition{
 			Type:    certificates.CertificateApproved,
 			Reason:  "AutoApproved",
 			Message: csrr.successMessage,
 		})
-		_, err = ar.kubeClient.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(csr)
+		_, err = ar.csr.UpdateApproval(csr)
 		if err != nil {
 			return fmt.Errorf("error updating approval for csr: %v", err)
 		}
@@ -62,21 +80,63 @@ func (ar *Approver) handle(csr *certificates.CertificateSigningRequest) error {
 }
 
 type recognizerFunc func(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) error
+
+// RecognizerPermission binds a csrRecognizer to the certificates.k8s.io
+// subresource authorize checks via SubjectAccessReview before approving a
+// CSR that chain matched, mirroring the "nodeclient"/"selfnodeclient"/
+// "selfnodeserver" subresources upstream kube-controller-manager's CSR
+// approver authorizes against.
+type RecognizerPermission struct {
+	Subresource string
+}
+
 type csrRecognizer struct {
 	recognizers    []recognizerFunc
 	successMessage string
+	permission     RecognizerPermission
 }
 
+const (
+	selfNodeClientSubresource = "selfnodeclient"
+	nodeClientSubresource     = "nodeclient"
+	selfNodeServerSubresource = "selfnodeserver"
+)
+
 func (ar *Approver) recognizers() []csrRecognizer {
-	return []csrRecognizer{{
+	csrrs := []csrRecognizer{{
 		// ensures: node present their client cert, exist(running) in cluster, belong to whitelist of ASGs.
 		recognizers:    []recognizerFunc{isSelfNodeClientCert, ar.isValidNode(ar.aws.instanceID), ar.isValidASG(ar.aws.autoScalingGroupID)},
 		successMessage: "kube-aws-approver approved self node client cert",
+		permission:     RecognizerPermission{Subresource: selfNodeClientSubresource},
 	}, {
 		// ensures: node username has instance-id, presented instance-id match instance-id by aws for node name in running state, doesn't belong to cluster, belong to whitelist of ASGs.
-		recognizers:    []recognizerFunc{isNodeClientCert, ar.isValidNewNode(ar.aws.instanceID), ar.isValidASG(ar.aws.autoScalingGroupID)},
+		recognizers:    ar.newNodeRecognizers(),
 		successMessage: "kube-aws-approver approved new node client cert",
+		permission:     RecognizerPermission{Subresource: nodeClientSubresource},
 	}}
+
+	if ar.approveServingCerts {
+		csrrs = append(csrrs, csrRecognizer{
+			// ensures: node presents its own serving cert, exists(running) in cluster, belongs to whitelist of ASGs,
+			// and every DNS/IP SAN it is requesting resolves to that same EC2 instance.
+			recognizers:    []recognizerFunc{isSelfNodeServerCert, ar.isValidNode(ar.aws.instanceID), ar.isValidServingSANs(ar.aws.instance), ar.isValidASG(ar.aws.autoScalingGroupID)},
+			successMessage: "kube-aws-approver approved node serving cert",
+			permission:     RecognizerPermission{Subresource: selfNodeServerSubresource},
+		})
+	}
+
+	return csrrs
+}
+
+// newNodeRecognizers returns the recognizer chain for isValidNewNode's
+// CSR, appending verifyInstanceIdentity when the approver was configured
+// to require it.
+func (ar *Approver) newNodeRecognizers() []recognizerFunc {
+	rs := []recognizerFunc{isNodeClientCert, ar.isValidNewNode(ar.aws.instanceID), ar.isValidASG(ar.aws.autoScalingGroupID)}
+	if ar.requireInstanceIdentityDocument {
+		rs = append(rs, ar.verifyInstanceIdentity(ar.instanceIdentityCert, ar.awsAccountID, ar.aws.region()))
+	}
+	return rs
 }
 
 // isNodeClientCert checks if
@@ -96,8 +156,8 @@ func isNodeClientCert(csr *certificates.CertificateSigningRequest, x509cr *x509.
 	if (len(x509cr.DNSNames) > 0) || (len(x509cr.EmailAddresses) > 0) || (len(x509cr.IPAddresses) > 0) {
 		return fmt.Errorf("isNodeClientCert: error non empty dnsnames/emailaddress/ipaddress")
 	}
-	if !hasExactUsages(csr, kubeletClientUsages) {
-		return fmt.Errorf("isNodeClientCert: error invalid key usages")
+	if !hasExactUsages(csr, kubeletClientUsages, kubeAPIServerClientKubeletSignerName) {
+		return fmt.Errorf("isNodeClientCert: error invalid key usages or signer name")
 	}
 	if !strings.HasPrefix(x509cr.Subject.CommonName, "system:node:") {
 		return fmt.Errorf("isNodeClientCert: error common name doesn't have system:node: prefix")
@@ -120,6 +180,105 @@ func isSelfNodeClientCert(csr *certificates.CertificateSigningRequest, x509cr *x
 	return nil
 }
 
+// isSelfNodeServerCert checks if
+// - x509.CertificateRequest Organization is equal to system:nodes
+// - x509.CertificateRequest CommonName has 'system:node:' prefix
+// - CertificateSigningRequest has valid serving key usages (digital signature, key encipherment, server auth)
+// - CertificateSigningRequest username matches the CertificateRequest CommonName, i.e. the node provided its own client cert to request this serving cert
+// - x509.CertificateRequest has at least one DNS or IP SAN, since a serving cert with no SANs can't serve anything
+// upstream: https://github.com/kubernetes/kubernetes/blob/7488d1c9210e60aef9ad49f07cb5d8a24152db88/pkg/controller/certificates/approver/sarapprove.go#L204
+// Returns nil when conditions met.
+func isSelfNodeServerCert(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) error {
+	if len(x509cr.Subject.Organization) != 1 || x509cr.Subject.Organization[0] != "system:nodes" {
+		return fmt.Errorf("isSelfNodeServerCert: error mismatch org")
+	}
+	if !hasExactUsages(csr, kubeletServerUsages, kubeletServingSignerName) {
+		return fmt.Errorf("isSelfNodeServerCert: error invalid key usages or signer name")
+	}
+	if !strings.HasPrefix(x509cr.Subject.CommonName, "system:node:") {
+		return fmt.Errorf("isSelfNodeServerCert: error common name doesn't have system:node: prefix")
+	}
+	if csr.Spec.Username != x509cr.Subject.CommonName {
+		return fmt.Errorf("isSelfNodeServerCert: error mismatch Username and CommonName")
+	}
+	if len(x509cr.DNSNames) == 0 && len(x509cr.IPAddresses) == 0 {
+		return fmt.Errorf("isSelfNodeServerCert: error serving cert requested with no DNS or IP SANs")
+	}
+	if len(x509cr.EmailAddresses) > 0 {
+		return fmt.Errorf("isSelfNodeServerCert: error non empty emailaddress")
+	}
+	return nil
+}
+
+type instanceFunc func(nodeName string) (*ec2.Instance, error)
+
+// isValidServingSANs is the kubelet serving-cert EC2-SAN-pinning
+// validator: it checks if
+// - there exists a valid, running instance corresponding to the nodename from CertificateRequest
+// - every DNS SAN requested appears in the instance's PrivateDnsName or PublicDnsName
+// - every IP SAN requested appears in the instance's PrivateIpAddress, PublicIpAddress, Ipv6Address, or a
+//   secondary private/IPv6 address on one of its attached network interfaces
+// Any SAN that isn't accounted for by the instance's own addresses causes the CSR to be rejected, so a
+// node can't request a serving cert valid for another node's address.
+// Returns nil when conditions met.
+func (ar *Approver) isValidServingSANs(f instanceFunc) recognizerFunc {
+	return func(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) error {
+		nn, err := getNodeNameFromCN(x509cr.Subject.CommonName)
+		if err != nil {
+			return fmt.Errorf("isValidServingSANs: error getting node name from common name: %v", err)
+		}
+
+		instance, err := f(nn)
+		if err != nil {
+			return fmt.Errorf("isValidServingSANs: error getting instance for %s: %v", nn, err)
+		}
+
+		allowedDNSNames := sets.NewString()
+		if dns := aws.StringValue(instance.PrivateDnsName); dns != "" {
+			allowedDNSNames.Insert(dns)
+		}
+		if dns := aws.StringValue(instance.PublicDnsName); dns != "" {
+			allowedDNSNames.Insert(dns)
+		}
+
+		allowedIPs := sets.NewString()
+		if ip := aws.StringValue(instance.PrivateIpAddress); ip != "" {
+			allowedIPs.Insert(ip)
+		}
+		if ip := aws.StringValue(instance.PublicIpAddress); ip != "" {
+			allowedIPs.Insert(ip)
+		}
+		if ip := aws.StringValue(instance.Ipv6Address); ip != "" {
+			allowedIPs.Insert(ip)
+		}
+		for _, eni := range instance.NetworkInterfaces {
+			for _, addr := range eni.PrivateIpAddresses {
+				if ip := aws.StringValue(addr.PrivateIpAddress); ip != "" {
+					allowedIPs.Insert(ip)
+				}
+			}
+			for _, addr := range eni.Ipv6Addresses {
+				if ip := aws.StringValue(addr.Ipv6Address); ip != "" {
+					allowedIPs.Insert(ip)
+				}
+			}
+		}
+
+		for _, dnsName := range x509cr.DNSNames {
+			if !allowedDNSNames.Has(dnsName) {
+				return fmt.Errorf("isValidServingSANs: DNS SAN %q does not belong to instance for node %s", dnsName, nn)
+			}
+		}
+		for _, ip := range x509cr.IPAddresses {
+			if !allowedIPs.Has(ip.String()) {
+				return fmt.Errorf("isValidServingSANs: IP SAN %q does not belong to instance for node %s", ip.String(), nn)
+			}
+		}
+
+		return nil
+	}
+}
+
 type instanceIDFunc func(nodeName string) (string, error)
 
 // isValidNewNode checks if
@@ -244,7 +403,30 @@ var kubeletClientUsages = []certificates.KeyUsage{
 	certificates.UsageClientAuth,
 }
 
-func hasExactUsages(csr *certificates.CertificateSigningRequest, usages []certificates.KeyUsage) bool {
+var kubeletServerUsages = []certificates.KeyUsage{
+	certificates.UsageKeyEncipherment,
+	certificates.UsageDigitalSignature,
+	certificates.UsageServerAuth,
+}
+
+// kubeAPIServerClientKubeletSignerName and kubeletServingSignerName are the
+// certificates.k8s.io/v1 built-in signerNames for, respectively, kubelet
+// client and kubelet serving certificates. See
+// https://kubernetes.io/docs/reference/access-authn-authz/certificate-signing-requests/#kubernetes-signers
+const (
+	kubeAPIServerClientKubeletSignerName = "kubernetes.io/kube-apiserver-client-kubelet"
+	kubeletServingSignerName             = "kubernetes.io/kubelet-serving"
+)
+
+func hasExactUsages(csr *certificates.CertificateSigningRequest, usages []certificates.KeyUsage, signerName string) bool {
+	// SignerName is a required field on the certificates.k8s.io/v1 API
+	// (always populated by csrFromV1), but is an optional *string on
+	// v1beta1; CSRs submitted directly against v1beta1 by clients that
+	// predate signerName are left unvalidated here rather than rejected.
+	if csr.Spec.SignerName != nil && *csr.Spec.SignerName != "" && *csr.Spec.SignerName != signerName {
+		return false
+	}
+
 	if len(usages) != len(csr.Spec.Usages) {
 		return false
 	}
@@ -0,0 +1,139 @@
+package aws
+
+import (
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	certificates "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+	testclient "k8s.io/client-go/testing"
+	csrutil "k8s.io/client-go/util/certificate/csr"
+)
+
+func TestAuthorize(t *testing.T) {
+	cases := []struct {
+		allowed bool
+		want    bool
+	}{
+		{allowed: true, want: true},
+		{allowed: false, want: false},
+	}
+
+	for _, c := range cases {
+		fclient := &fake.Clientset{}
+		fclient.AddReactor("create", "subjectaccessreviews", func(action testclient.Action) (bool, runtime.Object, error) {
+			return true, &authorizationv1.SubjectAccessReview{
+				Status: authorizationv1.SubjectAccessReviewStatus{Allowed: c.allowed},
+			}, nil
+		})
+		ar := &Approver{
+			kubeClient:  fclient,
+			sarGroup:    "certificates.k8s.io",
+			sarResource: "certificatesigningrequests",
+			sarVerb:     "create",
+		}
+		csr := makeTestCSR(t, csrBuilder{
+			cn:              "system:node:valid-node",
+			orgs:            []string{"system:nodes"},
+			requestor:       "system:node:valid-node",
+			requestorGroups: []string{"system:nodes"},
+			usages: []certificates.KeyUsage{
+				certificates.UsageKeyEncipherment,
+				certificates.UsageDigitalSignature,
+				certificates.UsageClientAuth,
+			},
+		})
+
+		got := ar.authorize(csr, selfNodeClientSubresource)
+		if got != c.want {
+			t.Errorf("authorize() = %v, want %v", got, c.want)
+		}
+
+		as := fclient.Actions()
+		if len(as) != 1 {
+			t.Fatalf("expected 1 client call but got: %#v", as)
+		}
+		a := as[0].(testclient.CreateActionImpl)
+		if got, expected := a.Resource.Resource, "subjectaccessreviews"; got != expected {
+			t.Errorf("got resource: %v, expected: %v", got, expected)
+		}
+		sar := a.Object.(*authorizationv1.SubjectAccessReview)
+		if got, expected := sar.Spec.ResourceAttributes.Subresource, selfNodeClientSubresource; got != expected {
+			t.Errorf("got subresource: %v, expected: %v", got, expected)
+		}
+		if got, expected := sar.Spec.ResourceAttributes.Group, "certificates.k8s.io"; got != expected {
+			t.Errorf("got group: %v, expected: %v", got, expected)
+		}
+	}
+}
+
+// TestAuthorizeRejectsRecognizedCSRWhenSARDenies verifies that a CSR which
+// passes every node/instance/ASG recognizer still fails authorize, the
+// check handle requires before approving, if its SubjectAccessReview comes
+// back Allowed: false.
+func TestAuthorizeRejectsRecognizedCSRWhenSARDenies(t *testing.T) {
+	nodetoid := map[string]string{"valid-node": "id-1"}
+	nodetoasg := map[string]string{"valid-node": "asg-1"}
+
+	b := csrBuilder{
+		cn:              "system:node:valid-node",
+		orgs:            []string{"system:nodes"},
+		requestor:       "system:node:valid-node",
+		requestorGroups: []string{"system:nodes"},
+		usages: []certificates.KeyUsage{
+			certificates.UsageKeyEncipherment,
+			certificates.UsageDigitalSignature,
+			certificates.UsageClientAuth,
+		},
+	}
+	csr := makeTestCSR(t, b)
+	x509cr, err := csrutil.ParseCSR(csr)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	fclient := &fake.Clientset{}
+	fclient.AddReactor("get", "nodes", func(action testclient.Action) (bool, runtime.Object, error) {
+		return true, &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "valid-node"},
+			Status: v1.NodeStatus{
+				Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			},
+		}, nil
+	})
+	fclient.AddReactor("create", "subjectaccessreviews", func(action testclient.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SubjectAccessReview{
+			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: false},
+		}, nil
+	})
+
+	ar := &Approver{
+		kubeClient:  fclient,
+		allowedASGs: sets.NewString("asg-1"),
+	}
+
+	// The recognizer chain for a self node client cert passes: org/usages/CN
+	// (no client calls), then node-readiness and ASG membership through
+	// injected stand-ins rather than ar.aws.
+	if err := isSelfNodeClientCert(csr, x509cr); err != nil {
+		t.Fatalf("expected isSelfNodeClientCert to pass, got: %v", err)
+	}
+	nodeOK := ar.isValidNode(mapNodesToInstanceIDs(nodetoid))
+	if err := nodeOK(csr, x509cr); err != nil {
+		t.Fatalf("expected isValidNode to pass, got: %v", err)
+	}
+	asgOK := ar.isValidASG(mapNodesToASGs(nodetoasg))
+	if err := asgOK(csr, x509cr); err != nil {
+		t.Fatalf("expected isValidASG to pass, got: %v", err)
+	}
+
+	// Even though the recognizer chain above matched, a denying SAR must
+	// still block approval.
+	if ar.authorize(csr, selfNodeClientSubresource) {
+		t.Fatalf("expected authorize to report false for a denying SubjectAccessReview")
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"net"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	certificates "k8s.io/api/certificates/v1beta1"
 	"k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -59,7 +61,7 @@ func TestHasKubeletUsages(t *testing.T) {
 			Spec: certificates.CertificateSigningRequestSpec{
 				Usages: c.usages,
 			},
-		}, kubeletClientUsages) != c.expected {
+		}, kubeletClientUsages, kubeAPIServerClientKubeletSignerName) != c.expected {
 			t.Errorf("unexpected result of hasKubeletUsages(%v), expecting: %v", c.usages, c.expected)
 		}
 	}
@@ -127,6 +129,18 @@ func TestClientCert(t *testing.T) {
 		},
 		r:   isSelfNodeClientCert,
 		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.signerName = "kubernetes.io/kubelet-serving"
+		},
+		r:   isNodeClientCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.signerName = kubeAPIServerClientKubeletSignerName
+		},
+		r:   isNodeClientCert,
+		err: false,
 	}}
 	for idx, c := range cases {
 		b := csrBuilder{
@@ -154,6 +168,180 @@ func TestClientCert(t *testing.T) {
 	}
 }
 
+func TestServerCert(t *testing.T) {
+	cases := []struct {
+		cbm func(b *csrBuilder)
+		r   recognizerFunc
+		err bool
+	}{{
+		cbm: func(b *csrBuilder) {},
+		r:   isSelfNodeServerCert,
+		err: false,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.orgs = []string{"system:master"}
+		},
+		r:   isSelfNodeServerCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.usages = append(b.usages, certificates.UsageClientAuth)
+		},
+		r:   isSelfNodeServerCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.cn = "test"
+		},
+		r:   isSelfNodeServerCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.requestor = "system:random-user"
+		},
+		r:   isSelfNodeServerCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.dns = nil
+			b.ips = nil
+		},
+		r:   isSelfNodeServerCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.emails = []string{"foo@example.com"}
+		},
+		r:   isSelfNodeServerCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.signerName = kubeAPIServerClientKubeletSignerName
+		},
+		r:   isSelfNodeServerCert,
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.signerName = kubeletServingSignerName
+		},
+		r:   isSelfNodeServerCert,
+		err: false,
+	}}
+	for idx, c := range cases {
+		b := csrBuilder{
+			cn:        "system:node:foo",
+			orgs:      []string{"system:nodes"},
+			requestor: "system:node:foo",
+			dns:       []string{"foo.ec2.internal"},
+			usages: []certificates.KeyUsage{
+				certificates.UsageKeyEncipherment,
+				certificates.UsageDigitalSignature,
+				certificates.UsageServerAuth,
+			},
+		}
+		c.cbm(&b)
+		t.Run(fmt.Sprintf("test #%d", idx), func(t *testing.T) {
+			csr := makeTestCSR(t, b)
+			x509cr, err := csrutil.ParseCSR(csr)
+			if err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			err = c.r(csr, x509cr)
+			if (err != nil) != c.err {
+				t.Errorf("expected err: %v got: %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestIsValidServingSANs(t *testing.T) {
+	nodeToInstance := map[string]*ec2.Instance{
+		"valid-node": {
+			PrivateDnsName:   aws.String("valid-node.ec2.internal"),
+			PrivateIpAddress: aws.String("10.0.0.1"),
+			Ipv6Address:      aws.String("2001:db8::1"),
+			NetworkInterfaces: []*ec2.InstanceNetworkInterface{{
+				PrivateIpAddresses: []*ec2.InstancePrivateIpAddress{{
+					PrivateIpAddress: aws.String("10.0.0.2"),
+				}},
+				Ipv6Addresses: []*ec2.InstanceIpv6Address{{
+					Ipv6Address: aws.String("2001:db8::2"),
+				}},
+			}},
+		},
+	}
+	cases := []struct {
+		cbm func(b *csrBuilder)
+		err bool
+	}{{
+		cbm: func(b *csrBuilder) {},
+		err: false,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.ips = []net.IP{net.ParseIP("10.0.0.2")}
+		},
+		err: false,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.ips = []net.IP{net.ParseIP("2001:db8::1")}
+		},
+		err: false,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.ips = []net.IP{net.ParseIP("2001:db8::2")}
+		},
+		err: false,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.cn = "system:node:unknown-node"
+		},
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.dns = []string{"other-node.ec2.internal"}
+		},
+		err: true,
+	}, {
+		cbm: func(b *csrBuilder) {
+			b.ips = []net.IP{net.ParseIP("10.0.0.99")}
+		},
+		err: true,
+	}}
+
+	for idx, c := range cases {
+		b := csrBuilder{
+			cn:        "system:node:valid-node",
+			orgs:      []string{"system:nodes"},
+			requestor: "system:node:valid-node",
+			dns:       []string{"valid-node.ec2.internal"},
+		}
+		c.cbm(&b)
+		t.Run(fmt.Sprintf("test #%d", idx), func(t *testing.T) {
+			ar := &Approver{}
+			csr := makeTestCSR(t, b)
+			x509cr, err := csrutil.ParseCSR(csr)
+			if err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			r := ar.isValidServingSANs(mapNodesToInstances(nodeToInstance))
+			err = r(csr, x509cr)
+			if (err != nil) != c.err {
+				t.Errorf("expected err: %v got: %v", c.err, err)
+			}
+		})
+	}
+}
+
+func mapNodesToInstances(m map[string]*ec2.Instance) instanceFunc {
+	return func(nodeName string) (*ec2.Instance, error) {
+		instance, ok := m[nodeName]
+		if !ok {
+			return nil, fmt.Errorf("no instance found for %s", nodeName)
+		}
+		return instance, nil
+	}
+}
+
 func TestIsValidNewNode(t *testing.T) {
 	nodetoid := map[string]string{
 		"valid-node":   "id-1",
@@ -605,6 +793,7 @@ type csrBuilder struct {
 	dns             []string
 	emails          []string
 	ips             []net.IP
+	signerName      string
 }
 
 func makeTestCSR(t *testing.T, b csrBuilder) *certificates.CertificateSigningRequest {
@@ -625,7 +814,7 @@ func makeTestCSR(t *testing.T, b csrBuilder) *certificates.CertificateSigningReq
 	if err != nil {
 		t.Fatalf("error creating csr: %v", err)
 	}
-	return &certificates.CertificateSigningRequest{
+	csr := &certificates.CertificateSigningRequest{
 		Spec: certificates.CertificateSigningRequestSpec{
 			Username: b.requestor,
 			Groups:   b.requestorGroups,
@@ -633,4 +822,8 @@ func makeTestCSR(t *testing.T, b csrBuilder) *certificates.CertificateSigningReq
 			Request:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrb}),
 		},
 	}
+	if b.signerName != "" {
+		csr.Spec.SignerName = &b.signerName
+	}
+	return csr
 }
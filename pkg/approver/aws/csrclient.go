@@ -0,0 +1,253 @@
+package aws
+
+import (
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificates "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"k8s.io/client-go/kubernetes"
+	certificatesv1listers "k8s.io/client-go/listers/certificates/v1"
+	certificatesv1b1listers "k8s.io/client-go/listers/certificates/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/coreos/kubecsr/pkg/util"
+)
+
+// csrClient abstracts the certificates.k8s.io CSR operations Approver
+// needs -- Get, UpdateApproval, and the informer feeding the work queue --
+// behind whichever of the v1 or v1beta1 API the cluster actually serves
+// (v1beta1 was removed in Kubernetes 1.22+). Recognizers and handle
+// continue to operate on the v1beta1-shaped type everywhere else in this
+// package; the v1 client converts at this boundary, including SignerName,
+// so isNodeClientCert/isSelfNodeServerCert can validate it regardless of
+// which API served the CSR.
+type csrClient interface {
+	Get(name string) (*certificates.CertificateSigningRequest, error)
+	UpdateApproval(csr *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error)
+	AddEventHandler(handler cache.ResourceEventHandlerFuncs)
+	Run(stopCh <-chan struct{})
+	HasSynced() cache.InformerSynced
+}
+
+// newCSRClient picks a v1 or v1beta1-backed csrClient, based on what the
+// API server behind client serves. signerName, if non-empty, is applied as
+// a field selector on the informer's List/Watch calls, so the API server
+// does the filtering and this Approver's workqueue/SubjectAccessReview
+// checks never see a CSR it isn't responsible for.
+func newCSRClient(client kubernetes.Interface, resyncPeriod time.Duration, signerName string) (csrClient, error) {
+	v1, err := util.KubeServerSupportsCertificatesV1API(client.Discovery())
+	if err != nil {
+		return nil, err
+	}
+
+	if v1 {
+		informer := cache.NewSharedIndexInformer(
+			&cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					applySignerNameSelector(&options, signerName)
+					return client.CertificatesV1().CertificateSigningRequests().List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					applySignerNameSelector(&options, signerName)
+					return client.CertificatesV1().CertificateSigningRequests().Watch(options)
+				},
+			},
+			&certificatesv1.CertificateSigningRequest{},
+			resyncPeriod,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		)
+		return &csrV1Client{
+			client:   client,
+			lister:   certificatesv1listers.NewCertificateSigningRequestLister(informer.GetIndexer()),
+			informer: informer,
+		}, nil
+	}
+
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				applySignerNameSelector(&options, signerName)
+				return client.CertificatesV1beta1().CertificateSigningRequests().List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				applySignerNameSelector(&options, signerName)
+				return client.CertificatesV1beta1().CertificateSigningRequests().Watch(options)
+			},
+		},
+		&certificates.CertificateSigningRequest{},
+		resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+	return &csrV1beta1Client{
+		client:   client,
+		lister:   certificatesv1b1listers.NewCertificateSigningRequestLister(informer.GetIndexer()),
+		informer: informer,
+	}, nil
+}
+
+// applySignerNameSelector sets options.FieldSelector to match signerName,
+// if signerName is non-empty; it's shared by both branches of
+// newCSRClient.
+func applySignerNameSelector(options *metav1.ListOptions, signerName string) {
+	if signerName == "" {
+		return
+	}
+	options.FieldSelector = fields.OneTermEqualSelector("spec.signerName", signerName).String()
+}
+
+// csrV1beta1Client is the original code path, unchanged: it already speaks
+// the v1beta1-shaped type the rest of this package uses.
+type csrV1beta1Client struct {
+	client   kubernetes.Interface
+	lister   certificatesv1b1listers.CertificateSigningRequestLister
+	informer cache.SharedIndexInformer
+}
+
+func (c *csrV1beta1Client) Get(name string) (*certificates.CertificateSigningRequest, error) {
+	return c.lister.Get(name)
+}
+
+func (c *csrV1beta1Client) UpdateApproval(csr *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error) {
+	return c.client.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(csr)
+}
+
+func (c *csrV1beta1Client) AddEventHandler(handler cache.ResourceEventHandlerFuncs) {
+	c.informer.AddEventHandler(handler)
+}
+
+func (c *csrV1beta1Client) Run(stopCh <-chan struct{}) {
+	c.informer.Run(stopCh)
+}
+
+func (c *csrV1beta1Client) HasSynced() cache.InformerSynced {
+	return c.informer.HasSynced
+}
+
+// csrV1Client drives the certificates.k8s.io/v1 API, converting to/from the
+// v1beta1-shaped type at every call so the rest of Approver is unaffected.
+type csrV1Client struct {
+	client   kubernetes.Interface
+	lister   certificatesv1listers.CertificateSigningRequestLister
+	informer cache.SharedIndexInformer
+}
+
+func (c *csrV1Client) Get(name string) (*certificates.CertificateSigningRequest, error) {
+	v1csr, err := c.lister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return csrFromV1(v1csr), nil
+}
+
+func (c *csrV1Client) UpdateApproval(csr *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error) {
+	v1csr, err := c.client.CertificatesV1().CertificateSigningRequests().Get(csr.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	v1csr.Status.Conditions = csrConditionsToV1(csr.Status.Conditions)
+
+	updated, err := c.client.CertificatesV1().CertificateSigningRequests().UpdateApproval(v1csr.Name, v1csr, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return csrFromV1(updated), nil
+}
+
+func (c *csrV1Client) AddEventHandler(handler cache.ResourceEventHandlerFuncs) {
+	c.informer.AddEventHandler(handler)
+}
+
+func (c *csrV1Client) Run(stopCh <-chan struct{}) {
+	c.informer.Run(stopCh)
+}
+
+func (c *csrV1Client) HasSynced() cache.InformerSynced {
+	return c.informer.HasSynced
+}
+
+// csrFromV1 converts a v1 CertificateSigningRequest into the v1beta1-shaped
+// type the rest of this package operates on, carrying SignerName across so
+// isNodeClientCert/isSelfNodeServerCert can validate it the same way
+// whether the CSR came in over the v1 or v1beta1 API.
+func csrFromV1(in *certificatesv1.CertificateSigningRequest) *certificates.CertificateSigningRequest {
+	return &certificates.CertificateSigningRequest{
+		ObjectMeta: in.ObjectMeta,
+		Spec: certificates.CertificateSigningRequestSpec{
+			Request:    in.Spec.Request,
+			Usages:     csrUsagesFromV1(in.Spec.Usages),
+			Username:   in.Spec.Username,
+			UID:        in.Spec.UID,
+			Groups:     in.Spec.Groups,
+			Extra:      csrExtraFromV1(in.Spec.Extra),
+			SignerName: &in.Spec.SignerName,
+		},
+		Status: certificates.CertificateSigningRequestStatus{
+			Certificate: in.Status.Certificate,
+			Conditions:  csrConditionsFromV1(in.Status.Conditions),
+		},
+	}
+}
+
+func csrUsagesFromV1(in []certificatesv1.KeyUsage) []certificates.KeyUsage {
+	if in == nil {
+		return nil
+	}
+	out := make([]certificates.KeyUsage, len(in))
+	for i, u := range in {
+		out[i] = certificates.KeyUsage(u)
+	}
+	return out
+}
+
+func csrExtraFromV1(in map[string]certificatesv1.ExtraValue) map[string]certificates.ExtraValue {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]certificates.ExtraValue, len(in))
+	for k, v := range in {
+		out[k] = certificates.ExtraValue(v)
+	}
+	return out
+}
+
+func csrConditionsFromV1(in []certificatesv1.CertificateSigningRequestCondition) []certificates.CertificateSigningRequestCondition {
+	if in == nil {
+		return nil
+	}
+	out := make([]certificates.CertificateSigningRequestCondition, len(in))
+	for i, c := range in {
+		out[i] = certificates.CertificateSigningRequestCondition{
+			Type:    certificates.RequestConditionType(c.Type),
+			Reason:  c.Reason,
+			Message: c.Message,
+		}
+	}
+	return out
+}
+
+func csrConditionsToV1(in []certificates.CertificateSigningRequestCondition) []certificatesv1.CertificateSigningRequestCondition {
+	if in == nil {
+		return nil
+	}
+	out := make([]certificatesv1.CertificateSigningRequestCondition, len(in))
+	for i, c := range in {
+		out[i] = certificatesv1.CertificateSigningRequestCondition{
+			Type:    certificatesv1.RequestConditionType(c.Type),
+			Reason:  c.Reason,
+			Message: c.Message,
+			// v1 requires Status on every condition; v1beta1 has no
+			// equivalent field. The conditions passed here are the
+			// Approved ones ar.handle appends and the Denied ones ar.deny
+			// appends, both of which are True.
+			Status: corev1.ConditionTrue,
+		}
+	}
+	return out
+}
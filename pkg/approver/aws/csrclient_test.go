@@ -0,0 +1,217 @@
+package aws
+
+import (
+	"encoding/pem"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificates "k8s.io/api/certificates/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	testclient "k8s.io/client-go/testing"
+	csrutil "k8s.io/client-go/util/certificate/csr"
+)
+
+// withServerGroup makes fclient's discovery client report gv (e.g.
+// "certificates.k8s.io/v1") as a served API group/version, the same way a
+// real API server would, so newCSRClient's discovery check can be
+// exercised against a fake.
+func withServerGroup(fclient *fake.Clientset, gv string) {
+	fclient.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{GroupVersion: gv}}
+}
+
+func TestNewCSRClientPicksV1WhenServed(t *testing.T) {
+	fclient := fake.NewSimpleClientset()
+	withServerGroup(fclient, "certificates.k8s.io/v1")
+
+	c, err := newCSRClient(fclient, time.Minute, "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, ok := c.(*csrV1Client); !ok {
+		t.Fatalf("expected a *csrV1Client, got %T", c)
+	}
+}
+
+func TestNewCSRClientFallsBackToV1beta1(t *testing.T) {
+	fclient := fake.NewSimpleClientset()
+	withServerGroup(fclient, "certificates.k8s.io/v1beta1")
+
+	c, err := newCSRClient(fclient, time.Minute, "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, ok := c.(*csrV1beta1Client); !ok {
+		t.Fatalf("expected a *csrV1beta1Client, got %T", c)
+	}
+}
+
+// TestApplySignerNameSelector confirms a non-empty signerName is rendered
+// as a spec.signerName field selector -- the selector newCSRClient applies
+// to its List/Watch calls, so the API server, not this Approver, filters
+// out CSRs it isn't responsible for -- and that an empty signerName (the
+// pre-signerName-aware default) leaves ListOptions untouched.
+func TestApplySignerNameSelector(t *testing.T) {
+	var opts metav1.ListOptions
+	applySignerNameSelector(&opts, "")
+	if opts.FieldSelector != "" {
+		t.Errorf("empty signerName: FieldSelector = %q, want empty", opts.FieldSelector)
+	}
+
+	applySignerNameSelector(&opts, kubeAPIServerClientKubeletSignerName)
+	want := "spec.signerName=" + kubeAPIServerClientKubeletSignerName
+	if opts.FieldSelector != want {
+		t.Errorf("FieldSelector = %q, want %q", opts.FieldSelector, want)
+	}
+}
+
+func TestCSRFromV1CarriesSignerName(t *testing.T) {
+	in := &certificatesv1.CertificateSigningRequest{
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   "system:node:foo",
+			SignerName: kubeAPIServerClientKubeletSignerName,
+		},
+	}
+	out := csrFromV1(in)
+	if out.Spec.SignerName == nil || *out.Spec.SignerName != kubeAPIServerClientKubeletSignerName {
+		t.Fatalf("expected SignerName %q, got %v", kubeAPIServerClientKubeletSignerName, out.Spec.SignerName)
+	}
+}
+
+// TestCSRV1ClientUpdateApproval exercises csrV1Client.UpdateApproval
+// end-to-end against a fake v1 clientset: the submitted v1 object must
+// carry the Approved condition set on the v1beta1-shaped input.
+func TestCSRV1ClientUpdateApproval(t *testing.T) {
+	fclient := fake.NewSimpleClientset()
+	fclient.PrependReactor("get", "certificatesigningrequests", func(action testclient.Action) (bool, runtime.Object, error) {
+		return true, &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		}, nil
+	})
+	var updated *certificatesv1.CertificateSigningRequest
+	fclient.PrependReactor("update", "certificatesigningrequests", func(action testclient.Action) (bool, runtime.Object, error) {
+		updated = action.(testclient.UpdateActionImpl).Object.(*certificatesv1.CertificateSigningRequest)
+		return true, updated, nil
+	})
+
+	c := &csrV1Client{client: fclient}
+	csr := &certificates.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Status: certificates.CertificateSigningRequestStatus{
+			Conditions: []certificates.CertificateSigningRequestCondition{{
+				Type:    certificates.CertificateApproved,
+				Reason:  "AutoApproved",
+				Message: "kube-aws-approver approved self node client cert",
+			}},
+		},
+	}
+
+	if _, err := c.UpdateApproval(csr); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if updated == nil || len(updated.Status.Conditions) != 1 {
+		t.Fatalf("expected the v1 client to submit one condition, got: %#v", updated)
+	}
+	if got := updated.Status.Conditions[0].Type; got != certificatesv1.CertificateApproved {
+		t.Errorf("expected condition type %q, got %q", certificatesv1.CertificateApproved, got)
+	}
+}
+
+// makeTestV1CSR builds the certificates.k8s.io/v1 equivalent of
+// makeTestCSR(t, b), so recognizer tests can run unchanged against either
+// API version's shape.
+func makeTestV1CSR(t *testing.T, b csrBuilder) *certificatesv1.CertificateSigningRequest {
+	v1beta1csr := makeTestCSR(t, b)
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: v1beta1csr.ObjectMeta,
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    v1beta1csr.Spec.Request,
+			Usages:     csrUsagesToV1(v1beta1csr.Spec.Usages),
+			Username:   v1beta1csr.Spec.Username,
+			Groups:     v1beta1csr.Spec.Groups,
+			SignerName: b.signerName,
+		},
+	}
+}
+
+func csrUsagesToV1(in []certificates.KeyUsage) []certificatesv1.KeyUsage {
+	out := make([]certificatesv1.KeyUsage, len(in))
+	for i, u := range in {
+		out[i] = certificatesv1.KeyUsage(u)
+	}
+	return out
+}
+
+// TestRecognizersAgainstV1CSR confirms isNodeClientCert and
+// isSelfNodeServerCert -- including their SignerName check -- behave the
+// same whether a CSR arrived over the v1beta1 or v1 API, by running them
+// against a v1 CSR converted through csrFromV1.
+func TestRecognizersAgainstV1CSR(t *testing.T) {
+	cases := []struct {
+		name string
+		b    csrBuilder
+		r    recognizerFunc
+		err  bool
+	}{{
+		name: "valid client cert",
+		b: csrBuilder{
+			cn:         "system:node:foo",
+			orgs:       []string{"system:nodes"},
+			requestor:  "system:node:foo",
+			usages:     kubeletClientUsages,
+			signerName: kubeAPIServerClientKubeletSignerName,
+		},
+		r:   isSelfNodeClientCert,
+		err: false,
+	}, {
+		name: "client cert with serving signerName is rejected",
+		b: csrBuilder{
+			cn:         "system:node:foo",
+			orgs:       []string{"system:nodes"},
+			requestor:  "system:node:foo",
+			usages:     kubeletClientUsages,
+			signerName: kubeletServingSignerName,
+		},
+		r:   isSelfNodeClientCert,
+		err: true,
+	}, {
+		name: "valid serving cert",
+		b: csrBuilder{
+			cn:         "system:node:foo",
+			orgs:       []string{"system:nodes"},
+			requestor:  "system:node:foo",
+			dns:        []string{"foo.ec2.internal"},
+			usages:     kubeletServerUsages,
+			signerName: kubeletServingSignerName,
+		},
+		r:   isSelfNodeServerCert,
+		err: false,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v1csr := makeTestV1CSR(t, c.b)
+			csr := csrFromV1(v1csr)
+
+			block, _ := pem.Decode(csr.Spec.Request)
+			if block == nil {
+				t.Fatalf("no PEM data in csr request")
+			}
+			x509cr, err := csrutil.ParseCSR(csr)
+			if err != nil {
+				t.Fatalf("unexpected err parsing csr: %v", err)
+			}
+
+			err = c.r(csr, x509cr)
+			if c.err && err == nil {
+				t.Fatalf("expected an error, got none")
+			}
+			if !c.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
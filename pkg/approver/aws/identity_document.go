@@ -0,0 +1,103 @@
+package aws
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fullsailor/pkcs7"
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// oidInstanceIdentityDocument is the custom X.509 extension OID bootstrap
+// tooling embeds the EC2 instance identity document's PKCS7 signature
+// blob under, so it travels to the approver as part of the CSR itself
+// rather than a side channel. 99999 is this project's placeholder private
+// enterprise number under 1.3.6.1.4.1 until a real one is registered.
+var oidInstanceIdentityDocument = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+// instanceIdentityDocument is the subset of the JSON payload IMDS's
+// /latest/dynamic/instance-identity/document endpoint returns (also
+// embedded, signed, in the pkcs7 endpoint's payload) that
+// verifyInstanceIdentity checks against the CSR.
+type instanceIdentityDocument struct {
+	InstanceID string `json:"instanceId"`
+	AccountID  string `json:"accountId"`
+	Region     string `json:"region"`
+}
+
+// BuildInstanceIdentityExtension returns the pkix.Extension node bootstrap
+// tooling should add to its CSR template so verifyInstanceIdentity can
+// authenticate it. pkcs7Signature is the raw (base64-decoded) body of
+// IMDS's /latest/dynamic/instance-identity/pkcs7 endpoint.
+func BuildInstanceIdentityExtension(pkcs7Signature []byte) pkix.Extension {
+	return pkix.Extension{
+		Id:    oidInstanceIdentityDocument,
+		Value: pkcs7Signature,
+	}
+}
+
+// verifyInstanceIdentity checks if
+// - the CertificateRequest carries an oidInstanceIdentityDocument extension
+// - its value parses as a PKCS7 signature verifiable against trustedCert
+// - the signed payload decodes as an instanceIdentityDocument
+// - its instanceId matches the instance-id in the CertificateSigningRequest username (system:bootstrappers:<id>)
+// - its accountId matches accountID
+// - its region matches regionName
+// trustedCert is the AWS public certificate for the region the approver runs in; AWS publishes these
+// certificates alongside the IMDS instance identity document documentation. A forged or
+// replayed-from-another-account/region identity document is rejected, so a leaked bootstrap token alone
+// can no longer mint a node identity isValidNewNode would otherwise trust.
+// Returns nil when conditions met.
+func (ar *Approver) verifyInstanceIdentity(trustedCert *x509.Certificate, accountID, regionName string) recognizerFunc {
+	return func(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) error {
+		ext, err := findExtension(x509cr, oidInstanceIdentityDocument)
+		if err != nil {
+			return fmt.Errorf("verifyInstanceIdentity: %v", err)
+		}
+
+		p7, err := pkcs7.Parse(ext.Value)
+		if err != nil {
+			return fmt.Errorf("verifyInstanceIdentity: error parsing pkcs7 instance identity document: %v", err)
+		}
+		p7.Certificates = []*x509.Certificate{trustedCert}
+		if err := p7.Verify(); err != nil {
+			return fmt.Errorf("verifyInstanceIdentity: error verifying pkcs7 signature: %v", err)
+		}
+
+		var doc instanceIdentityDocument
+		if err := json.Unmarshal(p7.Content, &doc); err != nil {
+			return fmt.Errorf("verifyInstanceIdentity: error decoding instance identity document: %v", err)
+		}
+
+		idu, err := getInstanceIDFromUsername(csr.Spec.Username)
+		if err != nil {
+			return fmt.Errorf("verifyInstanceIdentity: error getting id from username: %v", err)
+		}
+		if doc.InstanceID != idu {
+			return fmt.Errorf("verifyInstanceIdentity: error instance identity document instance id %q doesn't match username instance id %q", doc.InstanceID, idu)
+		}
+		if doc.AccountID != accountID {
+			return fmt.Errorf("verifyInstanceIdentity: error instance identity document account %q doesn't match configured account %q", doc.AccountID, accountID)
+		}
+		if doc.Region != regionName {
+			return fmt.Errorf("verifyInstanceIdentity: error instance identity document region %q doesn't match approver region %q", doc.Region, regionName)
+		}
+
+		return nil
+	}
+}
+
+// findExtension returns the named extension from x509cr, or an error if
+// it isn't present.
+func findExtension(x509cr *x509.CertificateRequest, oid asn1.ObjectIdentifier) (*pkix.Extension, error) {
+	for _, ext := range x509cr.Extensions {
+		if ext.Id.Equal(oid) {
+			e := ext
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("CSR is missing the instance identity document extension %s", oid)
+}
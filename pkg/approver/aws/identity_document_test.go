@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+func TestBuildInstanceIdentityExtension(t *testing.T) {
+	sig := []byte("fake-pkcs7-signature")
+	ext := BuildInstanceIdentityExtension(sig)
+	if !ext.Id.Equal(oidInstanceIdentityDocument) {
+		t.Fatalf("expected extension id %v, got %v", oidInstanceIdentityDocument, ext.Id)
+	}
+	if string(ext.Value) != string(sig) {
+		t.Fatalf("expected extension value %q, got %q", sig, ext.Value)
+	}
+}
+
+func TestFindExtension(t *testing.T) {
+	present := pkix.Extension{Id: oidInstanceIdentityDocument, Value: []byte("sig")}
+	other := pkix.Extension{Id: []int{1, 2, 3}, Value: []byte("other")}
+
+	cases := []struct {
+		name       string
+		extensions []pkix.Extension
+		wantErr    bool
+	}{{
+		name:       "present",
+		extensions: []pkix.Extension{other, present},
+		wantErr:    false,
+	}, {
+		name:       "missing",
+		extensions: []pkix.Extension{other},
+		wantErr:    true,
+	}, {
+		name:       "empty",
+		extensions: nil,
+		wantErr:    true,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			x509cr := &x509.CertificateRequest{Extensions: c.extensions}
+			ext, err := findExtension(x509cr, oidInstanceIdentityDocument)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(ext.Value) != "sig" {
+				t.Fatalf("expected value %q, got %q", "sig", ext.Value)
+			}
+		})
+	}
+}
+
+func TestVerifyInstanceIdentityMissingExtension(t *testing.T) {
+	ar := &Approver{}
+	csr := &certificates.CertificateSigningRequest{
+		Spec: certificates.CertificateSigningRequestSpec{
+			Username: "system:bootstrappers:id-1",
+		},
+	}
+	x509cr := &x509.CertificateRequest{}
+
+	err := ar.verifyInstanceIdentity(&x509.Certificate{}, "1234", "us-east-1")(csr, x509cr)
+	if err == nil {
+		t.Fatalf("expected error for a CSR missing the instance identity document extension")
+	}
+}
@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"fmt"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+	csrutil "k8s.io/client-go/util/certificate/csr"
+)
+
+// ApprovalPolicy is an additional, composable gate a CSR must pass once it
+// has already matched a recognizer chain and authorize's SubjectAccessReview
+// in handle. Unlike a recognizerFunc, an ApprovalPolicy failure doesn't just
+// fall through to the next recognizer chain -- it actively denies the CSR
+// (see Approver.denyPolicy), since by this point the CSR is known to be the
+// kind of request this approver handles; the policy is saying it shouldn't
+// be honored this time.
+type ApprovalPolicy interface {
+	// Validate reports whether csr is allowed. A false allowed with a nil
+	// err means the policy ran successfully and rejected csr; reason is
+	// recorded on the CSR's Denied condition. A non-nil err means the
+	// policy itself failed to evaluate (e.g. an AWS API call errored) and
+	// csr is left pending rather than denied.
+	Validate(csr *certificates.CertificateSigningRequest) (allowed bool, reason string, err error)
+}
+
+// PolicyMode selects how CompositePolicy combines its policies' verdicts.
+type PolicyMode string
+
+const (
+	// PolicyAND requires every policy to allow a CSR, the conservative
+	// default: enabling a second policy only ever narrows what gets
+	// approved, never widens it.
+	PolicyAND PolicyMode = "AND"
+	// PolicyOR allows a CSR if any policy allows it.
+	PolicyOR PolicyMode = "OR"
+)
+
+// Policy name strings accepted by Config.EnabledPolicies.
+const (
+	PolicyNameASGMembership  = "asg-membership"
+	PolicyNameKubeletServing = "kubelet-serving"
+)
+
+// CompositePolicy combines one or more ApprovalPolicy values under mode.
+// An empty CompositePolicy allows everything, matching Approver leaving
+// ar.policy nil when no policies are configured.
+type CompositePolicy struct {
+	policies []ApprovalPolicy
+	mode     PolicyMode
+}
+
+// NewCompositePolicy returns a CompositePolicy combining policies under
+// mode. mode defaults to PolicyAND if empty.
+func NewCompositePolicy(mode PolicyMode, policies ...ApprovalPolicy) *CompositePolicy {
+	if mode == "" {
+		mode = PolicyAND
+	}
+	return &CompositePolicy{policies: policies, mode: mode}
+}
+
+func (p *CompositePolicy) Validate(csr *certificates.CertificateSigningRequest) (bool, string, error) {
+	if len(p.policies) == 0 {
+		return true, "", nil
+	}
+
+	switch p.mode {
+	case PolicyOR:
+		var reasons []string
+		for _, policy := range p.policies {
+			allowed, reason, err := policy.Validate(csr)
+			if err != nil {
+				return false, "", err
+			}
+			if allowed {
+				return true, "", nil
+			}
+			reasons = append(reasons, reason)
+		}
+		return false, fmt.Sprintf("denied by all of: %v", reasons), nil
+	default:
+		for _, policy := range p.policies {
+			allowed, reason, err := policy.Validate(csr)
+			if err != nil {
+				return false, "", err
+			}
+			if !allowed {
+				return false, reason, nil
+			}
+		}
+		return true, "", nil
+	}
+}
+
+// funcApprovalPolicy adapts an existing recognizerFunc (isValidASG,
+// isSelfNodeServerCert, isValidServingSANs, ...) into an ApprovalPolicy, so
+// policies can reuse the recognizer chain's own validation instead of
+// duplicating it: a recognizerFunc's non-nil error becomes a deny with that
+// error's message as the reason.
+type funcApprovalPolicy struct {
+	name string
+	f    recognizerFunc
+}
+
+func (p funcApprovalPolicy) Validate(csr *certificates.CertificateSigningRequest) (bool, string, error) {
+	x509cr, err := csrutil.ParseCSR(csr)
+	if err != nil {
+		return false, "", fmt.Errorf("%s: error parsing CSR: %v", p.name, err)
+	}
+	if rerr := p.f(csr, x509cr); rerr != nil {
+		return false, rerr.Error(), nil
+	}
+	return true, "", nil
+}
+
+// ASGMembershipPolicy wraps isValidASG as an ApprovalPolicy, for operators
+// who want ASG membership re-checked as its own named, independently
+// toggleable policy rather than only as a step inside the fixed recognizer
+// chains in recognizers().
+func (ar *Approver) ASGMembershipPolicy() ApprovalPolicy {
+	return funcApprovalPolicy{name: PolicyNameASGMembership, f: ar.isValidASG(ar.aws.autoScalingGroupID)}
+}
+
+// KubeletServingPolicy wraps isSelfNodeServerCert and isValidServingSANs as
+// a single ApprovalPolicy, enabled via Config.EnabledPolicies. handle runs
+// the configured policy on every CSR that matched a recognizer chain, so
+// this policy sees serving CSRs only when ApproveServingCerts is set, and a
+// client-cert CSR fails its isSelfNodeServerCert check.
+func (ar *Approver) KubeletServingPolicy() ApprovalPolicy {
+	return NewCompositePolicy(PolicyAND,
+		funcApprovalPolicy{name: PolicyNameKubeletServing, f: isSelfNodeServerCert},
+		funcApprovalPolicy{name: PolicyNameKubeletServing, f: ar.isValidServingSANs(ar.aws.instance)},
+	)
+}
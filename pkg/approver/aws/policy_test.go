@@ -0,0 +1,125 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// fixedPolicy is a test-only ApprovalPolicy stand-in that always returns the
+// configured verdict, so CompositePolicy's combination logic can be tested
+// without going through funcApprovalPolicy/recognizerFunc.
+type fixedPolicy struct {
+	allowed bool
+	reason  string
+	err     error
+}
+
+func (p fixedPolicy) Validate(csr *certificates.CertificateSigningRequest) (bool, string, error) {
+	return p.allowed, p.reason, p.err
+}
+
+func TestCompositePolicyAND(t *testing.T) {
+	cases := []struct {
+		name     string
+		policies []ApprovalPolicy
+		want     bool
+		wantErr  bool
+	}{
+		{name: "empty allows", policies: nil, want: true},
+		{name: "all allow", policies: []ApprovalPolicy{fixedPolicy{allowed: true}, fixedPolicy{allowed: true}}, want: true},
+		{name: "one denies", policies: []ApprovalPolicy{fixedPolicy{allowed: true}, fixedPolicy{allowed: false, reason: "no"}}, want: false},
+		{name: "error short-circuits", policies: []ApprovalPolicy{fixedPolicy{err: errors.New("boom")}, fixedPolicy{allowed: false}}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := NewCompositePolicy(PolicyAND, c.policies...)
+			allowed, _, err := p.Validate(&certificates.CertificateSigningRequest{})
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed != c.want {
+				t.Errorf("Validate() = %v, want %v", allowed, c.want)
+			}
+		})
+	}
+}
+
+func TestCompositePolicyOR(t *testing.T) {
+	cases := []struct {
+		name     string
+		policies []ApprovalPolicy
+		want     bool
+	}{
+		{name: "empty allows", policies: nil, want: true},
+		{name: "one allows", policies: []ApprovalPolicy{fixedPolicy{allowed: false, reason: "no"}, fixedPolicy{allowed: true}}, want: true},
+		{name: "all deny", policies: []ApprovalPolicy{fixedPolicy{allowed: false, reason: "a"}, fixedPolicy{allowed: false, reason: "b"}}, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := NewCompositePolicy(PolicyOR, c.policies...)
+			allowed, _, err := p.Validate(&certificates.CertificateSigningRequest{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed != c.want {
+				t.Errorf("Validate() = %v, want %v", allowed, c.want)
+			}
+		})
+	}
+}
+
+// TestFuncApprovalPolicy confirms funcApprovalPolicy adapts a recognizerFunc
+// faithfully: a passing recognizer allows, a failing one denies with its
+// error's message as the reason.
+func TestFuncApprovalPolicy(t *testing.T) {
+	csr := makeTestCSR(t, csrBuilder{
+		cn:              "system:node:valid-node",
+		orgs:            []string{"system:nodes"},
+		requestor:       "system:node:valid-node",
+		requestorGroups: []string{"system:nodes"},
+		usages: []certificates.KeyUsage{
+			certificates.UsageKeyEncipherment,
+			certificates.UsageDigitalSignature,
+			certificates.UsageClientAuth,
+		},
+	})
+
+	p := funcApprovalPolicy{name: "test", f: isSelfNodeClientCert}
+	allowed, reason, err := p.Validate(csr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !allowed {
+		t.Fatalf("expected allowed, got denied: %s", reason)
+	}
+
+	badCSR := makeTestCSR(t, csrBuilder{
+		cn:        "not-a-node-cn",
+		requestor: "not-a-node-cn",
+		usages: []certificates.KeyUsage{
+			certificates.UsageKeyEncipherment,
+			certificates.UsageDigitalSignature,
+			certificates.UsageClientAuth,
+		},
+	})
+	allowed, reason, err = p.Validate(badCSR)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Fatalf("expected denied for a CSR with no system:node: prefix")
+	}
+	if reason == "" {
+		t.Fatalf("expected a non-empty deny reason")
+	}
+}
@@ -0,0 +1,112 @@
+package aws
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	csrutil "k8s.io/client-go/util/certificate/csr"
+)
+
+// verifySigned is the kubelet-style post-sign check: once a signer has
+// populated csr.Status.Certificate, this verifies the issued certificate
+// actually matches what was requested and chains to ar.verifyRoots, rather
+// than trusting that recognizer approval alone implies a usable
+// certificate. It catches a signer bug or misconfiguration (wrong EKU,
+// wrong CN/SANs, an unexpected chain) that would otherwise leave a bad
+// certificate sitting behind an Approved condition.
+func (ar *Approver) verifySigned(csr *certificates.CertificateSigningRequest) error {
+	x509cr, err := csrutil.ParseCSR(csr)
+	if err != nil {
+		return fmt.Errorf("verifySigned: error parsing CSR: %v", err)
+	}
+
+	block, _ := pem.Decode(csr.Status.Certificate)
+	if block == nil {
+		return fmt.Errorf("verifySigned: error no PEM data found in status.certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("verifySigned: error parsing issued certificate: %v", err)
+	}
+
+	ekus := []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	if hasUsage(csr, certificates.UsageServerAuth) {
+		ekus = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: ar.verifyRoots, KeyUsages: ekus}); err != nil {
+		return fmt.Errorf("verifySigned: error verifying issued certificate chain: %v", err)
+	}
+
+	if cert.Subject.CommonName != x509cr.Subject.CommonName {
+		return fmt.Errorf("verifySigned: error issued certificate CN %q doesn't match requested CN %q", cert.Subject.CommonName, x509cr.Subject.CommonName)
+	}
+	if !sameStringSet(cert.DNSNames, x509cr.DNSNames) {
+		return fmt.Errorf("verifySigned: error issued certificate DNS SANs %v don't match requested SANs %v", cert.DNSNames, x509cr.DNSNames)
+	}
+	if !sameIPSet(cert.IPAddresses, x509cr.IPAddresses) {
+		return fmt.Errorf("verifySigned: error issued certificate IP SANs %v don't match requested SANs %v", cert.IPAddresses, x509cr.IPAddresses)
+	}
+
+	return nil
+}
+
+// deny appends a Denied condition carrying reason and message to csr and
+// updates it via csrClient.UpdateApproval, mirroring the Approved condition
+// ar.handle appends. denySigned, denyPolicy, and denyExceededRetries all
+// funnel through this so every denial path in this approver produces the
+// same condition shape.
+func (ar *Approver) deny(csr *certificates.CertificateSigningRequest, reason, message string) error {
+	csr.Status.Conditions = append(csr.Status.Conditions, certificates.CertificateSigningRequestCondition{
+		Type:    certificates.CertificateDenied,
+		Reason:  reason,
+		Message: message,
+	})
+	if _, err := ar.csr.UpdateApproval(csr); err != nil {
+		return fmt.Errorf("error updating denial for csr: %v", err)
+	}
+	return nil
+}
+
+// denySigned denies csr with reason SignedCertificateVerificationFailed,
+// for a signed certificate that failed verifySigned.
+func (ar *Approver) denySigned(csr *certificates.CertificateSigningRequest, reason string) error {
+	return ar.deny(csr, "SignedCertificateVerificationFailed", reason)
+}
+
+// denyPolicy denies csr with reason ApprovalPolicyDenied, the
+// ApprovalPolicy counterpart of denySigned: a CSR that matched a
+// recognizer chain but failed Approver.policy is denied rather than left
+// pending, since a policy failure (unlike a recognizer mismatch) is a
+// statement that this specific CSR is actively disallowed, not just that
+// no rule recognized it yet.
+func (ar *Approver) denyPolicy(csr *certificates.CertificateSigningRequest, reason string) error {
+	return ar.deny(csr, "ApprovalPolicyDenied", reason)
+}
+
+func hasUsage(csr *certificates.CertificateSigningRequest, usage certificates.KeyUsage) bool {
+	for _, u := range csr.Spec.Usages {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+func sameStringSet(a, b []string) bool {
+	return sets.NewString(a...).Equal(sets.NewString(b...))
+}
+
+func sameIPSet(a []net.IP, b []net.IP) bool {
+	as, bs := sets.NewString(), sets.NewString()
+	for _, ip := range a {
+		as.Insert(ip.String())
+	}
+	for _, ip := range b {
+		bs.Insert(ip.String())
+	}
+	return as.Equal(bs)
+}
@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	csrutil "k8s.io/client-go/util/certificate/csr"
+)
+
+func makeTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating CA cert: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("error parsing CA cert: %v", err)
+	}
+	return cert, key
+}
+
+func makeTestLeafCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, cn string, notBefore, notAfter time.Time, ekus []x509.ExtKeyUsage) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating leaf key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  ekus,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("error creating leaf cert: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestVerifySigned(t *testing.T) {
+	ca, caKey := makeTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	now := time.Now()
+	cases := []struct {
+		name      string
+		cn        string
+		notBefore time.Time
+		notAfter  time.Time
+		ekus      []x509.ExtKeyUsage
+		wantErr   bool
+	}{{
+		name:      "valid",
+		cn:        "system:node:foo",
+		notBefore: now.Add(-time.Hour),
+		notAfter:  now.Add(time.Hour),
+		ekus:      []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		wantErr:   false,
+	}, {
+		name:      "expired",
+		cn:        "system:node:foo",
+		notBefore: now.Add(-2 * time.Hour),
+		notAfter:  now.Add(-time.Hour),
+		ekus:      []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		wantErr:   true,
+	}, {
+		name:      "future-dated",
+		cn:        "system:node:foo",
+		notBefore: now.Add(time.Hour),
+		notAfter:  now.Add(2 * time.Hour),
+		ekus:      []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		wantErr:   true,
+	}, {
+		name:      "wrong eku",
+		cn:        "system:node:foo",
+		notBefore: now.Add(-time.Hour),
+		notAfter:  now.Add(time.Hour),
+		ekus:      []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		wantErr:   true,
+	}, {
+		name:      "mismatched cn",
+		cn:        "system:node:bar",
+		notBefore: now.Add(-time.Hour),
+		notAfter:  now.Add(time.Hour),
+		ekus:      []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		wantErr:   true,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			csr := makeTestCSR(t, csrBuilder{
+				cn:        "system:node:foo",
+				orgs:      []string{"system:nodes"},
+				requestor: "system:node:foo",
+				usages:    kubeletClientUsages,
+			})
+			csr.Status.Certificate = makeTestLeafCert(t, ca, caKey, c.cn, c.notBefore, c.notAfter, c.ekus)
+
+			ar := &Approver{verifyRoots: roots}
+			err := ar.verifySigned(csr)
+			if c.wantErr && err == nil {
+				t.Fatalf("expected error, got none")
+			}
+			if !c.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// TestVerifySignedServingEKU confirms a server-auth CSR is verified against
+// ExtKeyUsageServerAuth instead of ExtKeyUsageClientAuth.
+func TestVerifySignedServingEKU(t *testing.T) {
+	ca, caKey := makeTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	now := time.Now()
+
+	csr := makeTestCSR(t, csrBuilder{
+		cn:        "system:node:foo",
+		orgs:      []string{"system:nodes"},
+		requestor: "system:node:foo",
+		dns:       []string{"foo.ec2.internal"},
+		usages:    kubeletServerUsages,
+	})
+	csr.Status.Certificate = makeTestLeafCert(t, ca, caKey, "system:node:foo", now.Add(-time.Hour), now.Add(time.Hour), []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+
+	ar := &Approver{verifyRoots: roots}
+	if err := ar.verifySigned(csr); err == nil {
+		t.Fatalf("expected error verifying a client-auth-only cert against a serving CSR")
+	}
+
+	_, err := csrutil.ParseCSR(csr)
+	if err != nil {
+		t.Fatalf("unexpected err parsing csr: %v", err)
+	}
+}
@@ -1,15 +1,21 @@
 package certagent
 
 import (
+	"crypto"
+	cryptorand "crypto/rand"
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"path"
 	"time"
 
 	"github.com/golang/glog"
 	capi "k8s.io/api/certificates/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	certificatesclient "k8s.io/client-go/kubernetes/typed/certificates/v1beta1"
@@ -30,6 +36,11 @@ type CSRConfig struct {
 	// Alternate Name values required to create CertificateRequest
 	DNSNames    []string `json:"dnsNames"`
 	IPAddresses []net.IP `json:"ipAddresses"`
+	// URIs is a list of URI SANs to request, e.g. a SPIFFE ID such as
+	// "spiffe://cluster.local/etcd/peer/<name>", for issuing workload
+	// identities consumable by mTLS meshes rather than just etcd
+	// peer/server certs. Each entry must parse as a URL.
+	URIs []string `json:"uris"`
 
 	// AssetsDir is the directory location where certificates and
 	// private keys will be saved
@@ -38,6 +49,16 @@ type CSRConfig struct {
 	// CSRName is the name of the CertificateSigningRequest object
 	// that will be created
 	CSRName string `json:"csrName"`
+
+	// SignerName is stamped onto Spec.SignerName. It is required by the
+	// certificates.k8s.io/v1 API (e.g. "kubernetes.io/kube-apiserver-client",
+	// or a custom name like "kubecsr.coreos.com/etcd" for etcd CSRs); v1beta1
+	// ignored it if unset. Leave empty when talking to a v1beta1-only signer.
+	SignerName string `json:"signerName"`
+
+	// MaxRetry bounds how many times RequestCertificate retries a failed
+	// CSR submission before giving up. 0 (the default) retries forever.
+	MaxRetry int `json:"maxRetry"`
 }
 
 // CertAgent is the top level object that represents a certificate agent.
@@ -89,12 +110,21 @@ func GenerateCSRObject(config CSRConfig) (*capi.CertificateSigningRequest, error
 		return nil, fmt.Errorf("error generating private key bytes: %v", err)
 	}
 
-	privateKey, err := certutil.ParsePrivateKeyPEM(privateKeyBytes)
+	parsedKey, err := certutil.ParsePrivateKeyPEM(privateKeyBytes)
 	if err != nil {
 		return nil, fmt.Errorf("invalid private key for certificate request: %v", err)
 	}
+	privateKey, ok := parsedKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key of type %T does not implement crypto.Signer", parsedKey)
+	}
+
+	uris, err := ParseURIs(config.URIs)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URI SANs: %v", err)
+	}
 
-	csrData, err := certutil.MakeCSR(privateKey, subject, config.DNSNames, config.IPAddresses)
+	csrData, err := MakeCSR(privateKey, subject, config.DNSNames, config.IPAddresses, uris)
 	if err != nil {
 		return nil, fmt.Errorf("error generating certificate request bytes: %v", err)
 	}
@@ -106,10 +136,51 @@ func GenerateCSRObject(config CSRConfig) (*capi.CertificateSigningRequest, error
 			Request: csrData,
 		},
 	}
+	if config.SignerName != "" {
+		csr.Spec.SignerName = &config.SignerName
+	}
 
 	return csr, nil
 }
 
+// ParseURIs parses each entry of uris as a URL, in order, for use as a
+// CertificateRequest's URI SANs. Exported so pkg/renewer's HTTP-based CSR
+// generation can share it instead of duplicating URI SAN parsing.
+func ParseURIs(uris []string) ([]*url.URL, error) {
+	if len(uris) == 0 {
+		return nil, nil
+	}
+	parsed := make([]*url.URL, len(uris))
+	for i, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %q: %v", uri, err)
+		}
+		parsed[i] = u
+	}
+	return parsed, nil
+}
+
+// MakeCSR builds a PEM-encoded PKCS#10 certificate request, the same way
+// certutil.MakeCSR does, except it also sets uris as URI SANs, which
+// certutil.MakeCSR has no way to request. Exported so pkg/renewer's
+// HTTP-based CSR generation can share it instead of duplicating the
+// x509.CertificateRequest template.
+func MakeCSR(privateKey crypto.Signer, subject *pkix.Name, dnsNames []string, ipAddresses []net.IP, uris []*url.URL) ([]byte, error) {
+	template := &x509.CertificateRequest{
+		Subject:     *subject,
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+		URIs:        uris,
+	}
+
+	der, err := x509.CreateCertificateRequest(cryptorand.Reader, template, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: certutil.CertificateRequestBlockType, Bytes: der}), nil
+}
+
 // RequestCertificate will create a certificate signing request for a node
 // with the config given and send it to a signer via a POST request.
 // If something goes wrong it returns an error but wait forever for
@@ -121,16 +192,27 @@ func (c *CertAgent) RequestCertificate() error {
 		return fmt.Errorf("error generating CSR Object: %v", err)
 	}
 
-	duration := 10 * time.Second
-	// wait forever for success and retry every duration interval
-	wait.PollInfinite(duration, func() (bool, error) {
-		_, err := c.client.Create(csr)
-		if err != nil {
-			glog.Errorf("error sending CSR to signer: %v", err)
-			return false, nil
+	defaultRetryInterval := 10 * time.Second
+	// retry every defaultRetryInterval unless the signer tells us to back
+	// off longer (e.g. it is rate limiting us), giving up after MaxRetry
+	// retries unless MaxRetry is 0, in which case we retry forever.
+	attempts := 0
+	for {
+		if _, err := c.client.Create(csr); err == nil {
+			break
+		} else {
+			attempts++
+			if c.config.MaxRetry > 0 && attempts > c.config.MaxRetry {
+				return fmt.Errorf("error sending CSR to signer after %d attempts: %v", attempts, err)
+			}
+			retryInterval := defaultRetryInterval
+			if delay, ok := apierrors.SuggestsClientDelay(err); ok {
+				retryInterval = time.Duration(delay) * time.Second
+			}
+			glog.Errorf("error sending CSR to signer: %v. retrying in %s", err, retryInterval)
+			time.Sleep(retryInterval)
 		}
-		return true, nil
-	})
+	}
 
 	rcvdCSR, err := c.WaitForCertificate()
 	if err != nil {
@@ -139,7 +221,7 @@ func (c *CertAgent) RequestCertificate() error {
 
 	// write out signed certificate to disk
 	certFile := path.Join(c.config.AssetsDir, c.config.CommonName+".crt")
-	if err := ioutil.WriteFile(certFile, rcvdCSR.Status.Certificate, 0644); err != nil {
+	if err := util.WriteFileAtomic(certFile, rcvdCSR.Status.Certificate, 0644); err != nil {
 		return fmt.Errorf("unable to write to %s: %v", certFile, err)
 	}
 	return nil
@@ -75,6 +75,10 @@ func TestGenerateCSRObject(t *testing.T) {
 		t.Errorf("IPAddress mismatch. Wanted %v, got %v", cConfig.IPAddresses[0], csr.IPAddresses[0])
 	}
 
+	if len(csr.URIs) != 0 {
+		t.Errorf("expected 0 URI SANs in the result, got %d", len(csr.URIs))
+	}
+
 	keyFile := path.Join(cConfig.AssetsDir, cConfig.CommonName+".key")
 	if _, err := os.Stat(keyFile); err == nil {
 		if err := os.Remove(keyFile); err != nil {
@@ -84,6 +88,42 @@ func TestGenerateCSRObject(t *testing.T) {
 	}
 }
 
+func TestGenerateCSRObjectWithURIs(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+
+	config := CSRConfig{
+		OrgName:    "system:etcd-peers",
+		CommonName: "system:etcd-peer:uri-test",
+		URIs:       []string{"spiffe://cluster.local/etcd/peer/uri-test"},
+		AssetsDir:  wd,
+	}
+	defer os.Remove(path.Join(wd, config.CommonName+".key"))
+
+	generatedCSR, err := GenerateCSRObject(config)
+	if err != nil {
+		t.Fatalf("error generating CSR object: %v", err)
+	}
+
+	csrBlock, _ := pem.Decode(generatedCSR.Spec.Request)
+	if csrBlock == nil {
+		t.Fatal("error decoding certificate request generated.")
+	}
+	csr, err := x509.ParseCertificateRequest(csrBlock.Bytes)
+	if err != nil {
+		t.Fatalf("error parsing certificate request: %v", err)
+	}
+
+	if len(csr.URIs) != 1 {
+		t.Fatalf("expected 1 URI SAN in the result, got %d", len(csr.URIs))
+	}
+	if got := csr.URIs[0].String(); got != config.URIs[0] {
+		t.Errorf("URI SAN mismatch. Wanted %v, got %v", config.URIs[0], got)
+	}
+}
+
 func TestUnescapeIPV6Address(t *testing.T) {
 	for _, test := range []struct {
 		ip   string
@@ -0,0 +1,14 @@
+package certagent
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	certRenewalTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cert_renewal_total",
+		Help: "Total number of certificate renewal attempts by the agent's renewal loop, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(certRenewalTotal)
+}
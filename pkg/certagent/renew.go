@@ -0,0 +1,199 @@
+package certagent
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"time"
+
+	"github.com/golang/glog"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+// renewalJitterFraction caps how much early a scheduled renewal may fire,
+// as a fraction of the computed delay, so a fleet of identically
+// configured agents doesn't all renew at the same instant.
+const renewalJitterFraction = 0.1
+
+const (
+	// rotationFractionMin and rotationFractionMax bound the fraction of a
+	// certificate's NotBefore..NotAfter lifetime Start lets elapse before
+	// rotating it, modeled on client-go's certificate.Manager, which jitters
+	// over 70-90% of lifetime; this agent uses the narrower 70-80% window.
+	// Jittering per agent instead of renewing at a single fixed fraction
+	// keeps a fleet of identically configured agents from all rotating, and
+	// hammering the signer, at once.
+	rotationFractionMin = 0.70
+	rotationFractionMax = 0.80
+
+	// initialRotationBackoff and maxRotationBackoff bound Start's retry
+	// delay after a failed rotation attempt, doubling on each consecutive
+	// failure so a signer outage doesn't turn into a tight retry loop.
+	initialRotationBackoff = 10 * time.Second
+	maxRotationBackoff     = 5 * time.Minute
+)
+
+// ReloadFunc signals a renewed certificate's consumer (e.g. restarting a
+// process or sending it SIGHUP) to pick up the refreshed files.
+type ReloadFunc func() error
+
+// RunRenewalLoop requests a certificate via RequestCertificate, then wakes
+// up at renewBefore of the issued certificate's NotAfter (jittered) and
+// requests a fresh one, repeating forever. reload, if non-nil, runs after
+// every renewal but not the initial request. It only returns on an
+// unrecoverable error, such as the renewed certificate never landing on
+// disk.
+func (c *CertAgent) RunRenewalLoop(renewBefore time.Duration, reload ReloadFunc) error {
+	if err := c.RequestCertificate(); err != nil {
+		certRenewalTotal.WithLabelValues("error").Inc()
+		return fmt.Errorf("error requesting initial certificate: %v", err)
+	}
+	certRenewalTotal.WithLabelValues("success").Inc()
+
+	for {
+		delay, err := c.nextRenewalDelay(renewBefore)
+		if err != nil {
+			return fmt.Errorf("error scheduling next renewal: %v", err)
+		}
+		glog.Infof("certificate %s renews in %s", c.config.CommonName, delay)
+		time.Sleep(delay)
+
+		if err := c.RequestCertificate(); err != nil {
+			certRenewalTotal.WithLabelValues("error").Inc()
+			glog.Errorf("error renewing certificate %s: %v. will retry at the next scheduled renewal", c.config.CommonName, err)
+			continue
+		}
+		certRenewalTotal.WithLabelValues("success").Inc()
+
+		if reload == nil {
+			continue
+		}
+		if err := reload(); err != nil {
+			glog.Errorf("error signaling reload after renewing certificate %s: %v", c.config.CommonName, err)
+		}
+	}
+}
+
+// loadCert parses the certificate this agent last wrote to config.AssetsDir.
+func (c *CertAgent) loadCert() (*x509.Certificate, error) {
+	certFile := path.Join(c.config.AssetsDir, c.config.CommonName+".crt")
+	certBytes, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate file %s: %v", certFile, err)
+	}
+	certs, err := certutil.ParseCertsPEM(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate file %s: %v", certFile, err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", certFile)
+	}
+	return certs[0], nil
+}
+
+// nextRenewalDelay returns how long to sleep before renewing the
+// certificate this agent last wrote to config.AssetsDir, jittered by up to
+// renewalJitterFraction of the interval.
+func (c *CertAgent) nextRenewalDelay(renewBefore time.Duration) (time.Duration, error) {
+	cert, err := c.loadCert()
+	if err != nil {
+		return 0, err
+	}
+
+	delay := time.Until(cert.NotAfter.Add(-renewBefore))
+	if delay < 0 {
+		delay = 0
+	}
+
+	if jitter := time.Duration(float64(delay) * renewalJitterFraction); jitter > 0 {
+		delay -= time.Duration(rand.Int63n(int64(jitter)))
+	}
+	return delay, nil
+}
+
+// nextRotationDelay returns how long to sleep before rotating the
+// certificate this agent last wrote to config.AssetsDir, modeled on
+// client-go's certificate.Manager: rather than renewing a fixed interval
+// before NotAfter, it picks a point between rotationFractionMin and
+// rotationFractionMax of the way through the certificate's
+// NotBefore..NotAfter lifetime.
+func (c *CertAgent) nextRotationDelay() (time.Duration, error) {
+	cert, err := c.loadCert()
+	if err != nil {
+		return 0, err
+	}
+
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	fraction := rotationFractionMin + rand.Float64()*(rotationFractionMax-rotationFractionMin)
+	rotateAt := cert.NotBefore.Add(time.Duration(float64(lifetime) * fraction))
+
+	delay := time.Until(rotateAt)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, nil
+}
+
+// Start requests an initial certificate via RequestCertificate, then runs a
+// kubelet-style rotation manager that wakes up at a jittered fraction of
+// the issued certificate's lifetime (see nextRotationDelay), generates a
+// fresh private key and CSR, and atomically swaps in the newly signed
+// certificate, repeating until ctx is done. reload, if non-nil, runs after
+// every successful rotation but not the initial request, so callers such
+// as an etcd wrapper can pick up the refreshed files without restarting.
+//
+// A failed rotation attempt is retried with exponential backoff, up to
+// maxRotationBackoff, instead of waiting for the next scheduled rotation,
+// so a transient signer outage doesn't leave a certificate un-rotated for
+// an entire lifetime.
+func (c *CertAgent) Start(ctx context.Context, reload ReloadFunc) error {
+	if err := c.RequestCertificate(); err != nil {
+		certRenewalTotal.WithLabelValues("error").Inc()
+		return fmt.Errorf("error requesting initial certificate: %v", err)
+	}
+	certRenewalTotal.WithLabelValues("success").Inc()
+
+	for {
+		delay, err := c.nextRotationDelay()
+		if err != nil {
+			return fmt.Errorf("error scheduling next rotation: %v", err)
+		}
+		glog.Infof("certificate %s rotates in %s", c.config.CommonName, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		backoff := initialRotationBackoff
+		for {
+			err := c.RequestCertificate()
+			if err == nil {
+				certRenewalTotal.WithLabelValues("success").Inc()
+				break
+			}
+			certRenewalTotal.WithLabelValues("error").Inc()
+			glog.Errorf("error rotating certificate %s: %v. retrying in %s", c.config.CommonName, err, backoff)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxRotationBackoff {
+				backoff = maxRotationBackoff
+			}
+		}
+
+		if reload == nil {
+			continue
+		}
+		if err := reload(); err != nil {
+			glog.Errorf("error signaling reload after rotating certificate %s: %v", c.config.CommonName, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package certagent
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate with the given NotAfter
+// to <dir>/<commonName>.crt and returns its path.
+func writeTestCert(t *testing.T, dir, commonName string, notAfter time.Time) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	certFile := path.Join(dir, commonName+".crt")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+	return certFile
+}
+
+func TestNextRenewalDelay(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+
+	commonName := "system:etcd-peer:renew-test"
+	notAfter := time.Now().Add(2 * time.Hour)
+	certFile := writeTestCert(t, wd, commonName, notAfter)
+	defer os.Remove(certFile)
+
+	a := &CertAgent{config: CSRConfig{AssetsDir: wd, CommonName: commonName}}
+
+	delay, err := a.nextRenewalDelay(time.Hour)
+	if err != nil {
+		t.Fatalf("nextRenewalDelay returned error: %v", err)
+	}
+
+	// renewBefore (1h) of a 2h-out cert should schedule a renewal roughly
+	// an hour from now, give or take jitter.
+	if delay <= 0 || delay > time.Hour {
+		t.Errorf("delay = %s, want a positive value no greater than 1h", delay)
+	}
+}
+
+func TestNextRotationDelay(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+
+	commonName := "system:etcd-peer:rotate-test"
+	notAfter := time.Now().Add(10 * time.Hour)
+	certFile := writeTestCert(t, wd, commonName, notAfter)
+	defer os.Remove(certFile)
+
+	a := &CertAgent{config: CSRConfig{AssetsDir: wd, CommonName: commonName}}
+
+	// writeTestCert sets NotBefore an hour in the past, so the 11h
+	// lifetime's 70-80% mark falls 7.7h-8.8h from NotBefore, i.e.
+	// 6.7h-7.8h from now.
+	delay, err := a.nextRotationDelay()
+	if err != nil {
+		t.Fatalf("nextRotationDelay returned error: %v", err)
+	}
+	if delay < 6*time.Hour || delay > 8*time.Hour {
+		t.Errorf("delay = %s, want a value between 6h and 8h", delay)
+	}
+}
+
+func TestNextRotationDelayPastDue(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+
+	commonName := "system:etcd-peer:rotate-test-pastdue"
+	notAfter := time.Now().Add(time.Minute)
+	certFile := writeTestCert(t, wd, commonName, notAfter)
+	defer os.Remove(certFile)
+
+	a := &CertAgent{config: CSRConfig{AssetsDir: wd, CommonName: commonName}}
+
+	delay, err := a.nextRotationDelay()
+	if err != nil {
+		t.Fatalf("nextRotationDelay returned error: %v", err)
+	}
+	if delay != 0 {
+		t.Errorf("delay = %s, want 0 for a certificate already past its rotation point", delay)
+	}
+}
+
+func TestNextRenewalDelayPastDue(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+
+	commonName := "system:etcd-peer:renew-test-pastdue"
+	notAfter := time.Now().Add(time.Minute)
+	certFile := writeTestCert(t, wd, commonName, notAfter)
+	defer os.Remove(certFile)
+
+	a := &CertAgent{config: CSRConfig{AssetsDir: wd, CommonName: commonName}}
+
+	delay, err := a.nextRenewalDelay(time.Hour)
+	if err != nil {
+		t.Fatalf("nextRenewalDelay returned error: %v", err)
+	}
+	if delay != 0 {
+		t.Errorf("delay = %s, want 0 for a certificate already within renewBefore", delay)
+	}
+}
@@ -0,0 +1,84 @@
+// Package certcheck inspects PEM certificate files on disk and reports
+// their subject, issuer, validity window, and days remaining. It backs the
+// `check` subcommands on the signer and agent binaries, and the signer's
+// cert_valid_seconds_remaining metrics, so both share one notion of what
+// "days remaining" means.
+package certcheck
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// CertInfo summarizes the fields of a single inspected certificate.
+type CertInfo struct {
+	// Path is the file the certificate was read from.
+	Path      string
+	Subject   string
+	Issuer    string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// DaysRemaining returns the whole number of days left until the
+// certificate expires, measured from now and truncated toward zero: it is
+// 0 during the last day before expiry and the first day after it, and
+// negative only once the certificate has been expired for a full day.
+func (ci CertInfo) DaysRemaining() int {
+	return int(time.Until(ci.NotAfter).Hours() / 24)
+}
+
+// Inspect reads and parses the PEM certificate at path and returns a
+// CertInfo describing it.
+func Inspect(path string) (*CertInfo, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate file %q: %v", path, err)
+	}
+	cert, err := helpers.ParseCertificatePEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate file %q: %v", path, err)
+	}
+	return infoFromCert(path, cert), nil
+}
+
+func infoFromCert(path string, cert *x509.Certificate) *CertInfo {
+	return &CertInfo{
+		Path:      path,
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+}
+
+// WriteReport renders infos as a table of path, subject, issuer, validity
+// window, and days remaining, one row per certificate, in the order given.
+func WriteReport(w io.Writer, infos []*CertInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tSUBJECT\tISSUER\tNOT BEFORE\tNOT AFTER\tDAYS REMAINING")
+	for _, ci := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n",
+			ci.Path, ci.Subject, ci.Issuer,
+			ci.NotBefore.Format(time.RFC3339), ci.NotAfter.Format(time.RFC3339), ci.DaysRemaining())
+	}
+	return tw.Flush()
+}
+
+// Expiring returns the subset of infos that have less than warn remaining
+// before they expire.
+func Expiring(infos []*CertInfo, warn time.Duration) []*CertInfo {
+	var expiring []*CertInfo
+	for _, ci := range infos {
+		if time.Until(ci.NotAfter) < warn {
+			expiring = append(expiring, ci)
+		}
+	}
+	return expiring
+}
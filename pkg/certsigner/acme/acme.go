@@ -0,0 +1,179 @@
+// Package acme implements an alternative signer backend that obtains
+// certificates from an external ACME (RFC 8555) CA -- e.g. Let's Encrypt,
+// Smallstep's step-ca, or Boulder -- instead of signing locally with an
+// on-disk CA cert/key pair.
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// Solver completes an ACME challenge for the given domain and tears down
+// whatever it provisioned once the challenge has been validated. Concrete
+// implementations correspond to ACME challenge types (http-01, dns-01, ...).
+type Solver interface {
+	Present(domain, token, keyAuth string) error
+	CleanUp(domain, token, keyAuth string) error
+}
+
+// Config configures an ACME-backed Issuer.
+type Config struct {
+	// DirectoryURL is the ACME server's directory endpoint, e.g.
+	// https://acme-v02.api.letsencrypt.org/directory, or a step-ca/Boulder URL.
+	DirectoryURL string
+	// Email is the contact address submitted during account registration.
+	Email string
+	// AccountKeyFile caches the ACME account private key across restarts so
+	// the signer registers a new account at most once.
+	AccountKeyFile string
+	// ChallengeType selects which challenge Solver satisfies. Only "http-01"
+	// is currently wired up to a built-in Solver (see HTTPSolver).
+	ChallengeType string
+	// Timeout bounds how long a single certificate issuance is allowed to take.
+	Timeout time.Duration
+}
+
+// Issuer signs CSRs by running the ACME protocol against an external CA.
+type Issuer struct {
+	client *acme.Client
+	cfg    Config
+	solver Solver
+}
+
+// NewIssuer loads or creates the ACME account key at cfg.AccountKeyFile,
+// registers an account with the directory at cfg.DirectoryURL if one does
+// not already exist, and returns an Issuer that uses solver to complete
+// challenges.
+func NewIssuer(cfg Config, solver Solver) (*Issuer, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+
+	key, err := loadOrCreateAccountKey(cfg.AccountKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading ACME account key: %v", err)
+	}
+
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: cfg.DirectoryURL,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if _, err := client.Register(ctx, &acme.Account{Contact: []string{"mailto:" + cfg.Email}}, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, fmt.Errorf("error registering ACME account with %q: %v", cfg.DirectoryURL, err)
+	}
+
+	return &Issuer{client: client, cfg: cfg, solver: solver}, nil
+}
+
+func loadOrCreateAccountKey(path string) (*ecdsa.PrivateKey, error) {
+	if data, err := ioutil.ReadFile(path); err == nil {
+		key, err := x509.ParseECPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing cached ACME account key %q: %v", path, err)
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating ACME account key: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, der, 0600); err != nil {
+		return nil, fmt.Errorf("error persisting ACME account key to %q: %v", path, err)
+	}
+	return key, nil
+}
+
+// IssueCertificate runs the ACME new-order/authorize/finalize flow for the
+// given domains against csrDER (a DER-encoded CSR), returning the issued
+// certificate chain, leaf first, as DER-encoded blocks.
+func (i *Issuer) IssueCertificate(domains []string, csrDER []byte) ([][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), i.cfg.Timeout)
+	defer cancel()
+
+	authzIDs := make([]acme.AuthzID, len(domains))
+	for idx, d := range domains {
+		authzIDs[idx] = acme.AuthzID{Type: "dns", Value: d}
+	}
+
+	order, err := i.client.AuthorizeOrder(ctx, authzIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error creating ACME order: %v", err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		if err := i.authorize(ctx, authzURL); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err = i.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for ACME order to become ready: %v", err)
+	}
+
+	chain, _, err := i.client.CreateOrderCert(ctx, order.FinalizeURL, csrDER, true)
+	if err != nil {
+		return nil, fmt.Errorf("error finalizing ACME order: %v", err)
+	}
+	return chain, nil
+}
+
+// authorize drives a single pending authorization through its challenge.
+func (i *Issuer) authorize(ctx context.Context, authzURL string) error {
+	authz, err := i.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("error fetching ACME authorization: %v", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == i.cfg.ChallengeType {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("no %s challenge offered for %s", i.cfg.ChallengeType, authz.Identifier.Value)
+	}
+
+	keyAuth, err := i.client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return fmt.Errorf("error computing challenge key authorization: %v", err)
+	}
+
+	if err := i.solver.Present(authz.Identifier.Value, chal.Token, keyAuth); err != nil {
+		return fmt.Errorf("error presenting %s challenge for %s: %v", i.cfg.ChallengeType, authz.Identifier.Value, err)
+	}
+	defer i.solver.CleanUp(authz.Identifier.Value, chal.Token, keyAuth)
+
+	if _, err := i.client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("error accepting ACME challenge for %s: %v", authz.Identifier.Value, err)
+	}
+	if _, err := i.client.WaitAuthorization(ctx, authzURL); err != nil {
+		return fmt.Errorf("error waiting for ACME authorization of %s: %v", authz.Identifier.Value, err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package acme
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// HTTPSolver satisfies http-01 challenges by serving the expected key
+// authorization under /.well-known/acme-challenge/{token} on the signer's
+// own request router, so no separate listener is required.
+type HTTPSolver struct {
+	mu       sync.Mutex
+	keyAuths map[string]string
+}
+
+// NewHTTPSolver registers the challenge-serving route on r and returns the
+// Solver to hand to NewIssuer.
+func NewHTTPSolver(r *mux.Router) *HTTPSolver {
+	s := &HTTPSolver{keyAuths: map[string]string{}}
+	r.HandleFunc("/.well-known/acme-challenge/{token}", s.serveChallenge).Methods("GET")
+	return s
+}
+
+func (s *HTTPSolver) serveChallenge(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+
+	s.mu.Lock()
+	keyAuth, ok := s.keyAuths[token]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
+
+// Present records keyAuth so it is served back for token.
+func (s *HTTPSolver) Present(domain, token, keyAuth string) error {
+	s.mu.Lock()
+	s.keyAuths[token] = keyAuth
+	s.mu.Unlock()
+	return nil
+}
+
+// CleanUp removes the previously recorded keyAuth for token.
+func (s *HTTPSolver) CleanUp(domain, token, keyAuth string) error {
+	s.mu.Lock()
+	delete(s.keyAuths, token)
+	s.mu.Unlock()
+	return nil
+}
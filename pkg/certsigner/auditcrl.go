@@ -0,0 +1,119 @@
+package certsigner
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/kubecsr/pkg/certsigner/revoke"
+	"github.com/coreos/kubecsr/pkg/certsigner/store"
+)
+
+// defaultCRLValidity is how long a CRL produced by GenerateCRL is valid for
+// before a consumer should expect a fresher one.
+const defaultCRLValidity = time.Hour
+
+// LoadRevokedSerialsFile reads an admin-maintained list of revoked serials,
+// one per line, formatted "<hex serial>[ <RFC3339 revokedAt>]". A serial
+// with no timestamp is recorded as revoked now. Blank lines and lines
+// starting with "#" are ignored. This is the complement to the signer's
+// own audit log: an operator can revoke a certificate the signer never
+// recorded (e.g. one issued before the audit log existed) just by adding
+// a line here.
+func LoadRevokedSerialsFile(path string) (map[string]time.Time, error) {
+	revoked := map[string]time.Time{}
+	if path == "" {
+		return revoked, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return revoked, nil
+		}
+		return nil, fmt.Errorf("error opening revoked-serials file %q: %v", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		serial := fields[0]
+		revokedAt := time.Now()
+		if len(fields) > 1 {
+			revokedAt, err = time.Parse(time.RFC3339, fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("error parsing revokedAt timestamp %q for serial %q: %v", fields[1], serial, err)
+			}
+		}
+		revoked[serial] = revokedAt
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading revoked-serials file %q: %v", path, err)
+	}
+	return revoked, nil
+}
+
+// GenerateCRL builds a DER-encoded CRL for ca from the union of:
+//   - records in the signer's own audit trail (e.g. a store.FileStore) that
+//     are Revoked and belong to one of ca's Profiles, and
+//   - serials listed in adminRevoked (from LoadRevokedSerialsFile), which
+//     are applied to every CA's CRL since the admin file has no notion of
+//     which CA issued a given serial.
+func GenerateCRL(ca revoke.CA, records []store.Record, adminRevoked map[string]time.Time) ([]byte, error) {
+	var entries []pkix.RevokedCertificate
+	seen := map[string]bool{}
+
+	addSerial := func(hexSerial string, revokedAt time.Time) error {
+		if seen[hexSerial] {
+			return nil
+		}
+		serial, ok := new(big.Int).SetString(hexSerial, 16)
+		if !ok {
+			return fmt.Errorf("unparsable serial %q", hexSerial)
+		}
+		seen[hexSerial] = true
+		entries = append(entries, pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: revokedAt,
+		})
+		return nil
+	}
+
+	for _, r := range records {
+		if !r.Revoked {
+			continue
+		}
+		if !profileCoveredByCA(ca, r.Profile) {
+			continue
+		}
+		if err := addSerial(r.Serial, r.RevokedAt); err != nil {
+			return nil, fmt.Errorf("error adding audit-log serial to CRL: %v", err)
+		}
+	}
+	for serial, revokedAt := range adminRevoked {
+		if err := addSerial(serial, revokedAt); err != nil {
+			return nil, fmt.Errorf("error adding admin-revoked serial to CRL: %v", err)
+		}
+	}
+
+	return ca.Cert.CreateCRL(rand.Reader, ca.Key, entries, time.Now(), time.Now().Add(defaultCRLValidity))
+}
+
+func profileCoveredByCA(ca revoke.CA, profile string) bool {
+	for _, p := range ca.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
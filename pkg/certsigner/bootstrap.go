@@ -0,0 +1,97 @@
+package certsigner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/initca"
+	"github.com/golang/glog"
+)
+
+// defaultSelfInitKeyAlgo and defaultSelfInitKeySize are used when
+// SignerCAFiles.SelfInitKeyAlgo/SelfInitKeySize are unset.
+const (
+	defaultSelfInitKeyAlgo = "rsa"
+	defaultSelfInitKeySize = 2048
+	// selfInitExpiry is the validity period of a self-initialized CA.
+	selfInitExpiry = "87600h"
+)
+
+// selfInitCAs mints the root and/or metric CA pair described by sc, for
+// whichever of the two pairs is both configured and entirely absent from
+// disk. It never touches a pair with only one of its two files present, or a
+// pair whose private key is sourced from an HSM.
+func selfInitCAs(sc *SignerCAFiles) error {
+	if sc.CACert != "" && sc.CAKey != "" && sc.CAHSM == nil {
+		if err := selfInitCA(sc.CACert, sc.CAKey, sc.SelfInitCommonName, sc); err != nil {
+			return fmt.Errorf("root CA: %v", err)
+		}
+	}
+	if sc.MetricCACert != "" && sc.MetricCAKey != "" && sc.MetricCAHSM == nil {
+		cn := sc.SelfInitCommonName + " Metrics CA"
+		if err := selfInitCA(sc.MetricCACert, sc.MetricCAKey, cn, sc); err != nil {
+			return fmt.Errorf("metric CA: %v", err)
+		}
+	}
+	return nil
+}
+
+// selfInitCA generates a fresh self-signed CA cert+key at certFile/keyFile,
+// with 0600 permissions, unless either file already exists.
+func selfInitCA(certFile, keyFile, cn string, sc *SignerCAFiles) error {
+	certExists := fileExists(certFile)
+	keyExists := fileExists(keyFile)
+	if certExists || keyExists {
+		// Partial or already-present state: leave it for the normal load
+		// path to use, or fail on, as before SelfInit existed.
+		return nil
+	}
+
+	keyAlgo := sc.SelfInitKeyAlgo
+	if keyAlgo == "" {
+		keyAlgo = defaultSelfInitKeyAlgo
+	}
+	keySize := sc.SelfInitKeySize
+	if keySize == 0 {
+		keySize = defaultSelfInitKeySize
+	}
+
+	req := &csr.CertificateRequest{
+		CN:         cn,
+		KeyRequest: &csr.BasicKeyRequest{A: keyAlgo, S: keySize},
+		CA:         &csr.CAConfig{Expiry: selfInitExpiry},
+	}
+	if sc.SelfInitOrganization != "" {
+		req.Names = []csr.Name{{O: sc.SelfInitOrganization}}
+	}
+
+	certPEM, _, keyPEM, err := initca.New(req)
+	if err != nil {
+		return fmt.Errorf("error generating CA %q: %v", cn, err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("error writing CA key %q: %v", keyFile, err)
+	}
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		return fmt.Errorf("error writing CA cert %q: %v", certFile, err)
+	}
+
+	cert, err := helpers.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return fmt.Errorf("error parsing newly-minted CA %q: %v", cn, err)
+	}
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	glog.Infof("self-initialized CA %q at %s / %s, SPKI hash sha256:%s", cn, certFile, keyFile, hex.EncodeToString(spki[:]))
+
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
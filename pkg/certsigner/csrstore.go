@@ -0,0 +1,111 @@
+package certsigner
+
+import (
+	"sync"
+	"time"
+
+	capi "k8s.io/api/certificates/v1beta1"
+
+	"github.com/coreos/kubecsr/pkg/util/timedcache"
+)
+
+// CSRStore persists signed CSRs between HandlePostCSR writing one and an
+// agent retrieving it, either by a direct GET (HandleGetCSR/HandleGetCSRV1)
+// or by watching for it (HandleWatchCSR). It is a lighter-weight,
+// replica-local alternative to the csrDir disk spool the signer already
+// uses -- it needs no shared disk between replicas, and entries expire
+// instead of accumulating forever.
+//
+// NewMemoryCSRStore is the only implementation in this repo today. A
+// BoltDB- or etcd-backed CSRStore (durable across a signer restart, or
+// shared between replicas without a disk volume) is a natural follow-up
+// but is not implemented here.
+type CSRStore interface {
+	// Put records a newly signed CSR, making it visible to Get and to any
+	// in-flight or future Watch call for its name.
+	Put(csr *capi.CertificateSigningRequest)
+	// Get returns the signed CSR previously recorded under name, if any
+	// and not yet expired.
+	Get(name string) (*capi.CertificateSigningRequest, bool)
+	// Watch returns a channel that receives name's signed CSR as soon as
+	// it's Put -- immediately, over a pre-filled channel, if it already
+	// has been -- and a cancel func the caller must call once done with
+	// the subscription, whether or not it ever fired.
+	Watch(name string) (<-chan *capi.CertificateSigningRequest, func())
+}
+
+// defaultCSRStoreTTL bounds how long a memoryCSRStore holds a signed CSR,
+// on the same reasoning as defaultCSRDedupeTTL: an agent that hasn't
+// fetched its certificate within a few minutes of it being signed almost
+// certainly isn't coming back for it.
+const defaultCSRStoreTTL = 5 * time.Minute
+
+// memoryCSRStore is the CSRStore returned by NewMemoryCSRStore: signed CSRs
+// are kept in a timedcache.Cache, and Watch subscribers are tracked
+// separately so Put can wake them up without polling.
+type memoryCSRStore struct {
+	cache *timedcache.Cache
+
+	mu   sync.Mutex
+	subs map[string][]chan *capi.CertificateSigningRequest
+}
+
+// NewMemoryCSRStore returns a CSRStore that holds signed CSRs in memory for
+// ttl (defaultCSRStoreTTL if ttl is non-positive).
+func NewMemoryCSRStore(ttl time.Duration) CSRStore {
+	if ttl <= 0 {
+		ttl = defaultCSRStoreTTL
+	}
+	return &memoryCSRStore{
+		cache: timedcache.New(ttl),
+		subs:  map[string][]chan *capi.CertificateSigningRequest{},
+	}
+}
+
+func (m *memoryCSRStore) Put(csr *capi.CertificateSigningRequest) {
+	m.mu.Lock()
+	m.cache.Set(csr.ObjectMeta.Name, csr)
+	subs := m.subs[csr.ObjectMeta.Name]
+	delete(m.subs, csr.ObjectMeta.Name)
+	m.mu.Unlock()
+
+	for _, ch := range subs {
+		ch <- csr
+		close(ch)
+	}
+}
+
+func (m *memoryCSRStore) Get(name string) (*capi.CertificateSigningRequest, bool) {
+	v, ok := m.cache.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*capi.CertificateSigningRequest), true
+}
+
+func (m *memoryCSRStore) Watch(name string) (<-chan *capi.CertificateSigningRequest, func()) {
+	ch := make(chan *capi.CertificateSigningRequest, 1)
+
+	m.mu.Lock()
+	if csr, ok := m.cache.Get(name); ok {
+		m.mu.Unlock()
+		ch <- csr.(*capi.CertificateSigningRequest)
+		close(ch)
+		return ch, func() {}
+	}
+	m.subs[name] = append(m.subs[name], ch)
+	m.mu.Unlock()
+
+	cancel := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		subs := m.subs[name]
+		for i, c := range subs {
+			if c == ch {
+				m.subs[name] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+	return ch, cancel
+}
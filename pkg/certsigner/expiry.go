@@ -0,0 +1,64 @@
+package certsigner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/coreos/kubecsr/pkg/certcheck"
+)
+
+// certExpiryScanInterval is how often startCertExpiryMonitor re-inspects
+// the signer's own certificates.
+const certExpiryScanInterval = 1 * time.Hour
+
+// certExpiryWarning is how close to expiry one of the signer's own
+// certificates must be before startCertExpiryMonitor logs a warning; it
+// matches the --warn default on the `check` CLI subcommands.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// startCertExpiryMonitor periodically re-inspects the root CA, metric CA,
+// and every configured server certificate, and republishes each one's
+// remaining validity as cert_valid_seconds_remaining, labeled by subject
+// and issuer. It never returns.
+//
+// CertServer fronts a bespoke HTTP CSR protocol rather than a real
+// Kubernetes apiserver, and it has no client of its own, so there is no
+// CSR object here to attach a Kubernetes Event to as these certificates
+// near expiry; a certificate within the warning window is logged at
+// glog.Warning level instead.
+func startCertExpiryMonitor(sc *SignerCAFiles, serverCerts []CertKey) {
+	for {
+		checkCertExpiry(sc, serverCerts)
+		time.Sleep(certExpiryScanInterval)
+	}
+}
+
+// checkCertExpiry runs a single expiry scan over sc and serverCerts.
+func checkCertExpiry(sc *SignerCAFiles, serverCerts []CertKey) {
+	files := map[string]string{}
+	if sc.CACert != "" {
+		files["ca"] = sc.CACert
+	}
+	if sc.MetricCACert != "" {
+		files["metric-ca"] = sc.MetricCACert
+	}
+	for idx, pair := range serverCerts {
+		files[fmt.Sprintf("servcrt-%d", idx)] = pair.CertFile
+	}
+
+	for name, path := range files {
+		info, err := certcheck.Inspect(path)
+		if err != nil {
+			glog.Errorf("error inspecting certificate %q for expiry metrics: %v", path, err)
+			continue
+		}
+
+		remaining := time.Until(info.NotAfter)
+		certValidSecondsRemaining.WithLabelValues(info.Subject, info.Issuer).Set(remaining.Seconds())
+		if remaining < certExpiryWarning {
+			glog.Warningf("certificate %q (subject=%q) has %s remaining before it expires, within the %s warning window", name, info.Subject, remaining.Round(time.Hour), certExpiryWarning)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Package hsm wraps github.com/letsencrypt/pkcs11key so the certsigner CA
+// signing keys can live in a PKCS#11-backed HSM instead of on disk.
+package hsm
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/letsencrypt/pkcs11key/v4"
+)
+
+// Config describes how to reach a single private key held in an HSM.
+type Config struct {
+	// Module is the path to the PKCS#11 module (.so) to load.
+	Module string `json:"module"`
+	// TokenLabel identifies the token (slot) holding the key.
+	TokenLabel string `json:"tokenLabel"`
+	// PIN authenticates to the token.
+	PIN string `json:"pin"`
+	// PrivateKeyLabel is the CKA_LABEL of the private key object to sign
+	// with.
+	PrivateKeyLabel string `json:"privateKeyLabel"`
+	// AllowPEMFallback lets newSignerCA fall back to the PEM key file on
+	// disk if HSM initialization fails, so a misconfigured or unreachable
+	// HSM doesn't take the signer down entirely. Operators should only set
+	// this while migrating a CA onto an HSM.
+	AllowPEMFallback bool `json:"allowPEMFallback"`
+}
+
+// NewSigner opens the token described by c and returns a crypto.Signer
+// backed by the private key object named c.PrivateKeyLabel.
+func NewSigner(c Config) (crypto.Signer, error) {
+	if c.Module == "" || c.TokenLabel == "" || c.PrivateKeyLabel == "" {
+		return nil, fmt.Errorf("hsm: module, tokenLabel and privateKeyLabel are required")
+	}
+
+	key, err := pkcs11key.New(pkcs11key.Config{
+		Module:          c.Module,
+		TokenLabel:      c.TokenLabel,
+		PIN:             c.PIN,
+		PrivateKeyLabel: c.PrivateKeyLabel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hsm: error initializing PKCS#11 key %q on token %q: %v", c.PrivateKeyLabel, c.TokenLabel, err)
+	}
+	return key, nil
+}
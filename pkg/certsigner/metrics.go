@@ -0,0 +1,40 @@
+package certsigner
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	csrSignedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csr_signed_total",
+		Help: "Total number of CSRs successfully signed, by profile.",
+	}, []string{"profile"})
+
+	csrThrottledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csr_throttled_total",
+		Help: "Total number of CSRs rejected by the signer's rate limiter, by profile.",
+	}, []string{"profile"})
+
+	csrRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csr_rejected_total",
+		Help: "Total number of CSRs rejected by the signer, by reason.",
+	}, []string{"reason"})
+
+	certValidSecondsRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cert_valid_seconds_remaining",
+		Help: "Remaining validity, in seconds, of a certificate watched by the signer's expiry monitor, by subject and issuer.",
+	}, []string{"subject", "issuer"})
+
+	// signedCertDurationSeconds records the lifetime actually granted when
+	// a CSR requests a duration via requestDurationAnnotation (see
+	// requestedCertDuration), distinguishing short-lived bootstrapping
+	// credentials from a profile's default long-lived duration. CSRs that
+	// don't request a duration aren't observed here.
+	signedCertDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubecsr_signed_cert_duration_seconds",
+		Help:    "Distribution of the requested certificate lifetime granted to signed CSRs, by profile.",
+		Buckets: []float64{60, 300, 900, 3600, 21600, 86400, 604800, 2592000},
+	}, []string{"profile"})
+)
+
+func init() {
+	prometheus.MustRegister(csrSignedTotal, csrThrottledTotal, csrRejectedTotal, certValidSecondsRemaining, signedCertDurationSeconds)
+}
@@ -0,0 +1,91 @@
+package certsigner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// RateLimitConfig configures the signer's per-identity token-bucket throttle.
+type RateLimitConfig struct {
+	// QPS is the sustained number of signing requests allowed per identity per second.
+	QPS float64
+	// Burst is the maximum burst of signing requests allowed per identity.
+	Burst int64
+	// Quota is the hard cap of certs a single identity may obtain within Window.
+	Quota int
+	// Window is the rolling window Quota is measured over.
+	Window time.Duration
+}
+
+// identityLimiter tracks a per-(source IP, CSR CN prefix) token bucket plus a
+// rolling-window issuance quota so a misbehaving init container calling Sign
+// in a tight loop can't exhaust the signer or the backing CA.
+type identityLimiter struct {
+	mu      sync.Mutex
+	cfg     RateLimitConfig
+	buckets map[string]*ratelimit.Bucket
+	history map[string][]time.Time
+}
+
+// newIdentityLimiter returns a limiter honoring cfg. A zero-value cfg.QPS
+// disables throttling entirely.
+func newIdentityLimiter(cfg RateLimitConfig) *identityLimiter {
+	return &identityLimiter{
+		cfg:     cfg,
+		buckets: map[string]*ratelimit.Bucket{},
+		history: map[string][]time.Time{},
+	}
+}
+
+// identityKey builds the (source IP, CN prefix) key the limiter tracks.
+func identityKey(sourceIP, cn string) string {
+	prefix := cn
+	if idx := len(cn); idx > 32 {
+		prefix = cn[:32]
+	}
+	return fmt.Sprintf("%s/%s", sourceIP, prefix)
+}
+
+// Allow reports whether a signing request for the given identity should
+// proceed, and if not, how long the caller should wait before retrying.
+func (l *identityLimiter) Allow(sourceIP, cn string) (allowed bool, retryAfter time.Duration) {
+	if l.cfg.QPS <= 0 {
+		return true, 0
+	}
+
+	key := identityKey(sourceIP, cn)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = ratelimit.NewBucketWithRate(l.cfg.QPS, l.cfg.Burst)
+		l.buckets[key] = bucket
+	}
+	if wait := bucket.Take(1); wait > 0 {
+		return false, wait
+	}
+
+	if l.cfg.Quota <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-l.cfg.Window)
+	hist := l.history[key][:0]
+	for _, t := range l.history[key] {
+		if t.After(cutoff) {
+			hist = append(hist, t)
+		}
+	}
+	if len(hist) >= l.cfg.Quota {
+		l.history[key] = hist
+		return false, l.cfg.Window - now.Sub(hist[0])
+	}
+	l.history[key] = append(hist, now)
+	return true, 0
+}
@@ -0,0 +1,139 @@
+package certsigner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	capi "k8s.io/api/certificates/v1beta1"
+)
+
+// defaultPendingMaxAge bounds how long an unsignable CSR is retried before
+// the reconciler gives up on it, if Config.PendingMaxAge is unset.
+const defaultPendingMaxAge = 24 * time.Hour
+
+// recoverableErr marks an error as one that might clear up on its own --
+// an unreachable metric CA file mount, a dropped HSM session, or a remote
+// signer hiccup -- so HandlePostCSR can park the CSR for retry instead of
+// failing the agent's request outright. Mirrors swarmkit's approach to the
+// same problem in its CA rotation code.
+type recoverableErr struct {
+	err error
+}
+
+func recoverable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &recoverableErr{err: err}
+}
+
+func (e *recoverableErr) Error() string {
+	return e.err.Error()
+}
+
+func isRecoverable(err error) bool {
+	_, ok := err.(*recoverableErr)
+	return ok
+}
+
+// pendingCSR is a CSR HandlePostCSR could not sign for a recoverable
+// reason, waiting for the background reconciler to retry it.
+type pendingCSR struct {
+	csr       *capi.CertificateSigningRequest
+	firstSeen time.Time
+}
+
+// pendingQueue is an in-memory, best-effort retry list for CSRs that hit a
+// recoverable signing error. It does not survive a server restart: an
+// agent whose CSR is lost this way simply re-POSTs it.
+type pendingQueue struct {
+	mu    sync.Mutex
+	items map[string]*pendingCSR
+}
+
+func newPendingQueue() *pendingQueue {
+	return &pendingQueue{items: map[string]*pendingCSR{}}
+}
+
+// enqueue adds csr to the queue, keyed by its name, if it isn't already
+// present.
+func (q *pendingQueue) enqueue(csr *capi.CertificateSigningRequest) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.items[csr.ObjectMeta.Name]; exists {
+		return
+	}
+	q.items[csr.ObjectMeta.Name] = &pendingCSR{csr: csr, firstSeen: time.Now()}
+}
+
+// has reports whether name is currently queued.
+func (q *pendingQueue) has(name string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, found := q.items[name]
+	return found
+}
+
+func (q *pendingQueue) remove(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.items, name)
+}
+
+// snapshot returns the queued CSRs at the time of the call, safe to range
+// over without holding q's lock.
+func (q *pendingQueue) snapshot() []*pendingCSR {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make([]*pendingCSR, 0, len(q.items))
+	for _, p := range q.items {
+		out = append(out, p)
+	}
+	return out
+}
+
+// reconcilePending re-attempts signing for every CSR in s.pending. It
+// writes newly-signed CSRs to csrDir and drops entries that fail with a
+// non-recoverable error or have been queued longer than maxAge. Intended to
+// be run periodically by StartSignerServer.
+func (s *CertServer) reconcilePending(maxAge time.Duration) {
+	if maxAge <= 0 {
+		maxAge = defaultPendingMaxAge
+	}
+
+	for _, p := range s.pending.snapshot() {
+		name := p.csr.ObjectMeta.Name
+
+		if time.Since(p.firstSeen) > maxAge {
+			glog.Errorf("Dropping CSR %s from retry queue: exceeded max age %s", name, maxAge)
+			s.pending.remove(name)
+			continue
+		}
+
+		signedCSR, err := s.trySign(p.csr)
+		if err == nil {
+			s.writeCSR(signedCSR)
+			s.pending.remove(name)
+			continue
+		}
+		if !isRecoverable(err) {
+			glog.Errorf("Dropping CSR %s from retry queue: fatal signing error: %v", name, err)
+			s.pending.remove(name)
+			continue
+		}
+		glog.V(4).Infof("CSR %s still not signable, leaving it queued: %v", name, err)
+	}
+}
+
+// startPendingReconciler runs reconcilePending every interval until
+// stopped. It is started by StartSignerServer and never returns.
+func (s *CertServer) startPendingReconciler(interval, maxAge time.Duration) {
+	for range time.Tick(interval) {
+		s.reconcilePending(maxAge)
+	}
+}
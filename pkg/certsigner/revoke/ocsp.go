@@ -0,0 +1,78 @@
+package revoke
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"net/http"
+	"time"
+
+	cfocsp "github.com/cloudflare/cfssl/ocsp"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspValidity is how long an issued OCSP response is valid for before a
+// client must re-check.
+const ocspValidity = time.Hour
+
+// keyHash returns the SHA-1 hash OCSP requests use to identify an issuer,
+// over the raw bits of its public key.
+func keyHash(ca CA) []byte {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(ca.Cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil
+	}
+	h := sha1.Sum(spki.PublicKey.RightAlign())
+	return h[:]
+}
+
+func (m *Manager) caForKeyHash(hash []byte) (CA, bool) {
+	for _, ca := range m.cas {
+		if bytes.Equal(keyHash(ca), hash) {
+			return ca, true
+		}
+	}
+	return CA{}, false
+}
+
+// Response implements cfssl/ocsp.Source, answering req by looking up the
+// certificate's status in certdb and signing a response with whichever
+// loaded CA's key hash matches the request's issuer.
+func (m *Manager) Response(req *ocsp.Request) ([]byte, error) {
+	ca, found := m.caForKeyHash(req.IssuerKeyHash)
+	if !found {
+		return nil, fmt.Errorf("revoke: unrecognized OCSP issuer key hash")
+	}
+
+	status := ocsp.Good
+	var revokedAt time.Time
+
+	records, err := m.db.GetCertificate(req.SerialNumber.String(), "")
+	if err != nil {
+		return nil, fmt.Errorf("revoke: error looking up certificate %s: %v", req.SerialNumber, err)
+	}
+	if len(records) > 0 && records[0].Status == "revoked" {
+		status = ocsp.Revoked
+		revokedAt = records[0].RevokedAt
+	}
+
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   time.Now(),
+		NextUpdate:   time.Now().Add(ocspValidity),
+		RevokedAt:    revokedAt,
+	}
+	return ocsp.CreateResponse(ca.Cert, ca.Cert, template, ca.Key)
+}
+
+// Handler returns an http.Handler serving POST /ocsp requests for every CA
+// Manager knows about.
+func (m *Manager) Handler() http.Handler {
+	return cfocsp.NewResponder(m, nil)
+}
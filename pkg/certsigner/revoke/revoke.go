@@ -0,0 +1,145 @@
+// Package revoke serves CRL and OCSP revocation status for the signer's
+// root and metric CAs, built on top of the cfssl certdb audit trail.
+package revoke
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cfssl/certdb"
+	"github.com/golang/glog"
+)
+
+// defaultInterval is how often CRLs are regenerated if Config.Interval is
+// unset.
+const defaultInterval = time.Hour
+
+// CA bundles one of the signer's loaded CAs with the profiles it covers, so
+// Manager can decide which CRL a revoked certificate belongs on and match
+// OCSP requests to the right issuer.
+type CA struct {
+	// Name identifies the CA in served paths and logs ("root", "metric").
+	Name string
+	// Profiles lists the signing profiles this CA issues for (e.g.
+	// EtcdPeer and EtcdServer for "root", EtcdMetric for "metric").
+	Profiles []string
+	Cert     *x509.Certificate
+	Key      crypto.Signer
+}
+
+func (ca CA) coversProfile(profile string) bool {
+	for _, p := range ca.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager periodically regenerates a CRL per configured CA from the
+// certdb.Accessor's revoked-certificate list, and answers OCSP requests by
+// matching the request's issuer key hash against the loaded CAs.
+type Manager struct {
+	db  certdb.Accessor
+	cas []CA
+
+	mu   sync.RWMutex
+	crls map[string][]byte // CA.Name -> DER-encoded CRL
+}
+
+// NewManager returns a Manager serving CRL/OCSP for cas, backed by db.
+func NewManager(db certdb.Accessor, cas []CA) *Manager {
+	return &Manager{db: db, cas: cas, crls: map[string][]byte{}}
+}
+
+// Start regenerates every CA's CRL immediately, then every interval (or
+// defaultInterval if interval <= 0) until stop is closed. It never returns
+// until stop fires, so callers should run it in a goroutine.
+func (m *Manager) Start(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	m.Regenerate()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			m.Regenerate()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Regenerate rebuilds every CA's CRL from the current certdb revocation
+// list. It is called on Manager's own schedule, and also after a single
+// certificate is revoked so the CRL doesn't wait a full interval to reflect
+// it.
+func (m *Manager) Regenerate() {
+	revoked, err := m.db.GetRevokedAndUnexpiredCertificates()
+	if err != nil {
+		glog.Errorf("revoke: error listing revoked certificates: %v", err)
+		return
+	}
+
+	for _, ca := range m.cas {
+		var entries []pkix.RevokedCertificate
+		for _, r := range revoked {
+			if !ca.coversProfile(r.CALabel) {
+				continue
+			}
+			serial, ok := new(big.Int).SetString(r.Serial, 16)
+			if !ok {
+				glog.Errorf("revoke: skipping unparsable serial %q on CA %q CRL", r.Serial, ca.Name)
+				continue
+			}
+			entries = append(entries, pkix.RevokedCertificate{
+				SerialNumber:   serial,
+				RevocationTime: r.RevokedAt,
+			})
+		}
+
+		der, err := ca.Cert.CreateCRL(rand.Reader, ca.Key, entries, time.Now(), time.Now().Add(defaultInterval))
+		if err != nil {
+			glog.Errorf("revoke: error generating CRL for CA %q: %v", ca.Name, err)
+			continue
+		}
+
+		m.mu.Lock()
+		m.crls[ca.Name] = der
+		m.mu.Unlock()
+	}
+}
+
+// CRL returns the last-generated DER-encoded CRL for the named CA.
+func (m *Manager) CRL(name string) ([]byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	der, ok := m.crls[name]
+	return der, ok
+}
+
+// ServeCRL returns an http.HandlerFunc serving the named CA's
+// application/pkix-crl, 503 if it hasn't been generated yet.
+func (m *Manager) ServeCRL(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		der, ok := m.CRL(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("CRL for %q not yet generated", name), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(defaultInterval.Seconds())))
+		w.Write(der)
+	}
+}
@@ -2,20 +2,26 @@ package certsigner
 
 import (
 	"crypto"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cloudflare/cfssl/certdb"
 	"github.com/cloudflare/cfssl/config"
 	"github.com/cloudflare/cfssl/helpers"
 	"github.com/cloudflare/cfssl/log"
@@ -23,9 +29,30 @@ import (
 	"github.com/cloudflare/cfssl/signer/local"
 	"github.com/golang/glog"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	certv1 "k8s.io/api/certificates/v1"
 	capi "k8s.io/api/certificates/v1beta1"
 	"k8s.io/client-go/kubernetes/scheme"
 	csrutil "k8s.io/client-go/util/certificate/csr"
+
+	"github.com/coreos/kubecsr/pkg/certsigner/acme"
+	"github.com/coreos/kubecsr/pkg/certsigner/hsm"
+	"github.com/coreos/kubecsr/pkg/certsigner/revoke"
+	"github.com/coreos/kubecsr/pkg/certsigner/store"
+	"github.com/coreos/kubecsr/pkg/certsigner/vault"
+	"github.com/coreos/kubecsr/pkg/util/timedcache"
+)
+
+const (
+	// backendLocalCA signs with an on-disk CA cert/key pair via cfssl. It is
+	// the default and the only backend available before ProfileRule.Backend
+	// was introduced, so the empty string is treated as an alias for it.
+	backendLocalCA = "local-ca"
+	// backendACME signs by obtaining a certificate from an external ACME CA.
+	backendACME = "acme"
+	// backendVault signs by delegating to a HashiCorp Vault PKI secrets
+	// engine mount instead of an on-host CA or an ACME CA.
+	backendVault = "vault"
 )
 
 const (
@@ -34,6 +61,254 @@ const (
 	etcdMetric = "EtcdMetric"
 )
 
+// ProfileRule describes a single config-driven CSR routing rule. It binds a
+// CN regex and/or Organization value to a CA cert/key pair, a cert duration,
+// the key usages to grant, and the Secret name template mount-secret should
+// use for the resulting certificate. Profiles declared this way are matched
+// before the built-in peer/server/metric profiles, so operators can add new
+// etcd-adjacent identities (e.g. `backup`, or a per-tenant CA) without
+// recompiling the signer.
+type ProfileRule struct {
+	// Name is the profile name, used as the `config.Signing` profile key.
+	Name string `json:"name"`
+	// CNRegex, when set, must match the CSR Subject Common Name.
+	CNRegex string `json:"cnRegex"`
+	// Organization, when set, must equal the CSR Subject Organization[0].
+	Organization string `json:"organization"`
+	// SignerName, when set, must equal the CSR's spec.signerName (only
+	// carried by CSRs submitted over the certificates.k8s.io/v1 route, or
+	// over v1beta1 with signerName explicitly set). It composes with
+	// CNRegex/Organization like another required-if-set filter, so a rule
+	// can bind a module-specific signer (e.g. "kubecsr.coreos.com/etcd-peer")
+	// to its own CA/backend independent of CN shape.
+	SignerName string `json:"signerName"`
+	// CACert and CAKey are the file paths to the CA used to sign CSRs matching this profile.
+	CACert string `json:"caCert"`
+	CAKey  string `json:"caKey"`
+	// CertDuration is the lifetime granted to certificates issued under this profile.
+	CertDuration time.Duration `json:"certDuration"`
+	// MinCertDuration and MaxCertDuration bound a caller-requested
+	// certificate lifetime (see requestDurationAnnotation) for CSRs
+	// matching this rule, following the pattern Istio's chiron uses to let
+	// short-lived bootstrapping credentials and long-lived identities share
+	// a signer. Left zero, the corresponding bound is not enforced; a CSR
+	// that doesn't request a duration at all is unaffected and still gets
+	// CertDuration.
+	MinCertDuration time.Duration `json:"minCertDuration"`
+	MaxCertDuration time.Duration `json:"maxCertDuration"`
+	// Usages is the list of key usages granted to certificates issued under this profile.
+	Usages []string `json:"usages"`
+	// SecretNameTemplate is used by mount-secret to compute the Secret name
+	// holding the issued cert/key pair. "%s" is replaced with the node name
+	// parsed out of the CSR Common Name.
+	SecretNameTemplate string `json:"secretNameTemplate"`
+
+	// Backend selects how CSRs matching this rule are signed: "local-ca"
+	// (the default, sign with CACert/CAKey), "acme" (obtain the
+	// certificate from an external ACME CA such as step-ca), or "vault"
+	// (obtain the certificate from a HashiCorp Vault PKI secrets engine
+	// mount). The ACME*/Vault* fields below are only consulted when
+	// Backend is the matching value.
+	Backend string `json:"backend"`
+	// ACMEDirectoryURL is the ACME server's directory endpoint.
+	ACMEDirectoryURL string `json:"acmeDirectoryURL"`
+	// ACMEEmail is the contact address submitted during account registration.
+	ACMEEmail string `json:"acmeEmail"`
+	// ACMEAccountKeyFile caches the ACME account key across signer restarts.
+	ACMEAccountKeyFile string `json:"acmeAccountKeyFile"`
+	// ACMEChallengeType selects the challenge type to complete; only
+	// "http-01" is currently backed by a built-in Solver.
+	ACMEChallengeType string `json:"acmeChallengeType"`
+
+	// VaultAddress, VaultToken, VaultMount, and VaultRole configure the
+	// Vault PKI secrets engine mount to sign against when Backend is
+	// "vault". See vault.Config for what each one means.
+	VaultAddress string `json:"vaultAddress"`
+	VaultToken   string `json:"vaultToken"`
+	VaultMount   string `json:"vaultMount"`
+	VaultRole    string `json:"vaultRole"`
+
+	// SPIFFETrustDomain, if set, requires every URI SAN on a CSR matching
+	// this rule to be a "spiffe://<SPIFFETrustDomain>/..." URI, so a
+	// workload identity rule only ever issues SVIDs for its own trust
+	// domain. A CSR with no URI SANs is unaffected.
+	SPIFFETrustDomain string `json:"spiffeTrustDomain"`
+	// SPIFFEWorkloadPaths, if set, further restricts the path component of
+	// an allowed spiffe:// URI SAN to one of these prefixes (e.g.
+	// "/etcd/peer/"), so a trust domain can be shared across workload
+	// types while still scoping what each ProfileRule may issue for.
+	SPIFFEWorkloadPaths []string `json:"spiffeWorkloadPaths"`
+
+	cnRegexp *regexp.Regexp
+}
+
+// LoadProfileRules reads a JSON file containing a list of ProfileRules and
+// compiles their CN regexes. It is used by both the signer and mount-secret
+// so the two stay in sync on how a CSR maps to a profile/Secret name.
+func LoadProfileRules(path string) ([]ProfileRule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile rules file %q: %v", path, err)
+	}
+
+	var rules []ProfileRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("error parsing profile rules file %q: %v", path, err)
+	}
+
+	for i := range rules {
+		if rules[i].CNRegex == "" {
+			continue
+		}
+		re, err := regexp.Compile(rules[i].CNRegex)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling cnRegex for profile %q: %v", rules[i].Name, err)
+		}
+		rules[i].cnRegexp = re
+	}
+	return rules, nil
+}
+
+// matchProfileRule returns the first ProfileRule whose SignerName/CNRegex/
+// Organization match the given CSR, or nil if none match. csr may be nil;
+// a rule with SignerName set never matches a nil csr.
+func matchProfileRule(rules []ProfileRule, x509CSR *x509.CertificateRequest, csr *capi.CertificateSigningRequest) *ProfileRule {
+	var signerName string
+	if csr != nil && csr.Spec.SignerName != nil {
+		signerName = *csr.Spec.SignerName
+	}
+
+	for i := range rules {
+		rule := rules[i]
+		if rule.SignerName != "" && rule.SignerName != signerName {
+			continue
+		}
+		if rule.Organization != "" || rule.cnRegexp != nil {
+			// a rule that matches on x509 fields can never match a CSR
+			// whose request bytes failed to parse.
+			if x509CSR == nil {
+				continue
+			}
+		}
+		if rule.Organization != "" {
+			if len(x509CSR.Subject.Organization) == 0 || x509CSR.Subject.Organization[0] != rule.Organization {
+				continue
+			}
+		}
+		if rule.cnRegexp != nil && !rule.cnRegexp.MatchString(x509CSR.Subject.CommonName) {
+			continue
+		}
+		return &rule
+	}
+	return nil
+}
+
+// validateSPIFFEURIs checks uris -- a CSR's URI SANs -- against rule's
+// SPIFFETrustDomain/SPIFFEWorkloadPaths allow-list. A rule with no
+// SPIFFETrustDomain set (including a nil rule, i.e. no ProfileRule
+// matched) doesn't opt into SPIFFE validation and passes any URI SANs
+// unchanged, matching the signer's behavior from before SPIFFE support
+// existed.
+func validateSPIFFEURIs(uris []*url.URL, rule *ProfileRule) error {
+	if rule == nil || rule.SPIFFETrustDomain == "" {
+		return nil
+	}
+	for _, u := range uris {
+		if u.Scheme != "spiffe" {
+			return fmt.Errorf("URI SAN %q is not a spiffe:// URI", u.String())
+		}
+		if u.Host != rule.SPIFFETrustDomain {
+			return fmt.Errorf("URI SAN %q has trust domain %q, want %q", u.String(), u.Host, rule.SPIFFETrustDomain)
+		}
+		if len(rule.SPIFFEWorkloadPaths) == 0 {
+			continue
+		}
+		allowed := false
+		for _, prefix := range rule.SPIFFEWorkloadPaths {
+			if strings.HasPrefix(u.Path, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("URI SAN %q has workload path %q, not one of the allowed prefixes %v", u.String(), u.Path, rule.SPIFFEWorkloadPaths)
+		}
+	}
+	return nil
+}
+
+// SecretNameForCN returns the Secret name the matching ProfileRule's
+// SecretNameTemplate computes for the given Common Name, using "%s" as the
+// substitution for the node name following the last ":" in the CN. It
+// returns false if no rule's CNRegex matches.
+func SecretNameForCN(cn string, rules []ProfileRule) (string, bool) {
+	for i := range rules {
+		rule := rules[i]
+		if rule.cnRegexp == nil || !rule.cnRegexp.MatchString(cn) {
+			continue
+		}
+		if rule.SecretNameTemplate == "" {
+			continue
+		}
+		return fmt.Sprintf(rule.SecretNameTemplate, nodeNameFromCN(cn)), true
+	}
+	return "", false
+}
+
+// requestDurationAnnotation is the CSR annotation a caller may set to
+// request a shorter certificate lifetime than a ProfileRule's configured
+// CertDuration, following the convention Istio's chiron uses
+// (experimental.cert-manager.io/request-duration) for the same purpose.
+// HandlePostCSR also populates it from the v1 spec.expirationSeconds field
+// when a caller sets that instead, so either input works regardless of
+// which certificates.k8s.io API version the CSR came in on.
+const requestDurationAnnotation = "experimental.cert-manager.io/request-duration"
+
+// requestedCertDuration returns the certificate lifetime csr is requesting
+// via requestDurationAnnotation, clamped to rule's MinCertDuration/
+// MaxCertDuration. It returns 0 if csr requests no duration, or if rule is
+// nil -- the built-in etcd-peer/etcd-server/etcd-metric profiles have no
+// ProfileRule to carry bounds on, so they keep their existing, fixed
+// Config-driven duration rather than honoring an arbitrary request.
+func requestedCertDuration(csr *capi.CertificateSigningRequest, rule *ProfileRule) (time.Duration, error) {
+	if rule == nil {
+		return 0, nil
+	}
+	raw, ok := csr.ObjectMeta.Annotations[requestDurationAnnotation]
+	if !ok || raw == "" {
+		return 0, nil
+	}
+
+	requested, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q: %v", requestDurationAnnotation, raw, err)
+	}
+	if rule.MaxCertDuration > 0 && requested > rule.MaxCertDuration {
+		requested = rule.MaxCertDuration
+	}
+	if rule.MinCertDuration > 0 && requested < rule.MinCertDuration {
+		requested = rule.MinCertDuration
+	}
+	return requested, nil
+}
+
+// setRequestedDurationFromExpirationSeconds populates csr's
+// requestDurationAnnotation from a v1 CSR's spec.expirationSeconds, if set
+// and csr doesn't already carry the annotation directly -- an explicit
+// annotation from the caller takes precedence over the field.
+func setRequestedDurationFromExpirationSeconds(csr *capi.CertificateSigningRequest, expirationSeconds *int32) {
+	if expirationSeconds == nil {
+		return
+	}
+	if _, ok := csr.ObjectMeta.Annotations[requestDurationAnnotation]; ok {
+		return
+	}
+	if csr.ObjectMeta.Annotations == nil {
+		csr.ObjectMeta.Annotations = map[string]string{}
+	}
+	csr.ObjectMeta.Annotations[requestDurationAnnotation] = (time.Duration(*expirationSeconds) * time.Second).String()
+}
+
 var (
 	// defaultCertDuration is initialized to 365 days
 	defaultCertDuration = 24 * 365 * time.Hour
@@ -60,6 +335,62 @@ type CertServer struct {
 	policy *config.Signing
 	// caFiles
 	caFiles *SignerCAFiles
+	// profileRules is the operator-configured list of additional CSR routing rules
+	profileRules []ProfileRule
+	// store records every signed certificate's serial/subject/SANs/expiry for
+	// audit and revocation purposes. It is nil when Config.Store is unset.
+	store store.Store
+	// allowReissueOverride permits re-signing a CN that already has a
+	// currently-valid, non-expired, non-revoked certificate on record.
+	allowReissueOverride bool
+	// limiter throttles signing requests per (source IP, CSR CN prefix). It
+	// is nil when Config.RateLimit is unset.
+	limiter *identityLimiter
+	// acmeIssuers holds one acme.Issuer per ProfileRule configured with
+	// Backend "acme", keyed by ProfileRule.Name.
+	acmeIssuers map[string]*acme.Issuer
+	// vaultIssuers holds one vault.Issuer per ProfileRule configured with
+	// Backend "vault", keyed by ProfileRule.Name.
+	vaultIssuers map[string]*vault.Issuer
+	// pending holds CSRs that hit a recoverable signing error, for the
+	// background reconciler started by StartSignerServer to retry.
+	pending *pendingQueue
+	// certDB, when set, is attached directly to the cfssl signer so every
+	// certificate it issues is recorded in a durable SQL database,
+	// independent of the higher-level store.Store audit trail above.
+	certDB certdb.Accessor
+	// revoke serves CRL/OCSP revocation status for the root and metric CAs
+	// from certDB's revocation records. Nil unless certDB is configured.
+	revoke *revoke.Manager
+	// remoteSigners, keyed by profile name, delegates that profile's
+	// signing to a remote CFSSL server instead of the local CA. Populated
+	// from Config.RemoteSigners.
+	remoteSigners map[string]RemoteSignerConfig
+	// cloudVerifier checks a CSR's node name against the expected cloud
+	// instance group before signing. Defaults to a no-op if
+	// Config.CloudVerifier is unset.
+	cloudVerifier CloudVerifier
+	// csrDedupe caches HandlePostCSR's signing result per CSR request
+	// hash for Config.CSRDedupeTTL, so a resubmission of the identical
+	// CSR is served the cached result instead of being signed again.
+	csrDedupe *timedcache.Cache
+	// csrStore, if set from Config.CSRStore, additionally records every
+	// signed CSR so handleGetCSR can serve it without shared disk between
+	// replicas and so HandleWatchCSR can push it to a waiting agent. Nil
+	// preserves the signer's original disk-only (csrDir) behavior.
+	csrStore CSRStore
+}
+
+// defaultCSRDedupeTTL is used when Config.CSRDedupeTTL is unset or
+// non-positive.
+const defaultCSRDedupeTTL = 1 * time.Minute
+
+// SignerBackend is the minimal interface CertSigner needs to sign a request:
+// something that takes a cfssl SignRequest and returns a signed certificate.
+// *local.Signer (an on-host CA) and the remote CFSSL backend in remote.go
+// both already satisfy it without any adapter.
+type SignerBackend interface {
+	Sign(req signer.SignRequest) (cert []byte, err error)
 }
 
 // CertSigner signs a certiifcate using a `cfssl` Signer.
@@ -73,9 +404,16 @@ type CertSigner struct {
 	// caCert is the x509 PEM encoded private key of the CA used for the
 	// cfssl signer
 	caKey crypto.Signer
-	// cfsslSigner is a `cfssl` Signer that can sign a certificate based on a
-	// certificate request.
-	cfsslSigner *local.Signer
+	// localBackend signs with the on-host CA loaded into caCert/caKey. It is
+	// used for every profile not present in backends.
+	localBackend SignerBackend
+	// backends, keyed by profile name, delegate that profile's signing to a
+	// remote CFSSL server instead of localBackend. Populated from
+	// Config.RemoteSigners, e.g. to keep EtcdMetric local while EtcdPeer and
+	// EtcdServer are signed by a central CFSSL cluster.
+	backends map[string]SignerBackend
+	// profileRules is the operator-configured list of additional CSR routing rules
+	profileRules []ProfileRule
 }
 
 // CertKey stores files for the cert and key pair.
@@ -93,6 +431,9 @@ type Config struct {
 	ListenAddress string
 	// InsecureHealthCheckAddress is the address at which the server listens for insecure health checks
 	InsecureHealthCheckAddress string
+	// DisableHealthCheck, if true, skips registering the /readyz handler
+	// and starting the insecure health check listener entirely.
+	DisableHealthCheck bool
 	// EtcdMetricCertDuration
 	EtcdMetricCertDuration time.Duration
 	// EtcdPeerCertDuration is the cert duration for the `EtcdPeer` profile
@@ -101,18 +442,102 @@ type Config struct {
 	EtcdServerCertDuration time.Duration
 	// CSRDir is the directory location where the signer stores CSRs and serves them
 	CSRDir string
+	// ProfileRules is an optional, config-driven list of additional CSR
+	// routing rules, checked before the built-in peer/server/metric profiles.
+	ProfileRules []ProfileRule
+	// Store, when set, persists every signed certificate and is consulted to
+	// reject re-signing a CN that already has a valid certificate on record.
+	Store store.Store
+	// AllowReissueOverride permits re-signing a CN that already has a
+	// currently-valid, non-expired, non-revoked certificate on record.
+	AllowReissueOverride bool
+	// RateLimit, when QPS > 0, throttles signing requests per identity and
+	// enforces a rolling-window issuance quota.
+	RateLimit RateLimitConfig
+	// PendingRetryInterval is how often the background reconciler retries
+	// CSRs that hit a recoverable signing error. Defaults to 10s.
+	PendingRetryInterval time.Duration
+	// PendingMaxAge bounds how long a CSR is retried before the
+	// reconciler drops it. Defaults to 24h.
+	PendingMaxAge time.Duration
+	// CertDB, when set, opens a cfssl certdb-backed SQL audit trail and
+	// attaches it directly to the cfssl signer.
+	CertDB *CertDBConfig
+	// CRLInterval is how often the CRL for each CA is regenerated, if
+	// CertDB is set. Defaults to 1h.
+	CRLInterval time.Duration
+	// RemoteSigners, keyed by profile name (e.g. "EtcdPeer"), delegates that
+	// profile's signing to a remote CFSSL server over mTLS instead of
+	// signing with the local CA. A profile absent from this map is signed
+	// locally, as before RemoteSigners existed.
+	RemoteSigners map[string]RemoteSignerConfig
+	// CloudVerifier, when set, is consulted with the node name parsed out
+	// of each CSR's Common Name before signing, and the CSR is rejected if
+	// it returns an error. Left nil (the pre-CloudVerifier behavior) by
+	// configurations that don't set --cloud-provider.
+	CloudVerifier CloudVerifier
+	// CSRDedupeTTL is how long HandlePostCSR remembers a CSR's signing
+	// result, keyed by a hash of its request bytes, so a resubmission of
+	// the identical CSR (e.g. CertAgent.RequestCertificate retrying a
+	// Create call that actually succeeded, after a transient error
+	// obscured the response) is served the cached result instead of
+	// being signed again. Defaults to defaultCSRDedupeTTL if zero.
+	CSRDedupeTTL time.Duration
+	// CSRStore, when set, additionally records every signed CSR so it can
+	// be retrieved or watched without relying on csrDir being on shared
+	// disk between replicas. Use NewMemoryCSRStore for the bundled
+	// in-memory implementation. Left nil, the signer serves signed CSRs
+	// from csrDir only, as before CSRStore existed, and the watch=true
+	// endpoint responds 501.
+	CSRStore CSRStore
 }
 
 // SignerCAFiles holds the file paths to the signer CA assets
 type SignerCAFiles struct {
 	// CACert is the file location of the Certificate Authority certificate
 	CACert string
-	// CAKey is the file location of the Certificate Authority private key
+	// CAKey is the file location of the Certificate Authority private key.
+	// Ignored if CAHSM is set.
 	CAKey string
+	// CAHSM, if set, sources the root CA's private key from a PKCS#11
+	// token instead of CAKey.
+	CAHSM *hsm.Config
 	// MetricCACert is the file location of the metrics Certificate Authority certificate
 	MetricCACert string
-	// MetricCAKey is the file location of the metrics Certificate Authority private key
+	// MetricCAKey is the file location of the metrics Certificate Authority private key.
+	// Ignored if MetricCAHSM is set.
 	MetricCAKey string
+	// MetricCAHSM, if set, sources the metric CA's private key from a
+	// PKCS#11 token instead of MetricCAKey.
+	MetricCAHSM *hsm.Config
+
+	// DisablePeerSigner, DisableServerSigner, and DisableMetricsSigner
+	// withdraw the EtcdPeer, EtcdServer, and EtcdMetric profiles
+	// respectively, so a signer split across least-privilege hosts only
+	// needs the CA material its enabled profile(s) actually use. A CSR
+	// for a disabled profile is refused with ErrProfileSupport, the same
+	// error returned today when its CA pair is simply absent.
+	DisablePeerSigner    bool
+	DisableServerSigner  bool
+	DisableMetricsSigner bool
+
+	// SelfInit, if true, mints a fresh self-signed CA for the root and/or
+	// metric pair when NewServer starts and finds both of that pair's files
+	// absent from disk, instead of failing to load it. The existing "must
+	// already exist" behavior remains the default so a misconfigured mount
+	// can't accidentally mint a new CA; a pair with only one of its two
+	// files present is left alone and still fails to load as before.
+	SelfInit bool
+	// SelfInitCommonName and SelfInitOrganization set the subject of any CA
+	// minted by SelfInit. A self-initialized metric CA gets the same
+	// organization, with " Metrics CA" appended to the common name.
+	SelfInitCommonName   string
+	SelfInitOrganization string
+	// SelfInitKeyAlgo and SelfInitKeySize select a minted CA's key algorithm
+	// ("rsa" or "ecdsa") and size, as in cfssl's csr.BasicKeyRequest. Default
+	// to "rsa" and 2048 if unset.
+	SelfInitKeyAlgo string
+	SelfInitKeySize int
 }
 
 // SignerCA stores the PEM encoded cert and key blocks.
@@ -138,47 +563,206 @@ func (l *loggingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // NewServer returns a CertServer object that has a CertSigner object
 // as a part of it
 func NewServer(c Config) (*CertServer, error) {
+	if c.SelfInit {
+		if err := selfInitCAs(&c.SignerCAFiles); err != nil {
+			return nil, fmt.Errorf("error self-initializing signer CAs: %v", err)
+		}
+	}
+
 	policy := signerPolicy(c)
 	mux := mux.NewRouter()
+
+	certDB, err := newCertDBAccessor(c.CertDB)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up certdb: %v", err)
+	}
+
+	cloudVerifier := c.CloudVerifier
+	if cloudVerifier == nil {
+		cloudVerifier = noopCloudVerifier{}
+	}
+
+	csrDedupeTTL := c.CSRDedupeTTL
+	if csrDedupeTTL <= 0 {
+		csrDedupeTTL = defaultCSRDedupeTTL
+	}
+
 	server := &CertServer{
 		mux:    mux,
 		csrDir: c.CSRDir,
 		policy: &policy,
 
-		caFiles: &c.SignerCAFiles,
+		caFiles:              &c.SignerCAFiles,
+		profileRules:         c.ProfileRules,
+		store:                c.Store,
+		allowReissueOverride: c.AllowReissueOverride,
+		limiter:              newIdentityLimiter(c.RateLimit),
+		pending:              newPendingQueue(),
+		certDB:               certDB,
+		remoteSigners:        c.RemoteSigners,
+		cloudVerifier:        cloudVerifier,
+		csrDedupe:            timedcache.New(csrDedupeTTL),
+		csrStore:             c.CSRStore,
 	}
 
 	mux.HandleFunc("/apis/certificates.k8s.io/v1beta1/certificatesigningrequests", server.HandlePostCSR).Methods("POST")
 	mux.HandleFunc("/apis/certificates.k8s.io/v1beta1/certificatesigningrequests/{csrName}", server.HandleGetCSR).Methods("GET")
-	mux.HandleFunc("/readyz", HandleHealthCheck).Methods("GET", "HEAD")
+	// The v1 route shares HandlePostCSR with v1beta1 -- it decodes either
+	// shape -- but needs its own GET handler, since a bare GET carries no
+	// body for HandlePostCSR's decode step to negotiate the version from.
+	mux.HandleFunc("/apis/certificates.k8s.io/v1/certificatesigningrequests", server.HandlePostCSR).Methods("POST")
+	mux.HandleFunc("/apis/certificates.k8s.io/v1/certificatesigningrequests/{csrName}", server.HandleGetCSRV1).Methods("GET")
+	// A watch=true GET on the collection route, rather than the
+	// {csrName} route above, mirrors where client-go itself issues a
+	// watch; it only works when CSRStore is configured (see
+	// HandleWatchCSR).
+	mux.HandleFunc("/apis/certificates.k8s.io/v1/certificatesigningrequests", server.HandleWatchCSR).Methods("GET").Queries("watch", "true")
+	if !c.DisableHealthCheck {
+		mux.HandleFunc("/readyz", HandleHealthCheck).Methods("GET", "HEAD")
+	}
+	mux.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	if server.store != nil {
+		mux.HandleFunc("/apis/certsigner/v1/issued", server.HandleListIssued).Methods("GET")
+		mux.HandleFunc("/apis/certsigner/v1/issued/{serial}/revoke", server.HandleRevoke).Methods("POST")
+	}
+	if server.certDB != nil && server.store == nil {
+		// certDB and store are independent, optional audit trails that both
+		// claim /apis/certsigner/v1/issued; store wins if both are
+		// configured, since it already serves the richer store.Record shape.
+		mux.HandleFunc("/apis/certsigner/v1/issued", server.HandleListIssuedCertDB).Methods("GET")
+		mux.HandleFunc("/apis/certsigner/v1/revoke/{serial}", server.HandleRevokeCertDB).Methods("POST")
+	}
+	if server.certDB != nil {
+		revokeCAs, err := LoadRevokeCAs(&c.SignerCAFiles)
+		if err != nil {
+			return nil, fmt.Errorf("error loading CAs for CRL/OCSP: %v", err)
+		}
+		server.revoke = revoke.NewManager(server.certDB, revokeCAs)
+
+		mux.HandleFunc("/crl/root.crl", server.revoke.ServeCRL("root")).Methods("GET")
+		mux.HandleFunc("/crl/metric.crl", server.revoke.ServeCRL("metric")).Methods("GET")
+		mux.Handle("/ocsp", server.revoke.Handler()).Methods("POST")
+
+		go server.revoke.Start(c.CRLInterval, make(chan struct{}))
+	}
+
+	acmeIssuers, err := newACMEIssuers(c.ProfileRules, mux)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up ACME-backed profiles: %v", err)
+	}
+	server.acmeIssuers = acmeIssuers
+
+	vaultIssuers, err := newVaultIssuers(c.ProfileRules)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up Vault-backed profiles: %v", err)
+	}
+	server.vaultIssuers = vaultIssuers
 
 	return server, nil
 }
 
+// newACMEIssuers builds one acme.Issuer per rule whose Backend is "acme",
+// registering a single shared http-01 solver route on r to answer
+// challenges for all of them.
+func newACMEIssuers(rules []ProfileRule, r *mux.Router) (map[string]*acme.Issuer, error) {
+	var solver *acme.HTTPSolver
+	issuers := map[string]*acme.Issuer{}
+
+	for _, rule := range rules {
+		if rule.Backend != backendACME {
+			continue
+		}
+		if solver == nil {
+			solver = acme.NewHTTPSolver(r)
+		}
+
+		challengeType := rule.ACMEChallengeType
+		if challengeType == "" {
+			challengeType = "http-01"
+		}
+
+		issuer, err := acme.NewIssuer(acme.Config{
+			DirectoryURL:   rule.ACMEDirectoryURL,
+			Email:          rule.ACMEEmail,
+			AccountKeyFile: rule.ACMEAccountKeyFile,
+			ChallengeType:  challengeType,
+		}, solver)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up ACME issuer for profile %q: %v", rule.Name, err)
+		}
+		issuers[rule.Name] = issuer
+	}
+	return issuers, nil
+}
+
+// newVaultIssuers builds one vault.Issuer per rule whose Backend is "vault".
+func newVaultIssuers(rules []ProfileRule) (map[string]*vault.Issuer, error) {
+	issuers := map[string]*vault.Issuer{}
+
+	for _, rule := range rules {
+		if rule.Backend != backendVault {
+			continue
+		}
+
+		issuer, err := vault.NewIssuer(vault.Config{
+			Address: rule.VaultAddress,
+			Token:   rule.VaultToken,
+			Mount:   rule.VaultMount,
+			Role:    rule.VaultRole,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error setting up Vault issuer for profile %q: %v", rule.Name, err)
+		}
+		issuers[rule.Name] = issuer
+	}
+	return issuers, nil
+}
+
 func (s *CertServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
 }
 
 // newSignerCA returns a SignerCA object of PEM encoded CA cert and keys based on the profile passed.
-func newSignerCA(sc *SignerCAFiles, csr *capi.CertificateSigningRequest) (*SignerCA, error) {
+func newSignerCA(sc *SignerCAFiles, rules []ProfileRule, csr *capi.CertificateSigningRequest) (*SignerCA, error) {
 	var caCert, caKey string
+	var caHSM *hsm.Config
 
-	profile, err := getProfile(csr)
+	if len(rules) > 0 {
+		x509CSR, err := csrutil.ParseCSR(csr)
+		if err == nil {
+			if rule := matchProfileRule(rules, x509CSR, csr); rule != nil {
+				caCert, caKey = rule.CACert, rule.CAKey
+				return loadSignerCA(caCert, caKey, nil)
+			}
+		}
+	}
+
+	profile, err := getProfile(csr, rules)
 	if err != nil {
 		return nil, err
 	}
 	switch profile {
 	case "EtcdMetric":
-		if sc.MetricCAKey != "" && sc.MetricCACert != "" {
+		if !sc.DisableMetricsSigner && sc.MetricCACert != "" && (sc.MetricCAKey != "" || sc.MetricCAHSM != nil) {
 			caCert = sc.MetricCACert
 			caKey = sc.MetricCAKey
+			caHSM = sc.MetricCAHSM
 			break
 		}
 		return nil, ErrProfileSupport
-	case "EtcdServer", "EtcdPeer":
-		if sc.CAKey != "" && sc.CACert != "" {
+	case "EtcdPeer":
+		if !sc.DisablePeerSigner && sc.CACert != "" && (sc.CAKey != "" || sc.CAHSM != nil) {
 			caCert = sc.CACert
 			caKey = sc.CAKey
+			caHSM = sc.CAHSM
+			break
+		}
+		return nil, ErrProfileSupport
+	case "EtcdServer":
+		if !sc.DisableServerSigner && sc.CACert != "" && (sc.CAKey != "" || sc.CAHSM != nil) {
+			caCert = sc.CACert
+			caKey = sc.CAKey
+			caHSM = sc.CAHSM
 			break
 		}
 		return nil, ErrProfileSupport
@@ -186,18 +770,74 @@ func newSignerCA(sc *SignerCAFiles, csr *capi.CertificateSigningRequest) (*Signe
 		return nil, ErrInvalidOrg
 	}
 
-	ca, err := ioutil.ReadFile(caCert)
-	if err != nil {
-		return nil, fmt.Errorf("error reading CA cert file %q: %v", caCert, err)
+	return loadSignerCA(caCert, caKey, caHSM)
+}
+
+// LoadRevokeCAs loads the root and metric CAs described by sc and returns
+// them as revoke.CA values, ready for revoke.NewManager or GenerateCRL. A
+// CA whose cert path is unset is skipped.
+func LoadRevokeCAs(sc *SignerCAFiles) ([]revoke.CA, error) {
+	var cas []revoke.CA
+
+	if sc.CACert != "" {
+		root, err := loadSignerCA(sc.CACert, sc.CAKey, sc.CAHSM)
+		if err != nil {
+			return nil, fmt.Errorf("error loading root CA: %v", err)
+		}
+		cas = append(cas, revoke.CA{
+			Name:     "root",
+			Profiles: []string{etcdPeer, etcdServer},
+			Cert:     root.caCert,
+			Key:      root.caKey,
+		})
 	}
-	cakey, err := ioutil.ReadFile(caKey)
+
+	if sc.MetricCACert != "" {
+		metric, err := loadSignerCA(sc.MetricCACert, sc.MetricCAKey, sc.MetricCAHSM)
+		if err != nil {
+			return nil, fmt.Errorf("error loading metric CA: %v", err)
+		}
+		cas = append(cas, revoke.CA{
+			Name:     "metric",
+			Profiles: []string{etcdMetric},
+			Cert:     metric.caCert,
+			Key:      metric.caKey,
+		})
+	}
+
+	return cas, nil
+}
+
+// loadSignerCA reads and parses the CA cert at caCert, and the CA's private
+// key either from the PKCS#11 token described by hsmConfig, or (if hsmConfig
+// is nil, or it fails and allows it) from the PEM key file at caKey.
+func loadSignerCA(caCert, caKey string, hsmConfig *hsm.Config) (*SignerCA, error) {
+	ca, err := ioutil.ReadFile(caCert)
 	if err != nil {
-		return nil, fmt.Errorf("error reading CA key file %q: %v", caKey, err)
+		// a missing/unmounted file is usually transient in a container
+		// environment, so let the caller retry instead of failing outright.
+		return nil, recoverable(fmt.Errorf("error reading CA cert file %q: %v", caCert, err))
 	}
 	parsedCA, err := helpers.ParseCertificatePEM(ca)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing CA cert file %q: %v", caCert, err)
 	}
+
+	if hsmConfig != nil {
+		privateKey, err := hsm.NewSigner(*hsmConfig)
+		if err == nil {
+			return &SignerCA{caCert: parsedCA, caKey: privateKey}, nil
+		}
+		if !hsmConfig.AllowPEMFallback {
+			return nil, recoverable(fmt.Errorf("error loading CA key from HSM: %v", err))
+		}
+		glog.Errorf("error loading CA key from HSM, falling back to %q: %v", caKey, err)
+	}
+
+	cakey, err := ioutil.ReadFile(caKey)
+	if err != nil {
+		return nil, recoverable(fmt.Errorf("error reading CA key file %q: %v", caKey, err))
+	}
 	privateKey, err := helpers.ParsePrivateKeyPEM(cakey)
 	if err != nil {
 		return nil, fmt.Errorf("Malformed private key %v", err)
@@ -209,40 +849,52 @@ func newSignerCA(sc *SignerCAFiles, csr *capi.CertificateSigningRequest) (*Signe
 	}, nil
 }
 
-// signerPolicy
+// signerPolicy builds the cfssl signing policy for the built-in
+// peer/server/metric profiles, plus one profile per operator-configured
+// ProfileRule so config-driven CAs can be selected by name.
 func signerPolicy(c Config) config.Signing {
-	policy := config.Signing{
-		Profiles: map[string]*config.SigningProfile{
-			etcdPeer: &config.SigningProfile{
-				Usage: []string{
-					string(capi.UsageKeyEncipherment),
-					string(capi.UsageDigitalSignature),
-					string(capi.UsageClientAuth),
-					string(capi.UsageServerAuth),
-				},
-				Expiry:       c.EtcdPeerCertDuration,
-				ExpiryString: c.EtcdPeerCertDuration.String(),
+	profiles := map[string]*config.SigningProfile{
+		etcdPeer: &config.SigningProfile{
+			Usage: []string{
+				string(capi.UsageKeyEncipherment),
+				string(capi.UsageDigitalSignature),
+				string(capi.UsageClientAuth),
+				string(capi.UsageServerAuth),
 			},
-			etcdServer: &config.SigningProfile{
-				Usage: []string{
-					string(capi.UsageKeyEncipherment),
-					string(capi.UsageDigitalSignature),
-					string(capi.UsageServerAuth),
-				},
-				Expiry:       c.EtcdServerCertDuration,
-				ExpiryString: c.EtcdServerCertDuration.String(),
+			Expiry:       c.EtcdPeerCertDuration,
+			ExpiryString: c.EtcdPeerCertDuration.String(),
+		},
+		etcdServer: &config.SigningProfile{
+			Usage: []string{
+				string(capi.UsageKeyEncipherment),
+				string(capi.UsageDigitalSignature),
+				string(capi.UsageServerAuth),
 			},
-			etcdMetric: &config.SigningProfile{
-				Usage: []string{
-					string(capi.UsageKeyEncipherment),
-					string(capi.UsageDigitalSignature),
-					string(capi.UsageClientAuth),
-					string(capi.UsageServerAuth),
-				},
-				Expiry:       c.EtcdMetricCertDuration,
-				ExpiryString: c.EtcdMetricCertDuration.String(),
+			Expiry:       c.EtcdServerCertDuration,
+			ExpiryString: c.EtcdServerCertDuration.String(),
+		},
+		etcdMetric: &config.SigningProfile{
+			Usage: []string{
+				string(capi.UsageKeyEncipherment),
+				string(capi.UsageDigitalSignature),
+				string(capi.UsageClientAuth),
+				string(capi.UsageServerAuth),
 			},
+			Expiry:       c.EtcdMetricCertDuration,
+			ExpiryString: c.EtcdMetricCertDuration.String(),
 		},
+	}
+
+	for _, rule := range c.ProfileRules {
+		profiles[rule.Name] = &config.SigningProfile{
+			Usage:        rule.Usages,
+			Expiry:       rule.CertDuration,
+			ExpiryString: rule.CertDuration.String(),
+		}
+	}
+
+	return config.Signing{
+		Profiles: profiles,
 		Default: &config.SigningProfile{
 			Usage: []string{
 				string(capi.UsageKeyEncipherment),
@@ -252,22 +904,37 @@ func signerPolicy(c Config) config.Signing {
 			ExpiryString: defaultCertDuration.String(),
 		},
 	}
-
-	return policy
 }
 
 // NewSigner returns a CertSigner object after filling in its attibutes
-// from the `Config` provided.
-func NewSigner(s *SignerCA, policy *config.Signing) (*CertSigner, error) {
+// from the `Config` provided. If dbAccessor is non-nil, the cfssl signer
+// records every certificate it issues through it. remoteSigners, if
+// non-empty, delegates the named profiles to a remote CFSSL server instead
+// of signing them with s's CA.
+func NewSigner(s *SignerCA, policy *config.Signing, rules []ProfileRule, dbAccessor certdb.Accessor, remoteSigners map[string]RemoteSignerConfig) (*CertSigner, error) {
 	cfs, err := local.NewSigner(s.caKey, s.caCert, signer.DefaultSigAlgo(s.caKey), policy)
 	if err != nil {
 		return nil, fmt.Errorf("error setting up local cfssl signer: %v", err)
 	}
+	if dbAccessor != nil {
+		cfs.SetDBAccessor(dbAccessor)
+	}
+
+	backends := make(map[string]SignerBackend, len(remoteSigners))
+	for profile, rc := range remoteSigners {
+		backend, err := newRemoteSignerBackend(rc)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up remote signer for profile %q: %v", profile, err)
+		}
+		backends[profile] = backend
+	}
 
 	return &CertSigner{
-		caCert:      s.caCert,
-		caKey:       s.caKey,
-		cfsslSigner: cfs,
+		caCert:       s.caCert,
+		caKey:        s.caKey,
+		localBackend: cfs,
+		backends:     backends,
+		profileRules: rules,
 	}, nil
 }
 
@@ -279,7 +946,7 @@ func NewSigner(s *SignerCA, policy *config.Signing) (*CertSigner, error) {
 func (s *CertSigner) Sign(csr *capi.CertificateSigningRequest) (*capi.CertificateSigningRequest, error) {
 	// the following step ensures that the signer server only signs CSRs from etcd nodes
 	// that have a specific profile. All other requests are denied immediately.
-	profile, err := getProfile(csr)
+	profile, err := getProfile(csr, s.profileRules)
 	if err != nil {
 		csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
 			capi.CertificateSigningRequestCondition{
@@ -290,10 +957,40 @@ func (s *CertSigner) Sign(csr *capi.CertificateSigningRequest) (*capi.Certificat
 		return nil, fmt.Errorf("error parsing profile: %v", err)
 	}
 
-	csr.Status.Certificate, err = s.cfsslSigner.Sign(signer.SignRequest{
+	backend := s.localBackend
+	if b, ok := s.backends[profile]; ok {
+		backend = b
+	}
+
+	req := signer.SignRequest{
 		Request: string(csr.Spec.Request),
 		Profile: profile,
-	})
+		// Label ends up as the certdb record's CALabel, so a certDB-backed
+		// audit trail can be filtered by profile the same way the
+		// store.Store-backed one is filtered by ProfileRule.
+		Label: profile,
+	}
+
+	if x509CSR, perr := csrutil.ParseCSR(csr); perr == nil {
+		rule := matchProfileRule(s.profileRules, x509CSR, csr)
+		duration, derr := requestedCertDuration(csr, rule)
+		if derr != nil {
+			csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
+				capi.CertificateSigningRequestCondition{
+					Type:    capi.CertificateDenied,
+					Message: derr.Error(),
+				},
+			}
+			return nil, derr
+		}
+		if duration > 0 {
+			req.NotAfter = time.Now().Add(duration)
+			signedCertDurationSeconds.WithLabelValues(profile).Observe(duration.Seconds())
+			glog.V(4).Infof("signing csr %s under profile %q with requested duration %s", csr.ObjectMeta.Name, profile, duration)
+		}
+	}
+
+	csr.Status.Certificate, err = backend.Sign(req)
 	if err != nil {
 		csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
 			capi.CertificateSigningRequestCondition{
@@ -301,7 +998,9 @@ func (s *CertSigner) Sign(csr *capi.CertificateSigningRequest) (*capi.Certificat
 				Message: fmt.Sprintf("certificate signing error: %v ", err),
 			},
 		}
-		return csr, err
+		// the cfssl signer itself failing (vs. a bad CSR) is usually
+		// transient -- e.g. a dropped HSM session -- so let it be retried.
+		return csr, recoverable(err)
 	}
 
 	csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
@@ -313,9 +1012,89 @@ func (s *CertSigner) Sign(csr *capi.CertificateSigningRequest) (*capi.Certificat
 	return csr, nil
 }
 
-// getProfile returns the profile corresponding to the CSR Subject. For now only
-// `etcd-peers` and `etcd-servers` are considered valid profiles.
-func getProfile(csr *capi.CertificateSigningRequest) (string, error) {
+// signACME obtains a certificate for csr from rule's ACME issuer, using
+// x509CSR's Common Name and DNS SANs as the domains to authorize.
+func (s *CertServer) signACME(rule *ProfileRule, x509CSR *x509.CertificateRequest, csr *capi.CertificateSigningRequest) (*capi.CertificateSigningRequest, error) {
+	issuer, ok := s.acmeIssuers[rule.Name]
+	if !ok {
+		return nil, fmt.Errorf("no ACME issuer configured for profile %q", rule.Name)
+	}
+
+	domains := x509CSR.DNSNames
+	if x509CSR.Subject.CommonName != "" {
+		domains = append([]string{x509CSR.Subject.CommonName}, domains...)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("CSR has no Common Name or DNS SANs to authorize")
+	}
+
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil {
+		return nil, fmt.Errorf("unable to decode PEM-encoded CSR")
+	}
+
+	chain, err := issuer.IssueCertificate(domains, block.Bytes)
+	if err != nil {
+		csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
+			{Type: capi.CertificateDenied, Message: fmt.Sprintf("ACME issuance error: %v", err)},
+		}
+		return csr, err
+	}
+
+	csr.Status.Certificate = pemEncodeChain(chain)
+	csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
+		{Type: capi.CertificateApproved},
+	}
+	return csr, nil
+}
+
+// signVault obtains a certificate for csr from rule's Vault issuer.
+func (s *CertServer) signVault(rule *ProfileRule, csr *capi.CertificateSigningRequest) (*capi.CertificateSigningRequest, error) {
+	issuer, ok := s.vaultIssuers[rule.Name]
+	if !ok {
+		return nil, fmt.Errorf("no Vault issuer configured for profile %q", rule.Name)
+	}
+
+	cert, err := issuer.IssueCertificate(csr.Spec.Request)
+	if err != nil {
+		csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
+			{Type: capi.CertificateDenied, Message: fmt.Sprintf("Vault issuance error: %v", err)},
+		}
+		return csr, err
+	}
+
+	csr.Status.Certificate = cert
+	csr.Status.Conditions = []capi.CertificateSigningRequestCondition{
+		{Type: capi.CertificateApproved},
+	}
+	return csr, nil
+}
+
+// csrDedupeKey returns the cache key HandlePostCSR uses to deduplicate
+// signings of an identical CSR: the hex-encoded SHA-256 of its raw
+// request bytes. Hashing keeps the in-memory cache's footprint constant
+// regardless of CSR size, and two byte-identical requests -- which is
+// all CertAgent.RequestCertificate's retry path can produce, since it
+// resubmits the exact same generated CSR object -- always hash the same.
+func csrDedupeKey(csrRequest []byte) string {
+	sum := sha256.Sum256(csrRequest)
+	return fmt.Sprintf("%x", sum)
+}
+
+// pemEncodeChain PEM-encodes a certificate chain of DER-encoded blocks, leaf first.
+func pemEncodeChain(chain [][]byte) []byte {
+	var out []byte
+	for _, der := range chain {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	return out
+}
+
+// getProfile returns the profile corresponding to the CSR Subject. Config-driven
+// ProfileRules are checked first so operators can add new profiles without
+// recompiling; otherwise only `etcd-peers`/`etcd-servers`/`etcd-metrics` are
+// considered valid profiles.
+func getProfile(csr *capi.CertificateSigningRequest, rules []ProfileRule) (string, error) {
 	x509CSR, err := csrutil.ParseCSR(csr)
 	if err != nil {
 		return "", fmt.Errorf("error parsing CSR, %v", err)
@@ -323,6 +1102,11 @@ func getProfile(csr *capi.CertificateSigningRequest) (string, error) {
 	if err := x509CSR.CheckSignature(); err != nil {
 		return "", fmt.Errorf("error validating signature of CSR: %v", err)
 	}
+
+	if rule := matchProfileRule(rules, x509CSR, csr); rule != nil {
+		return rule.Name, nil
+	}
+
 	if x509CSR.Subject.Organization == nil || len(x509CSR.Subject.Organization) == 0 {
 		return "", ErrInvalidOrg
 	}
@@ -349,10 +1133,54 @@ func getProfile(csr *capi.CertificateSigningRequest) (string, error) {
 	return "", ErrInvalidOrg
 }
 
+// denyCSR appends a Denied condition to csr with reason and message. It's
+// the signer-side counterpart of aws.Approver's denyPolicy/denySigned, but
+// this server has no CSR API object of its own to update -- HandlePostCSR
+// returns the denied csr to the caller directly instead, so the agent can
+// read csr.Status.Conditions for a structured reason rather than being
+// left with only an HTTP status line.
+func denyCSR(csr *capi.CertificateSigningRequest, reason, message string) *capi.CertificateSigningRequest {
+	csr.Status.Conditions = append(csr.Status.Conditions, capi.CertificateSigningRequestCondition{
+		Type:    capi.CertificateDenied,
+		Reason:  reason,
+		Message: message,
+	})
+	return csr
+}
+
+// writeDeniedCSR marshals a denied csr (as v1 if the original request was)
+// and writes it as the response body with statusCode, in place of
+// http.Error's plain-text body.
+func writeDeniedCSR(w http.ResponseWriter, csr *capi.CertificateSigningRequest, v1Request bool, statusCode int) {
+	var body interface{} = csr
+	if v1Request {
+		body = csrToV1(csr)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		glog.Errorf("Error marshalling denied CSR: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
+
 // HandlePostCSR takes in a CSR, attempts to approve it and writes the CSR
 // to a file in the `csrDir`.
 // It returns a `http.StatusOK` to the client if the recieved CSR can
 // be sucessfully decoded.
+//
+// It is registered under both the certificates.k8s.io/v1beta1 and v1
+// routes: scheme.Codecs already knows both API versions, so the request
+// body's own apiVersion/kind (decoded here, ahead of any profile routing)
+// is what actually negotiates the version -- a more reliable signal than
+// trusting the Content-Type/Accept headers alone, which this handler
+// still requires to be "application/json" via the deserializer. A v1
+// request is converted to the v1beta1-shaped type the rest of the signer
+// operates on internally, and the response is converted back to v1
+// before being written.
 func (s *CertServer) HandlePostCSR(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -368,78 +1196,429 @@ func (s *CertServer) HandlePostCSR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	csr, ok := obj.(*capi.CertificateSigningRequest)
-	if !ok {
-		glog.Errorf("Invalid Certificate Signing Request in request from agent: %v", err)
+	var csr *capi.CertificateSigningRequest
+	var v1Request bool
+	switch t := obj.(type) {
+	case *capi.CertificateSigningRequest:
+		csr = t
+	case *certv1.CertificateSigningRequest:
+		csr = csrFromV1(t)
+		v1Request = true
+		setRequestedDurationFromExpirationSeconds(csr, t.Spec.ExpirationSeconds)
+	default:
+		glog.Errorf("Invalid Certificate Signing Request in request from agent: unexpected type %T", obj)
 		http.Error(w, "Invalid Certificate Signing Request", http.StatusBadRequest)
 		return
 	}
 
-	signerCA, err := newSignerCA(s.caFiles, csr)
-	if err != nil {
-		glog.Errorf("Error signing CSR provided in request from agent: %v", err)
-		http.Error(w, "Error signing csr", http.StatusBadRequest)
-		return
+	var requestCN string
+	x509CSR, perr := csrutil.ParseCSR(csr)
+	if perr == nil {
+		requestCN = x509CSR.Subject.CommonName
 	}
 
-	signer, err := NewSigner(signerCA, s.policy)
-	if err != nil {
-		glog.Errorf("Error signing CSR provided in request from agent: %v", err)
-		http.Error(w, "Error signing csr", http.StatusBadRequest)
+	if allowed, retryAfter := s.limiter.Allow(sourceIP(r), requestCN); !allowed {
+		profile, _ := getProfile(csr, s.profileRules)
+		csrThrottledTotal.WithLabelValues(profile).Inc()
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+		http.Error(w, "too many signing requests for this identity", http.StatusTooManyRequests)
 		return
 	}
 
-	signedCSR, err := signer.Sign(csr)
+	cloudVerification := "disabled"
+	if requestCN != "" {
+		if _, noop := s.cloudVerifier.(noopCloudVerifier); !noop {
+			if err := s.cloudVerifier.VerifyNodeName(nodeNameFromCN(requestCN)); err != nil {
+				glog.Errorf("Refusing to sign CSR for %q: cloud verification failed: %v", requestCN, err)
+				csrRejectedTotal.WithLabelValues("CloudVerificationFailed").Inc()
+				writeDeniedCSR(w, denyCSR(csr, "CloudVerificationFailed", err.Error()), v1Request, http.StatusForbidden)
+				return
+			}
+			cloudVerification = fmt.Sprintf("passed: node %q verified against its cloud instance group", nodeNameFromCN(requestCN))
+		}
+	}
+
+	if s.store != nil && !s.allowReissueOverride && requestCN != "" {
+		if blocked, existing := hasValidRecordForSubject(s.store, requestCN); blocked {
+			glog.Errorf("Refusing to re-sign CSR for %q: valid certificate %s already on record", requestCN, existing.Serial)
+			csrRejectedTotal.WithLabelValues("AlreadyIssued").Inc()
+			msg := fmt.Sprintf("a valid certificate (serial %s) already exists for this subject; pass the override flag to re-issue", existing.Serial)
+			writeDeniedCSR(w, denyCSR(csr, "AlreadyIssued", msg), v1Request, http.StatusConflict)
+			return
+		}
+	}
+
+	rule := matchProfileRule(s.profileRules, x509CSR, csr)
+	if x509CSR != nil {
+		if err := validateSPIFFEURIs(x509CSR.URIs, rule); err != nil {
+			glog.Errorf("Refusing to sign CSR for %q: %v", requestCN, err)
+			csrRejectedTotal.WithLabelValues("SPIFFEURINotAllowed").Inc()
+			writeDeniedCSR(w, denyCSR(csr, "SPIFFEURINotAllowed", fmt.Sprintf("URI SAN not allowed: %v", err)), v1Request, http.StatusForbidden)
+			return
+		}
+	}
+
+	backendName := backendLocalCA
+	profileName, _ := getProfile(csr, s.profileRules)
+	if rule != nil {
+		profileName = rule.Name
+		if rule.Backend != "" {
+			backendName = rule.Backend
+		}
+	}
+	approvalReason := fmt.Sprintf("signed via %s backend under profile %q", backendName, profileName)
+
+	dedupeKey := csrDedupeKey(csr.Spec.Request)
+	signedCSRVal, err := s.csrDedupe.GetOrCreate(dedupeKey, func() (interface{}, time.Duration, error) {
+		var signedCSR *capi.CertificateSigningRequest
+		var err error
+		if rule != nil && rule.Backend == backendACME {
+			signedCSR, err = s.signACME(rule, x509CSR, csr)
+			if err != nil {
+				csrRejectedTotal.WithLabelValues("ACMEError").Inc()
+				glog.Errorf("Error obtaining certificate from ACME CA for profile %q: %v", rule.Name, err)
+				return nil, 0, err
+			}
+			csrSignedTotal.WithLabelValues(rule.Name).Inc()
+		} else if rule != nil && rule.Backend == backendVault {
+			signedCSR, err = s.signVault(rule, csr)
+			if err != nil {
+				csrRejectedTotal.WithLabelValues("VaultError").Inc()
+				glog.Errorf("Error obtaining certificate from Vault for profile %q: %v", rule.Name, err)
+				return nil, 0, err
+			}
+			csrSignedTotal.WithLabelValues(rule.Name).Inc()
+		} else {
+			signedCSR, err = s.trySign(csr)
+			if err != nil {
+				if isRecoverable(err) {
+					glog.Errorf("Recoverable error signing CSR %s, queuing for retry: %v", csr.ObjectMeta.Name, err)
+					s.pending.enqueue(csr)
+					return nil, 0, err
+				}
+				csrRejectedTotal.WithLabelValues("SigningError").Inc()
+				glog.Errorf("Error signing CSR provided in request from agent: %v", err)
+				return nil, 0, err
+			}
+			if profile, perr := getProfile(csr, s.profileRules); perr == nil {
+				csrSignedTotal.WithLabelValues(profile).Inc()
+			}
+		}
+		return signedCSR, 0, nil
+	})
 	if err != nil {
-		glog.Errorf("Error signing CSR provided in request from agent: %v", err)
-		http.Error(w, "Error signing csr", http.StatusBadRequest)
+		if isRecoverable(err) {
+			w.Header().Set("Retry-After", "10")
+			http.Error(w, "signing temporarily unavailable, retry later", http.StatusAccepted)
+			return
+		}
+		writeDeniedCSR(w, denyCSR(csr, "SigningError", err.Error()), v1Request, http.StatusBadRequest)
 		return
 	}
+	signedCSR := signedCSRVal.(*capi.CertificateSigningRequest)
 
-	csrBytes, err := json.Marshal(signedCSR)
+	if s.store != nil && len(signedCSR.Status.Certificate) > 0 {
+		if err := recordIssuedCertificate(s.store, signedCSR, approvalReason, cloudVerification); err != nil {
+			glog.Errorf("Error recording issued certificate for %s: %v", signedCSR.ObjectMeta.Name, err)
+		}
+	}
+
+	csrBytes, err := s.writeCSR(signedCSR)
 	if err != nil {
 		glog.Errorf("Error marshalling approved CSR: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// write CSR to disk which will then be served to the agent.
-	csrFile := path.Join(s.csrDir, signedCSR.ObjectMeta.Name)
-	if err := ioutil.WriteFile(csrFile, csrBytes, 0600); err != nil {
-		glog.Errorf("Unable to write to %s: %v", csrFile, err)
+	respBytes := csrBytes
+	if v1Request {
+		respBytes, err = json.Marshal(csrToV1(signedCSR))
+		if err != nil {
+			glog.Errorf("Error marshalling approved CSR as v1: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Send the signed CSR back to the client agent
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write(csrBytes)
+	w.Write(respBytes)
 
 	return
 }
 
-// HandleGetCSR retrieves a CSR from a directory location (`csrDir`) and returns it
-// to an agent.
+// trySign signs csr with the local CA signer, or with the remote CFSSL
+// backend configured for its profile, returning a *recoverableErr (see
+// isRecoverable) for failures that might clear up on retry -- an
+// unreachable CA file/HSM, or a remote signer being unreachable -- so
+// callers can park the CSR instead of failing it outright.
+func (s *CertServer) trySign(csr *capi.CertificateSigningRequest) (*capi.CertificateSigningRequest, error) {
+	signerCA, err := newSignerCA(s.caFiles, s.profileRules, csr)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := NewSigner(signerCA, s.policy, s.profileRules, s.certDB, s.remoteSigners)
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Sign(csr)
+}
+
+// writeCSR marshals signedCSR and writes it to csrDir, where HandleGetCSR
+// (and the agent's poll loop) will find it. If s.csrStore is set, it also
+// records signedCSR there, for handleGetCSR and HandleWatchCSR to serve
+// without relying on csrDir being shared disk. It returns the marshaled
+// bytes.
+func (s *CertServer) writeCSR(signedCSR *capi.CertificateSigningRequest) ([]byte, error) {
+	csrBytes, err := json.Marshal(signedCSR)
+	if err != nil {
+		return nil, err
+	}
+
+	csrFile := path.Join(s.csrDir, signedCSR.ObjectMeta.Name)
+	if err := ioutil.WriteFile(csrFile, csrBytes, 0600); err != nil {
+		glog.Errorf("Unable to write to %s: %v", csrFile, err)
+	}
+
+	if s.csrStore != nil {
+		s.csrStore.Put(signedCSR)
+	}
+
+	return csrBytes, nil
+}
+
+// HandleGetCSR retrieves a CSR from a directory location (`csrDir`) and
+// returns it to an agent in the certificates.k8s.io/v1beta1 shape it was
+// stored in -- the signer's internal, on-disk representation regardless of
+// which API version the original HandlePostCSR request used.
 func (s *CertServer) HandleGetCSR(w http.ResponseWriter, r *http.Request) {
+	s.handleGetCSR(w, r, false)
+}
+
+// HandleGetCSRV1 is the certificates.k8s.io/v1 counterpart of HandleGetCSR:
+// the same on-disk lookup, with the stored v1beta1-shaped CSR converted to
+// v1 before being returned.
+func (s *CertServer) HandleGetCSRV1(w http.ResponseWriter, r *http.Request) {
+	s.handleGetCSR(w, r, true)
+}
+
+func (s *CertServer) handleGetCSR(w http.ResponseWriter, r *http.Request, v1Response bool) {
 	vars := mux.Vars(r)
 	csrName := vars["csrName"]
 
-	if _, err := os.Stat(filepath.Join(s.csrDir, csrName)); os.IsNotExist(err) {
-		// csr file does not exist in `csrDir`
-		http.Error(w, "CSR not found with given CSR name"+csrName, http.StatusNotFound)
-		return
+	var data []byte
+	if s.csrStore != nil {
+		if csr, ok := s.csrStore.Get(csrName); ok {
+			marshaled, err := json.Marshal(csr)
+			if err != nil {
+				glog.Errorf("Error marshalling stored CSR %s: %v", csrName, err)
+				http.Error(w, "error reading CSR from store", http.StatusInternalServerError)
+				return
+			}
+			data = marshaled
+		}
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(s.csrDir, csrName))
-	if err != nil {
-		http.Error(w, "error reading CSR from file", http.StatusInternalServerError)
-		return
+	if data == nil {
+		if _, err := os.Stat(filepath.Join(s.csrDir, csrName)); os.IsNotExist(err) {
+			if s.pending.has(csrName) {
+				// still being retried by the background reconciler; tell the
+				// agent to keep polling rather than treat this as a failure.
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"status": "pending"})
+				return
+			}
+			// csr file does not exist in `csrDir`
+			http.Error(w, "CSR not found with given CSR name"+csrName, http.StatusNotFound)
+			return
+		}
+
+		fileData, err := ioutil.ReadFile(filepath.Join(s.csrDir, csrName))
+		if err != nil {
+			http.Error(w, "error reading CSR from file", http.StatusInternalServerError)
+			return
+		}
+		data = fileData
 	}
+
+	if v1Response {
+		var csr capi.CertificateSigningRequest
+		if err := json.Unmarshal(data, &csr); err != nil {
+			glog.Errorf("Error decoding stored CSR %s: %v", csrName, err)
+			http.Error(w, "error reading CSR from file", http.StatusInternalServerError)
+			return
+		}
+		v1Data, err := json.Marshal(csrToV1(&csr))
+		if err != nil {
+			glog.Errorf("Error marshalling stored CSR %s as v1: %v", csrName, err)
+			http.Error(w, "error reading CSR from file", http.StatusInternalServerError)
+			return
+		}
+		data = v1Data
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.Write(data)
 	return
 }
 
+// csrWatchEvent is the single JSON object streamed by HandleWatchCSR. It
+// mirrors the Type/Object shape of a real Kubernetes watch.Event (rather
+// than being a bespoke format), so a client already speaking the watch
+// protocol doesn't need a special case for this endpoint.
+type csrWatchEvent struct {
+	Type   string                            `json:"type"`
+	Object *certv1.CertificateSigningRequest `json:"object"`
+}
+
+// HandleWatchCSR streams a signed CSR to an agent as soon as it's
+// available, instead of requiring the agent to poll HandleGetCSR. It is
+// registered on the certificates.k8s.io/v1 collection route with
+// ?watch=true, the same query parameter client-go itself uses for a watch.
+// Unlike a full API server watch, it only watches a single CSR by name, via
+// fieldSelector=metadata.name=<name> (again matching how client-go scopes a
+// single-object watch), and closes the connection once that CSR is
+// delivered -- a signer's CSRs never change again once signed.
+//
+// It requires Config.CSRStore; without one, there is nothing to watch, so
+// it returns http.StatusNotImplemented.
+func (s *CertServer) HandleWatchCSR(w http.ResponseWriter, r *http.Request) {
+	if s.csrStore == nil {
+		http.Error(w, "watch is not enabled on this signer", http.StatusNotImplemented)
+		return
+	}
+
+	csrName := csrNameFromFieldSelector(r.URL.Query().Get("fieldSelector"))
+	if csrName == "" {
+		http.Error(w, "watch requires fieldSelector=metadata.name=<csr name>", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, cancel := s.csrStore.Watch(csrName)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	select {
+	case csr, ok := <-ch:
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(csrWatchEvent{Type: "MODIFIED", Object: csrToV1(csr)})
+		flusher.Flush()
+	case <-r.Context().Done():
+	}
+}
+
+// csrNameFromFieldSelector extracts name from a "metadata.name=name" field
+// selector, the only form HandleWatchCSR supports, returning "" if selector
+// doesn't have that shape.
+func csrNameFromFieldSelector(selector string) string {
+	const prefix = "metadata.name="
+	if !strings.HasPrefix(selector, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(selector, prefix)
+}
+
+// sourceIP extracts the caller's IP address from a request, preferring
+// X-Forwarded-For if present (the signer is commonly fronted by a proxy)
+// and falling back to RemoteAddr.
+func sourceIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// hasValidRecordForSubject reports whether the store holds a currently-valid,
+// non-expired, non-revoked certificate for the given subject CN.
+func hasValidRecordForSubject(s store.Store, subject string) (bool, store.Record) {
+	records, err := s.FindBySubject(subject)
+	if err != nil {
+		glog.Errorf("error querying store for subject %q: %v", subject, err)
+		return false, store.Record{}
+	}
+	for _, r := range records {
+		if r.Valid() {
+			return true, r
+		}
+	}
+	return false, store.Record{}
+}
+
+// recordIssuedCertificate parses the freshly signed certificate out of csr
+// and persists a store.Record for it, along with approvalReason and
+// cloudVerification as an audit trail of why the signer approved it.
+func recordIssuedCertificate(s store.Store, csr *capi.CertificateSigningRequest, approvalReason, cloudVerification string) error {
+	cert, err := helpers.ParseCertificatePEM(csr.Status.Certificate)
+	if err != nil {
+		return fmt.Errorf("error parsing issued certificate: %v", err)
+	}
+	profile, _ := getProfile(csr, nil)
+	record := store.RecordFromCertificate(cert, profile, csr.Spec.Username)
+	record.ApprovalReason = approvalReason
+	record.CloudVerification = cloudVerification
+	return s.Record(record)
+}
+
+// HandleListIssued returns the issued-certificate records known to the
+// store, optionally filtered by `?profile=`.
+func (s *CertServer) HandleListIssued(w http.ResponseWriter, r *http.Request) {
+	records, err := s.store.List()
+	if err != nil {
+		http.Error(w, "error listing issued certificates", http.StatusInternalServerError)
+		return
+	}
+
+	if profile := r.URL.Query().Get("profile"); profile != "" {
+		filtered := records[:0]
+		for _, rec := range records {
+			if rec.Profile == profile {
+				filtered = append(filtered, rec)
+			}
+		}
+		records = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(records); err != nil {
+		glog.Errorf("error encoding issued certificate list: %v", err)
+	}
+}
+
+// HandleRevoke marks a serial as revoked in the store.
+func (s *CertServer) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	serial := mux.Vars(r)["serial"]
+	if err := s.store.Revoke(serial); err != nil {
+		if err == store.ErrNotFound {
+			http.Error(w, "serial not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "error revoking certificate", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 // HandleHealthCheck handles health check
 func HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Length", "0")
@@ -466,13 +1645,22 @@ func StartSignerServer(c Config) error {
 	}
 	tlsconfig.BuildNameToCertificate()
 
-	// start insecure health check server
-	insecureHCMux := mux.NewRouter()
-	insecureHCMux.HandleFunc("/readyz", HandleHealthCheck).Methods("GET", "HEAD")
-	go (&http.Server{
-		Handler: &loggingHandler{insecureHCMux},
-		Addr:    c.InsecureHealthCheckAddress,
-	}).ListenAndServe()
+	if !c.DisableHealthCheck {
+		// start insecure health check server
+		insecureHCMux := mux.NewRouter()
+		insecureHCMux.HandleFunc("/readyz", HandleHealthCheck).Methods("GET", "HEAD")
+		go (&http.Server{
+			Handler: &loggingHandler{insecureHCMux},
+			Addr:    c.InsecureHealthCheckAddress,
+		}).ListenAndServe()
+	}
+
+	pendingRetryInterval := c.PendingRetryInterval
+	if pendingRetryInterval <= 0 {
+		pendingRetryInterval = 10 * time.Second
+	}
+	go s.startPendingReconciler(pendingRetryInterval, c.PendingMaxAge)
+	go startCertExpiryMonitor(&c.SignerCAFiles, c.ServerCertKeys)
 
 	return (&http.Server{
 		TLSConfig: tlsconfig,
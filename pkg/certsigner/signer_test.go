@@ -5,12 +5,23 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	certv1 "k8s.io/api/certificates/v1"
 	capi "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	csrutil "k8s.io/client-go/util/certificate/csr"
+
+	"github.com/coreos/kubecsr/pkg/certsigner/store"
+	"github.com/coreos/kubecsr/pkg/util/timedcache"
 )
 
 var (
@@ -429,3 +440,410 @@ func gotError(err error) string {
 		return fmt.Sprintf("invalid type: %v", t)
 	}
 }
+
+func TestValidateSPIFFEURIs(t *testing.T) {
+	mustParseURI := func(raw string) *url.URL {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", raw, err)
+		}
+		return u
+	}
+
+	rule := &ProfileRule{
+		SPIFFETrustDomain:   "cluster.local",
+		SPIFFEWorkloadPaths: []string{"/etcd/peer/"},
+	}
+
+	for _, test := range []struct {
+		name string
+		uris []*url.URL
+		rule *ProfileRule
+		want string
+	}{
+		{"no rule configured", []*url.URL{mustParseURI("spiffe://evil.example/anything")}, nil, "ok"},
+		{"no URI SANs", nil, rule, "ok"},
+		{"allowed SPIFFE ID", []*url.URL{mustParseURI("spiffe://cluster.local/etcd/peer/node-1")}, rule, "ok"},
+		{"wrong scheme", []*url.URL{mustParseURI("https://cluster.local/etcd/peer/node-1")}, rule, "error"},
+		{"wrong trust domain", []*url.URL{mustParseURI("spiffe://evil.example/etcd/peer/node-1")}, rule, "error"},
+		{"wrong workload path", []*url.URL{mustParseURI("spiffe://cluster.local/other/node-1")}, rule, "error"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := gotError(validateSPIFFEURIs(test.uris, test.rule)); got != test.want {
+				t.Errorf("validateSPIFFEURIs() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCSRDedupeKey(t *testing.T) {
+	a := []byte("-----BEGIN CERTIFICATE REQUEST-----\nAAAA\n-----END CERTIFICATE REQUEST-----\n")
+	b := []byte("-----BEGIN CERTIFICATE REQUEST-----\nBBBB\n-----END CERTIFICATE REQUEST-----\n")
+
+	if csrDedupeKey(a) != csrDedupeKey(a) {
+		t.Error("csrDedupeKey() is not deterministic for identical input")
+	}
+	if csrDedupeKey(a) == csrDedupeKey(b) {
+		t.Error("csrDedupeKey() collided for two different CSRs")
+	}
+}
+
+// TestCSRDedupeBurst demonstrates the property HandlePostCSR relies on
+// s.csrDedupe for: a burst of concurrent signings of the byte-identical
+// CSR -- the shape of CertAgent.RequestCertificate's retry path, which
+// always resubmits the same generated CSR object -- results in exactly
+// one signing operation, with every other caller in the burst receiving
+// the same cached *capi.CertificateSigningRequest.
+func TestCSRDedupeBurst(t *testing.T) {
+	dedupe := timedcache.New(time.Minute)
+	csrBytesA := []byte("csr-a")
+	csrBytesB := []byte("csr-b")
+
+	var signingsA, signingsB int32
+	sign := func(csrRequest []byte, calls *int32) *capi.CertificateSigningRequest {
+		key := csrDedupeKey(csrRequest)
+		v, err := dedupe.GetOrCreate(key, func() (interface{}, time.Duration, error) {
+			atomic.AddInt32(calls, 1)
+			return &capi.CertificateSigningRequest{
+				Status: capi.CertificateSigningRequestStatus{Certificate: csrRequest},
+			}, 0, nil
+		})
+		if err != nil {
+			t.Fatalf("GetOrCreate() error = %v", err)
+		}
+		return v.(*capi.CertificateSigningRequest)
+	}
+
+	const burst = 25
+	var wg sync.WaitGroup
+	wg.Add(2 * burst)
+	for i := 0; i < burst; i++ {
+		go func() {
+			defer wg.Done()
+			sign(csrBytesA, &signingsA)
+		}()
+		go func() {
+			defer wg.Done()
+			sign(csrBytesB, &signingsB)
+		}()
+	}
+	wg.Wait()
+
+	if signingsA != 1 {
+		t.Errorf("%d signing operations for a burst of %d identical CSR-A requests, want 1", signingsA, burst)
+	}
+	if signingsB != 1 {
+		t.Errorf("%d signing operations for a burst of %d identical CSR-B requests, want 1", signingsB, burst)
+	}
+
+	got := sign(csrBytesA, &signingsA)
+	if string(got.Status.Certificate) != string(csrBytesA) {
+		t.Errorf("cached result = %q, want %q", got.Status.Certificate, csrBytesA)
+	}
+	if signingsA != 1 {
+		t.Errorf("a later call for an already-cached CSR re-ran the signing operation")
+	}
+}
+
+func TestLoadRevokedSerialsFile(t *testing.T) {
+	if _, err := LoadRevokedSerialsFile(""); err != nil {
+		t.Errorf("empty path: unexpected error %v", err)
+	}
+	if revoked, err := LoadRevokedSerialsFile("no-such-revoked-serials-file"); err != nil || len(revoked) != 0 {
+		t.Errorf("missing file: got (%v, %v), want (empty map, nil)", revoked, err)
+	}
+
+	f, err := ioutil.TempFile("", "revoked-serials")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprintln(f, "# comment, then a blank line")
+	fmt.Fprintln(f, "")
+	fmt.Fprintln(f, "1a")
+	fmt.Fprintln(f, "2b 2020-01-01T00:00:00Z")
+	if err := f.Close(); err != nil {
+		t.Fatalf("error closing temp file: %v", err)
+	}
+
+	revoked, err := LoadRevokedSerialsFile(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := revoked["1a"]; !ok {
+		t.Errorf("serial 1a with no timestamp: not recorded as revoked")
+	}
+	want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := revoked["2b"]; !got.Equal(want) {
+		t.Errorf("serial 2b revokedAt = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateCRL(t *testing.T) {
+	loadAllCrts(t)
+	defer cleanUp(SignerCAFiles{CACert: caCrtFile, CAKey: caKeyFile})
+
+	cas, err := LoadRevokeCAs(&SignerCAFiles{CACert: caCrtFile, CAKey: caKeyFile})
+	if err != nil {
+		t.Fatalf("error loading CA: %v", err)
+	}
+	if len(cas) != 1 {
+		t.Fatalf("len(cas) = %d, want 1", len(cas))
+	}
+	ca := cas[0]
+
+	records := []store.Record{
+		{Serial: "1", Profile: etcdPeer, Revoked: true, RevokedAt: time.Now()},
+		{Serial: "2", Profile: etcdPeer, Revoked: false},
+		{Serial: "3", Profile: etcdMetric, Revoked: true, RevokedAt: time.Now()},
+	}
+	adminRevoked := map[string]time.Time{"4": time.Now()}
+
+	der, err := GenerateCRL(ca, records, adminRevoked)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	crl, err := x509.ParseCRL(der)
+	if err != nil {
+		t.Fatalf("error parsing generated CRL: %v", err)
+	}
+
+	gotSerials := map[string]bool{}
+	for _, rc := range crl.TBSCertList.RevokedCertificates {
+		gotSerials[rc.SerialNumber.Text(16)] = true
+	}
+	// Serial "1" (EtcdPeer, covered by the root CA) and "4" (admin-revoked,
+	// applied unconditionally) should be present; "2" (not revoked) and "3"
+	// (EtcdMetric, not covered by the root CA) should not be.
+	if !gotSerials["1"] || !gotSerials["4"] {
+		t.Errorf("CRL revoked serials = %v, want 1 and 4 present", gotSerials)
+	}
+	if gotSerials["2"] || gotSerials["3"] {
+		t.Errorf("CRL revoked serials = %v, want 2 and 3 absent", gotSerials)
+	}
+}
+
+func TestMatchProfileRuleSignerName(t *testing.T) {
+	csr := createCSR(csrBytes)
+	x509CSR, err := csrutil.ParseCSR(csr)
+	if err != nil {
+		t.Fatalf("error parsing CSR: %v", err)
+	}
+
+	signerName := "kubecsr.coreos.com/etcd-peer"
+	rules := []ProfileRule{{Name: "bound-to-signer", SignerName: signerName}}
+
+	if got := matchProfileRule(rules, x509CSR, nil); got != nil {
+		t.Errorf("nil csr: matchProfileRule() = %v, want nil", got)
+	}
+	if got := matchProfileRule(rules, x509CSR, csr); got != nil {
+		t.Errorf("csr with no signerName: matchProfileRule() = %v, want nil", got)
+	}
+
+	csr.Spec.SignerName = &signerName
+	got := matchProfileRule(rules, x509CSR, csr)
+	if got == nil || got.Name != "bound-to-signer" {
+		t.Errorf("matchProfileRule() = %v, want rule %q", got, "bound-to-signer")
+	}
+
+	other := "some-other-signer"
+	csr.Spec.SignerName = &other
+	if got := matchProfileRule(rules, x509CSR, csr); got != nil {
+		t.Errorf("mismatched signerName: matchProfileRule() = %v, want nil", got)
+	}
+}
+
+// TestMatchProfileRuleNilX509CSR confirms a rule that matches on x509
+// fields (Organization or a CN regexp) never matches -- and never
+// dereferences -- a nil x509CSR, the case when csrutil.ParseCSR failed to
+// parse malformed CSR request bytes.
+func TestMatchProfileRuleNilX509CSR(t *testing.T) {
+	csr := createCSR(csrBytes)
+
+	rules := []ProfileRule{{Name: "org-bound", Organization: "system:etcd-peers"}}
+	if got := matchProfileRule(rules, nil, csr); got != nil {
+		t.Errorf("Organization rule with nil x509CSR: matchProfileRule() = %v, want nil", got)
+	}
+
+	cnRule := ProfileRule{Name: "cn-bound", cnRegexp: regexp.MustCompile("^system:etcd-peer:")}
+	if got := matchProfileRule([]ProfileRule{cnRule}, nil, csr); got != nil {
+		t.Errorf("CN regexp rule with nil x509CSR: matchProfileRule() = %v, want nil", got)
+	}
+}
+
+func TestCSRV1RoundTrip(t *testing.T) {
+	signerName := "kubecsr.coreos.com/etcd-peer"
+	in := createCSR(csrBytes)
+	in.Name = "etcd-peer-1"
+	in.Spec.SignerName = &signerName
+	in.Status.Conditions = []capi.CertificateSigningRequestCondition{{
+		Type:    capi.CertificateApproved,
+		Reason:  "AutoApproved",
+		Message: "approved by certsigner",
+	}}
+
+	v1CSR := csrToV1(in)
+	if v1CSR.Name != in.Name {
+		t.Errorf("csrToV1() Name = %q, want %q", v1CSR.Name, in.Name)
+	}
+	if v1CSR.Spec.SignerName != signerName {
+		t.Errorf("csrToV1() SignerName = %q, want %q", v1CSR.Spec.SignerName, signerName)
+	}
+	if len(v1CSR.Status.Conditions) != 1 || v1CSR.Status.Conditions[0].Status != corev1.ConditionTrue {
+		t.Errorf("csrToV1() Conditions = %v, want one condition with Status True", v1CSR.Status.Conditions)
+	}
+
+	back := csrFromV1(v1CSR)
+	if back.Name != in.Name {
+		t.Errorf("csrFromV1(csrToV1()) Name = %q, want %q", back.Name, in.Name)
+	}
+	if back.Spec.SignerName == nil || *back.Spec.SignerName != signerName {
+		t.Errorf("csrFromV1(csrToV1()) SignerName = %v, want %q", back.Spec.SignerName, signerName)
+	}
+	if len(back.Status.Conditions) != 1 || back.Status.Conditions[0].Reason != "AutoApproved" {
+		t.Errorf("csrFromV1(csrToV1()) Conditions = %v, want one AutoApproved condition", back.Status.Conditions)
+	}
+}
+
+// TestCSRFromV1Empty confirms csrFromV1 leaves nil slices/maps nil rather
+// than producing empty-but-non-nil ones, matching the rest of the signer's
+// conversion helpers (e.g. usagesFromV1).
+func TestCSRFromV1Empty(t *testing.T) {
+	out := csrFromV1(&certv1.CertificateSigningRequest{})
+	if out.Spec.Usages != nil || out.Spec.Extra != nil || out.Status.Conditions != nil {
+		t.Errorf("csrFromV1(empty) = %+v, want nil Usages/Extra/Conditions", out.Spec)
+	}
+}
+
+func TestMemoryCSRStoreGetPut(t *testing.T) {
+	s := NewMemoryCSRStore(time.Minute)
+
+	if _, ok := s.Get("foo"); ok {
+		t.Fatalf("Get on empty store: ok = true, want false")
+	}
+
+	csr := &capi.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+	s.Put(csr)
+
+	got, ok := s.Get("foo")
+	if !ok || got != csr {
+		t.Fatalf("Get(%q) = (%v, %v), want (%v, true)", "foo", got, ok, csr)
+	}
+}
+
+func TestMemoryCSRStoreWatchAlreadyPresent(t *testing.T) {
+	s := NewMemoryCSRStore(time.Minute)
+	csr := &capi.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+	s.Put(csr)
+
+	ch, cancel := s.Watch("foo")
+	defer cancel()
+
+	select {
+	case got, ok := <-ch:
+		if !ok || got != csr {
+			t.Fatalf("Watch(%q) = (%v, %v), want (%v, true)", "foo", got, ok, csr)
+		}
+	default:
+		t.Fatalf("Watch on an already-Put CSR did not deliver it immediately")
+	}
+}
+
+func TestMemoryCSRStoreWatchBeforePut(t *testing.T) {
+	s := NewMemoryCSRStore(time.Minute)
+	ch, cancel := s.Watch("foo")
+	defer cancel()
+
+	select {
+	case <-ch:
+		t.Fatalf("Watch fired before Put")
+	default:
+	}
+
+	csr := &capi.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+	s.Put(csr)
+
+	select {
+	case got, ok := <-ch:
+		if !ok || got != csr {
+			t.Fatalf("Watch(%q) = (%v, %v), want (%v, true)", "foo", got, ok, csr)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Watch did not fire after Put")
+	}
+}
+
+func TestRequestedCertDuration(t *testing.T) {
+	rule := &ProfileRule{
+		Name:            "bootstrap",
+		CertDuration:    time.Hour,
+		MinCertDuration: 5 * time.Minute,
+		MaxCertDuration: 2 * time.Hour,
+	}
+
+	cases := []struct {
+		name       string
+		rule       *ProfileRule
+		annotation string
+		want       time.Duration
+		wantErr    bool
+	}{
+		{name: "no rule", rule: nil, annotation: "10m", want: 0},
+		{name: "no annotation", rule: rule, want: 0},
+		{name: "within bounds", rule: rule, annotation: "30m", want: 30 * time.Minute},
+		{name: "clamped to max", rule: rule, annotation: "10h", want: 2 * time.Hour},
+		{name: "clamped to min", rule: rule, annotation: "1m", want: 5 * time.Minute},
+		{name: "invalid duration", rule: rule, annotation: "not-a-duration", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			csr := &capi.CertificateSigningRequest{}
+			if c.annotation != "" {
+				csr.ObjectMeta.Annotations = map[string]string{requestDurationAnnotation: c.annotation}
+			}
+
+			got, err := requestedCertDuration(csr, c.rule)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("requestedCertDuration() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestSetRequestedDurationFromExpirationSeconds confirms a v1
+// spec.expirationSeconds populates the annotation, but doesn't override an
+// annotation the caller already set directly.
+func TestSetRequestedDurationFromExpirationSeconds(t *testing.T) {
+	seconds := int32(600)
+
+	csr := &capi.CertificateSigningRequest{}
+	setRequestedDurationFromExpirationSeconds(csr, &seconds)
+	if got, want := csr.ObjectMeta.Annotations[requestDurationAnnotation], (10 * time.Minute).String(); got != want {
+		t.Errorf("annotation = %q, want %q", got, want)
+	}
+
+	csr2 := &capi.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{requestDurationAnnotation: "5m"}},
+	}
+	setRequestedDurationFromExpirationSeconds(csr2, &seconds)
+	if got, want := csr2.ObjectMeta.Annotations[requestDurationAnnotation], "5m"; got != want {
+		t.Errorf("annotation = %q, want unchanged %q", got, want)
+	}
+
+	csr3 := &capi.CertificateSigningRequest{}
+	setRequestedDurationFromExpirationSeconds(csr3, nil)
+	if csr3.ObjectMeta.Annotations != nil {
+		t.Errorf("expected no annotations set for a nil expirationSeconds, got %v", csr3.ObjectMeta.Annotations)
+	}
+}
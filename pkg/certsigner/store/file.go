@@ -0,0 +1,167 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileLogEntry is a single line of a FileStore's append-only log: either a
+// newly issued Record (Op == "issue") or a later revocation of a serial
+// already on the log (Op == "revoke").
+type fileLogEntry struct {
+	Op        string    `json:"op"`
+	Record    *Record   `json:"record,omitempty"`
+	Serial    string    `json:"serial,omitempty"`
+	RevokedAt time.Time `json:"revokedAt,omitempty"`
+}
+
+// FileStore is an append-only, file-backed Store: every Record and Revoke
+// call appends one JSON line to the file at its path, and the full history
+// is replayed into memory on open so reads don't re-scan the file. It's
+// meant for operators who want a durable, human-auditable issuance log
+// without standing up Mongo, and who drive revocation/CRL generation
+// offline via the `crl` subcommand rather than a live certdb.
+type FileStore struct {
+	mu      sync.Mutex
+	file    *os.File
+	records map[string]Record
+}
+
+// NewFileStore opens (creating if necessary) the append-only log at path
+// and replays it into memory.
+func NewFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log %q: %v", path, err)
+	}
+
+	s := &FileStore{file: f, records: map[string]Record{}}
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error replaying audit log %q: %v", path, err)
+	}
+	return s, nil
+}
+
+// replay reads every line already in s.file and applies it to s.records.
+func (s *FileStore) replay() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(s.file)
+	// Audit log lines can be long (SANs, etc); grow past bufio's 64KiB default.
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry fileLogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("error decoding log line: %v", err)
+		}
+		switch entry.Op {
+		case "issue":
+			if entry.Record != nil {
+				s.records[entry.Record.Serial] = *entry.Record
+			}
+		case "revoke":
+			if r, ok := s.records[entry.Serial]; ok {
+				r.Revoked = true
+				r.RevokedAt = entry.RevokedAt
+				s.records[entry.Serial] = r
+			}
+		}
+	}
+	if _, err := s.file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
+
+func (s *FileStore) append(entry fileLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStore) Record(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(fileLogEntry{Op: "issue", Record: &r}); err != nil {
+		return fmt.Errorf("error appending to audit log: %v", err)
+	}
+	s.records[r.Serial] = r
+	return nil
+}
+
+func (s *FileStore) Get(serial string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[serial]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *FileStore) FindBySubject(subject string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []Record
+	for _, r := range s.records {
+		if r.Subject == subject {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *FileStore) List() ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s *FileStore) Revoke(serial string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[serial]
+	if !ok {
+		return ErrNotFound
+	}
+
+	revokedAt := time.Now()
+	if err := s.append(fileLogEntry{Op: "revoke", Serial: serial, RevokedAt: revokedAt}); err != nil {
+		return fmt.Errorf("error appending to audit log: %v", err)
+	}
+	r.Revoked = true
+	r.RevokedAt = revokedAt
+	s.records[serial] = r
+	return nil
+}
+
+// Close closes the underlying log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
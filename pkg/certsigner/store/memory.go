@@ -0,0 +1,70 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store implementation, useful for tests and for
+// single-replica deployments that don't need durability across restarts.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]Record
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (s *MemoryStore) Record(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[r.Serial] = r
+	return nil
+}
+
+func (s *MemoryStore) Get(serial string) (Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[serial]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (s *MemoryStore) FindBySubject(subject string) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Record
+	for _, r := range s.records {
+		if r.Subject == subject {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) List() ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Revoke(serial string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[serial]
+	if !ok {
+		return ErrNotFound
+	}
+	r.Revoked = true
+	r.RevokedAt = time.Now()
+	s.records[serial] = r
+	return nil
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MongoConfig holds the connection settings for the MongoDB-backed Store.
+type MongoConfig struct {
+	// URI is the MongoDB connection string, e.g. mongodb://host:27017.
+	URI string
+	// Database is the database name to use.
+	Database string
+	// Collection is the collection name used to store Records.
+	Collection string
+}
+
+// MongoStore is a Store backed by MongoDB, following the store/mongo pattern
+// used elsewhere for pluggable persistence backends.
+type MongoStore struct {
+	session *mgo.Session
+	coll    string
+	db      string
+}
+
+// NewMongoStore dials the configured MongoDB instance and ensures the serial
+// index used for lookups/upserts exists.
+func NewMongoStore(c MongoConfig) (*MongoStore, error) {
+	session, err := mgo.Dial(c.URI)
+	if err != nil {
+		return nil, err
+	}
+	session.SetMode(mgo.Monotonic, true)
+
+	s := &MongoStore{session: session, db: c.Database, coll: c.Collection}
+	index := mgo.Index{
+		Key:    []string{"serial"},
+		Unique: true,
+	}
+	if err := s.collection().EnsureIndex(index); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MongoStore) collection() *mgo.Collection {
+	return s.session.DB(s.db).C(s.coll)
+}
+
+func (s *MongoStore) Record(r Record) error {
+	_, err := s.collection().Upsert(bson.M{"serial": r.Serial}, r)
+	return err
+}
+
+func (s *MongoStore) Get(serial string) (Record, error) {
+	var r Record
+	if err := s.collection().Find(bson.M{"serial": serial}).One(&r); err != nil {
+		if err == mgo.ErrNotFound {
+			return Record{}, ErrNotFound
+		}
+		return Record{}, err
+	}
+	return r, nil
+}
+
+func (s *MongoStore) FindBySubject(subject string) ([]Record, error) {
+	var records []Record
+	err := s.collection().Find(bson.M{"subject": subject}).Sort("-issuedAt").All(&records)
+	return records, err
+}
+
+func (s *MongoStore) List() ([]Record, error) {
+	var records []Record
+	err := s.collection().Find(nil).All(&records)
+	return records, err
+}
+
+func (s *MongoStore) Revoke(serial string) error {
+	err := s.collection().Update(bson.M{"serial": serial}, bson.M{"$set": bson.M{
+		"revoked":   true,
+		"revokedAt": time.Now(),
+	}})
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+// Package store provides a pluggable persistence layer for certificates
+// issued by the signer, recording issuance/revocation history so operators
+// have a durable audit trail independent of the Kubernetes CSR object.
+package store
+
+import (
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a record cannot be located by serial.
+var ErrNotFound = errors.New("certificate record not found")
+
+// Record captures everything worth auditing about a single issued certificate.
+type Record struct {
+	// Serial is the hex-encoded serial number of the issued certificate.
+	Serial string `json:"serial" bson:"serial"`
+	// Subject is the CN of the issued certificate.
+	Subject string `json:"subject" bson:"subject"`
+	// SANs is the list of DNS/IP subject alternative names on the certificate.
+	SANs []string `json:"sans" bson:"sans"`
+	// Profile is the signing profile used to issue the certificate (EtcdPeer, EtcdServer, ...).
+	Profile string `json:"profile" bson:"profile"`
+	// Requester is the identity (CSR Spec.Username) that requested the certificate.
+	Requester string `json:"requester" bson:"requester"`
+	// IssuedAt is when the certificate was signed.
+	IssuedAt time.Time `json:"issuedAt" bson:"issuedAt"`
+	// NotAfter is the certificate's expiry.
+	NotAfter time.Time `json:"notAfter" bson:"notAfter"`
+	// Revoked is true once the serial has been marked revoked.
+	Revoked bool `json:"revoked" bson:"revoked"`
+	// RevokedAt is when the serial was revoked, if Revoked is true.
+	RevokedAt time.Time `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	// ApprovalReason records why HandlePostCSR approved the request, e.g.
+	// the backend and profile that signed it, for later audit.
+	ApprovalReason string `json:"approvalReason,omitempty" bson:"approvalReason,omitempty"`
+	// CloudVerification records the outcome of CloudVerifier.VerifyNodeName
+	// for this request: "disabled" if no --cloud-provider was configured,
+	// otherwise a description of the successful verification.
+	CloudVerification string `json:"cloudVerification,omitempty" bson:"cloudVerification,omitempty"`
+}
+
+// Valid reports whether the record is neither expired nor revoked as of now.
+func (r Record) Valid() bool {
+	return !r.Revoked && time.Now().Before(r.NotAfter)
+}
+
+// Store persists issued-certificate Records and their revocation state.
+type Store interface {
+	// Record saves a newly issued certificate.
+	Record(r Record) error
+	// Get looks up a record by serial.
+	Get(serial string) (Record, error)
+	// FindBySubject returns every record for a given subject CN, expired and
+	// revoked ones included, so callers can decide whether re-issuance should
+	// be blocked. MongoStore returns them most recent first; MemoryStore and
+	// FileStore return them in no particular order.
+	FindBySubject(subject string) ([]Record, error)
+	// List returns every record known to the store.
+	List() ([]Record, error)
+	// Revoke marks a serial as revoked.
+	Revoke(serial string) error
+}
+
+// RecordFromCertificate builds a Record from a parsed certificate plus the
+// metadata the signer already has on hand (profile, requester).
+func RecordFromCertificate(cert *x509.Certificate, profile, requester string) Record {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return Record{
+		Serial:    cert.SerialNumber.Text(16),
+		Subject:   cert.Subject.CommonName,
+		SANs:      sans,
+		Profile:   profile,
+		Requester: requester,
+		IssuedAt:  cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+}
@@ -0,0 +1,138 @@
+package certsigner
+
+import (
+	certv1 "k8s.io/api/certificates/v1"
+	capi "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// csrFromV1 converts a certificates.k8s.io/v1 CertificateSigningRequest
+// into the v1beta1-shaped type the rest of the signer operates on
+// internally, carrying SignerName across as a pointer (v1beta1's optional
+// field) so matchProfileRule can route on it regardless of which API
+// version a request arrived over.
+func csrFromV1(in *certv1.CertificateSigningRequest) *capi.CertificateSigningRequest {
+	signerName := in.Spec.SignerName
+	return &capi.CertificateSigningRequest{
+		ObjectMeta: in.ObjectMeta,
+		Spec: capi.CertificateSigningRequestSpec{
+			Request:    in.Spec.Request,
+			Usages:     usagesFromV1(in.Spec.Usages),
+			Username:   in.Spec.Username,
+			UID:        in.Spec.UID,
+			Groups:     in.Spec.Groups,
+			Extra:      extraFromV1(in.Spec.Extra),
+			SignerName: &signerName,
+		},
+		Status: capi.CertificateSigningRequestStatus{
+			Certificate: in.Status.Certificate,
+			Conditions:  conditionsFromV1(in.Status.Conditions),
+		},
+	}
+}
+
+// csrToV1 converts the signer's internal v1beta1-shaped type back to
+// certificates.k8s.io/v1, the inverse of csrFromV1, for responding to a
+// request made over the v1 route.
+func csrToV1(in *capi.CertificateSigningRequest) *certv1.CertificateSigningRequest {
+	var signerName string
+	if in.Spec.SignerName != nil {
+		signerName = *in.Spec.SignerName
+	}
+	return &certv1.CertificateSigningRequest{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: in.ObjectMeta,
+		Spec: certv1.CertificateSigningRequestSpec{
+			Request:    in.Spec.Request,
+			SignerName: signerName,
+			Usages:     usagesToV1(in.Spec.Usages),
+			Username:   in.Spec.Username,
+			UID:        in.Spec.UID,
+			Groups:     in.Spec.Groups,
+			Extra:      extraToV1(in.Spec.Extra),
+		},
+		Status: certv1.CertificateSigningRequestStatus{
+			Certificate: in.Status.Certificate,
+			Conditions:  conditionsToV1(in.Status.Conditions),
+		},
+	}
+}
+
+func usagesFromV1(in []certv1.KeyUsage) []capi.KeyUsage {
+	if in == nil {
+		return nil
+	}
+	out := make([]capi.KeyUsage, len(in))
+	for i, u := range in {
+		out[i] = capi.KeyUsage(u)
+	}
+	return out
+}
+
+func usagesToV1(in []capi.KeyUsage) []certv1.KeyUsage {
+	if in == nil {
+		return nil
+	}
+	out := make([]certv1.KeyUsage, len(in))
+	for i, u := range in {
+		out[i] = certv1.KeyUsage(u)
+	}
+	return out
+}
+
+func extraFromV1(in map[string]certv1.ExtraValue) map[string]capi.ExtraValue {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]capi.ExtraValue, len(in))
+	for k, v := range in {
+		out[k] = capi.ExtraValue(v)
+	}
+	return out
+}
+
+func extraToV1(in map[string]capi.ExtraValue) map[string]certv1.ExtraValue {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]certv1.ExtraValue, len(in))
+	for k, v := range in {
+		out[k] = certv1.ExtraValue(v)
+	}
+	return out
+}
+
+func conditionsFromV1(in []certv1.CertificateSigningRequestCondition) []capi.CertificateSigningRequestCondition {
+	if in == nil {
+		return nil
+	}
+	out := make([]capi.CertificateSigningRequestCondition, len(in))
+	for i, c := range in {
+		out[i] = capi.CertificateSigningRequestCondition{
+			Type:    capi.RequestConditionType(c.Type),
+			Reason:  c.Reason,
+			Message: c.Message,
+		}
+	}
+	return out
+}
+
+func conditionsToV1(in []capi.CertificateSigningRequestCondition) []certv1.CertificateSigningRequestCondition {
+	if in == nil {
+		return nil
+	}
+	out := make([]certv1.CertificateSigningRequestCondition, len(in))
+	for i, c := range in {
+		out[i] = certv1.CertificateSigningRequestCondition{
+			Type:    certv1.RequestConditionType(c.Type),
+			Reason:  c.Reason,
+			Message: c.Message,
+			// v1 requires Status on every condition; v1beta1 has no
+			// equivalent field. Every condition this signer produces (the
+			// Approved one on issue, or the Denied one from denyCSR) is in
+			// effect, so Status is always True.
+			Status: corev1.ConditionTrue,
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+// Package vault implements an alternative signer backend that obtains
+// certificates from a HashiCorp Vault PKI secrets engine mount, using its
+// "sign-verbatim" endpoint to sign a CSR that was already generated and
+// validated by the kubecsr signer -- e.g. so the signing key can live in
+// Vault's HSM-backed storage instead of on the signer's own disk.
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Config configures a Vault-backed Issuer.
+type Config struct {
+	// Address is the Vault server's base URL, e.g. "https://vault.example.com:8200".
+	Address string
+	// Token authenticates requests to Vault. kubecsr expects this to be a
+	// pre-provisioned token with permission to sign against Mount/Role; it
+	// does not implement Vault's login flows itself.
+	Token string
+	// Mount is the path the PKI secrets engine is mounted at, e.g. "pki".
+	Mount string
+	// Role is the PKI role to sign against; it constrains which CNs/SANs
+	// Vault will actually issue a certificate for.
+	Role string
+	// Timeout bounds how long a single certificate issuance is allowed to take.
+	Timeout time.Duration
+	// httpClient is overridden in tests; nil uses a new http.Client whose
+	// Timeout is Timeout.
+	httpClient *http.Client
+}
+
+// Issuer signs CSRs by delegating to a Vault PKI secrets engine mount.
+type Issuer struct {
+	cfg    Config
+	client *http.Client
+}
+
+// NewIssuer returns an Issuer that signs against cfg.Address/cfg.Mount/cfg.Role.
+func NewIssuer(cfg Config) (*Issuer, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("vault: Address is required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("vault: Token is required")
+	}
+	if cfg.Mount == "" {
+		return nil, fmt.Errorf("vault: Mount is required")
+	}
+	if cfg.Role == "" {
+		return nil, fmt.Errorf("vault: Role is required")
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	client := cfg.httpClient
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	return &Issuer{cfg: cfg, client: client}, nil
+}
+
+// signVerbatimRequest is the body Vault's pki/sign-verbatim/:role endpoint expects.
+type signVerbatimRequest struct {
+	CSR string `json:"csr"`
+}
+
+// signVerbatimResponse is the subset of Vault's response this Issuer reads.
+type signVerbatimResponse struct {
+	Data struct {
+		Certificate string   `json:"certificate"`
+		CAChain     []string `json:"ca_chain"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// IssueCertificate submits csrPEM (a PEM-encoded CSR) to Vault's
+// sign-verbatim endpoint and returns the PEM-encoded certificate chain,
+// leaf first, that Vault issued for it.
+func (i *Issuer) IssueCertificate(csrPEM []byte) ([]byte, error) {
+	reqBody, err := json.Marshal(signVerbatimRequest{CSR: string(csrPEM)})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling Vault sign request: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/sign-verbatim/%s", strings.TrimRight(i.cfg.Address, "/"), i.cfg.Mount, i.cfg.Role)
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(string(reqBody)))
+	if err != nil {
+		return nil, fmt.Errorf("error building Vault sign request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", i.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling Vault at %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Vault response: %v", err)
+	}
+
+	var sv signVerbatimResponse
+	if err := json.Unmarshal(respBody, &sv); err != nil {
+		return nil, fmt.Errorf("error decoding Vault response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Vault sign-verbatim returned %s: %v", resp.Status, sv.Errors)
+	}
+	if sv.Data.Certificate == "" {
+		return nil, fmt.Errorf("Vault sign-verbatim response had no certificate")
+	}
+
+	chain := sv.Data.Certificate
+	for _, ca := range sv.Data.CAChain {
+		chain += "\n" + ca
+	}
+	return []byte(chain), nil
+}
@@ -0,0 +1,60 @@
+package certsigner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
+)
+
+// CloudVerifier checks that a CSR's requested node name corresponds to a
+// real, running cloud instance in the expected instance group before the
+// signer signs it, so a leaked or forged CSR for a node name that was
+// never actually provisioned (or belongs to someone else's instance
+// group) can't be signed.
+type CloudVerifier interface {
+	// VerifyNodeName returns an error if nodeName does not resolve to an
+	// instance in the expected instance group.
+	VerifyNodeName(nodeName string) error
+}
+
+// noopCloudVerifier is the CloudVerifier used when no --cloud-provider is
+// configured ("none"), preserving the signer's behavior from before
+// CloudVerifier existed.
+type noopCloudVerifier struct{}
+
+func (noopCloudVerifier) VerifyNodeName(string) error { return nil }
+
+// cloudInstanceGroupVerifier resolves a node name through cloud to an
+// instance ID, then to that instance's instance group/ASG/VMSS, and
+// requires it to equal instanceGroup.
+type cloudInstanceGroupVerifier struct {
+	cloud         cloudprovider.Interface
+	instanceGroup string
+}
+
+// NewCloudInstanceGroupVerifier returns a CloudVerifier that rejects any
+// node name whose instance group, as resolved through cloud, isn't
+// instanceGroup.
+func NewCloudInstanceGroupVerifier(cloud cloudprovider.Interface, instanceGroup string) CloudVerifier {
+	return &cloudInstanceGroupVerifier{cloud: cloud, instanceGroup: instanceGroup}
+}
+
+func (v *cloudInstanceGroupVerifier) VerifyNodeName(nodeName string) error {
+	group, err := v.cloud.GetInstanceGroupByNodeName(nodeName)
+	if err != nil {
+		return fmt.Errorf("error resolving instance group for node %q: %v", nodeName, err)
+	}
+	if group != v.instanceGroup {
+		return fmt.Errorf("node %q belongs to instance group %q, not %q", nodeName, group, v.instanceGroup)
+	}
+	return nil
+}
+
+// nodeNameFromCN extracts the node name from an etcd CSR's Subject Common
+// Name (e.g. "system:etcd-peer:node-1" -> "node-1"), the same convention
+// SecretNameForCN's %s substitution relies on.
+func nodeNameFromCN(cn string) string {
+	parts := strings.Split(cn, ":")
+	return parts[len(parts)-1]
+}
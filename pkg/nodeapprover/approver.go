@@ -1,3 +1,11 @@
+// Package approver is the provider-agnostic CSR reconciler: its Approver
+// drives recognizers (see csr.go) off a cloudprovider.Interface and an
+// identity.IdentityProvider rather than calling out to AWS-specific
+// helpers directly, so the same reconciler approves CSRs for AWS, Azure,
+// GCE, bare-metal, or a cloudprovider.MultiCloud mix of them (see
+// cmd/nodeapprover). pkg/approver/aws predates this package and remains
+// the AWS-only reconciler for clusters that only ever run on AWS; new
+// multi-cloud or non-AWS deployments should use this package instead.
 package approver
 
 import (
@@ -7,7 +15,9 @@ import (
 	"github.com/golang/glog"
 	"github.com/juju/ratelimit"
 
+	authorizationv1 "k8s.io/api/authorization/v1"
 	certificates "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,14 +26,19 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	certificatesv1b1listers "k8s.io/client-go/listers/certificates/v1beta1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
+	"github.com/coreos/kubecsr/pkg/nodeapprover/identity"
 )
 
 const (
@@ -38,22 +53,79 @@ type Approver struct {
 
 	nodeLister corelisters.NodeLister
 	nodeSynced cache.InformerSynced
-	csrLister  certificatesv1b1listers.CertificateSigningRequestLister
-	csrSynced  cache.InformerSynced
-	queue      workqueue.RateLimitingInterface
+	// csr abstracts CSR Get/UpdateApproval and the informer that feeds
+	// queue, so the rest of Approver runs the same whether the cluster
+	// serves certificates.k8s.io/v1 or the removed-in-1.22 v1beta1. See
+	// csrclient.go.
+	csr       csrClient
+	csrSynced cache.InformerSynced
+	queue     workqueue.RateLimitingInterface
+
+	// identity is consulted by isValidMaster/isValidWorker to decide
+	// whether a node may request the role it's asking for. It always
+	// includes a cloudprovider-backed identity.IdentityProvider, plus
+	// whatever extra --identity-provider flags New was given, so bare
+	// metal and hybrid clusters can approve node CSRs without a cloud API.
+	identity identity.IdentityProvider
+
+	// events records a Kubernetes Event against each CSR node-csr-approver
+	// approves, in addition to the csrDecisionsTotal metric. It is left
+	// nil by tests that construct an Approver directly rather than via New.
+	events record.EventRecorder
 
 	MasterGroup sets.String
 	WorkerGroup sets.String
+
+	// TokenBindings, when set, requires a bootstrap-credential-requested
+	// node client CSR's "system:bootstrap:<token-id>" requestor to be
+	// bound to the same cloud instance ID the requested node name
+	// resolves to (see isValidTokenBinding), so a leaked or reused
+	// bootstrap token can't mint a certificate for any node other than
+	// the one it was issued for. Left nil by New, which keeps today's
+	// behavior of trusting any token in the right bootstrappers group for
+	// any node name; set it (and run a TokenBindingController to keep it
+	// populated) to opt into one-shot, per-node bootstrap tokens.
+	TokenBindings TokenBindingStore
+
+	// SARGroup, SARResource, and SARVerb set the ResourceAttributes
+	// authorizedForSubresource checks via SubjectAccessReview, alongside
+	// the kind-specific Subresource (selfnodeclient/nodeclient/
+	// nodeserver). New defaults them to the same
+	// certificates.k8s.io/certificatesigningrequests "create" check
+	// upstream kube-controller-manager's CSR approver performs; operators
+	// who want to gate approval on their own RBAC policy instead can
+	// override them on the returned Approver before calling Run.
+	SARGroup    string
+	SARResource string
+	SARVerb     string
 }
 
-func New(client kubernetes.Interface, cloud cloudprovider.Interface) (*Approver, error) {
+// New returns a new Approver. cloudPrefixes pins a node-name prefix (e.g.
+// "ip-" for AWS) to the name of the single cloud, within a
+// cloudprovider.MultiCloud, that should be consulted for it instead of
+// fanning out to every cloud; it may be nil. extraIdentityProviders are
+// consulted, in order, after the cloud-provider-backed identity check, so
+// that a node which the cloud API doesn't recognize can still be approved
+// by a static file, LDAP, or OIDC identity provider.
+func New(client kubernetes.Interface, cloud cloudprovider.Interface, cloudPrefixes map[string]string, extraIdentityProviders ...identity.IdentityProvider) (*Approver, error) {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+
 	ar := &Approver{
 		kubeClient:  client,
 		cloud:       cloud,
+		events:      eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "node-csr-approver"}),
 		MasterGroup: sets.NewString(),
 		WorkerGroup: sets.NewString(),
+
+		SARGroup:    "certificates.k8s.io",
+		SARResource: "certificatesigningrequests",
+		SARVerb:     "create",
 	}
 
+	providers := append([]identity.IdentityProvider{identity.NewCloudProviderIdentity(cloud, ar.MasterGroup, ar.WorkerGroup, cloudPrefixes)}, extraIdentityProviders...)
+	ar.identity = identity.Chain(providers)
+
 	ar.queue = workqueue.NewNamedRateLimitingQueue(workqueue.NewMaxOfRateLimiter(
 		workqueue.NewItemExponentialFailureRateLimiter(200*time.Millisecond, 1000*time.Second),
 		&workqueue.BucketRateLimiter{Bucket: ratelimit.NewBucketWithRate(float64(10), int64(100))},
@@ -65,44 +137,52 @@ func New(client kubernetes.Interface, cloud cloudprovider.Interface) (*Approver,
 	ar.nodeLister = nodeInformer.Lister()
 	ar.nodeSynced = nodeInformer.Informer().HasSynced
 
-	csrInformer := sharedInformer.Certificates().V1beta1().CertificateSigningRequests()
-	// Manage the addition/update of certificate requests
-	csrInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	csr, err := newCSRClient(ar.kubeClient, sharedInformer)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up CSR client: %v", err)
+	}
+	ar.csr = csr
+
+	// Manage the addition/update of certificate requests. These handlers
+	// only need an object's name (via the generic metav1.Object accessor,
+	// not a v1beta1-specific type assertion) so they work unchanged whether
+	// csrClient is backed by the v1 or v1beta1 API.
+	ar.csr.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			csr := obj.(*certificates.CertificateSigningRequest)
-			glog.V(4).Infof("Adding certificate request %s", csr.Name)
+			glog.V(4).Infof("Adding certificate request %s", csrObjectName(obj))
 			ar.enqueueCertificateRequest(obj)
 		},
 		UpdateFunc: func(old, new interface{}) {
-			oldCSR := old.(*certificates.CertificateSigningRequest)
-			glog.V(4).Infof("Updating certificate request %s", oldCSR.Name)
+			glog.V(4).Infof("Updating certificate request %s", csrObjectName(old))
 			ar.enqueueCertificateRequest(new)
 		},
 		DeleteFunc: func(obj interface{}) {
-			csr, ok := obj.(*certificates.CertificateSigningRequest)
-			if !ok {
-				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-				if !ok {
-					glog.V(2).Infof("Couldn't get object from tombstone %#v", obj)
-					return
-				}
-				csr, ok = tombstone.Obj.(*certificates.CertificateSigningRequest)
-				if !ok {
-					glog.V(2).Infof("Tombstone contained object that is not a CSR: %#v", obj)
-					return
-				}
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
 			}
-			glog.V(4).Infof("Deleting certificate request %s", csr.Name)
+			glog.V(4).Infof("Deleting certificate request %s", csrObjectName(obj))
 			ar.enqueueCertificateRequest(obj)
 		},
 	})
-	ar.csrLister = csrInformer.Lister()
-	ar.csrSynced = csrInformer.Informer().HasSynced
+	ar.csrSynced = ar.csr.HasSynced()
 
 	go sharedInformer.Start(wait.NeverStop)
 	return ar, nil
 }
 
+// csrObjectName returns obj's name for logging, regardless of whether it is
+// a v1 or v1beta1 CertificateSigningRequest (or a delete tombstone).
+func csrObjectName(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := apimeta.Accessor(obj)
+	if err != nil {
+		return fmt.Sprintf("%v", obj)
+	}
+	return accessor.GetName()
+}
+
 func (ar *Approver) Run(workers int, stopCh <-chan struct{}) error {
 	defer utilruntime.HandleCrash()
 	defer ar.queue.ShutDown()
@@ -179,7 +259,7 @@ func (ar *Approver) syncFunc(key string) error {
 	defer func() {
 		glog.V(4).Infof("Finished syncing certificate request %q (%v)", key, time.Now().Sub(startTime))
 	}()
-	csr, err := ar.csrLister.Get(key)
+	csr, err := ar.csr.Get(key)
 	if apierrors.IsNotFound(err) {
 		glog.V(3).Infof("csr has been deleted: %v", key)
 		return nil
@@ -242,7 +322,11 @@ func (ar *Approver) findInstanceGroupFromSelector(sel labels.Selector) ([]string
 	results := []string{}
 	for _, node := range nodes {
 		glog.V(4).Infof("fetching instance group for node: %s", node.GetName())
-		ig, err := ar.cloud.GetInstanceGroupByNodeName(node.GetName())
+		// These nodes are already registered, so node.Spec.ProviderID is
+		// populated; resolving through it rather than node.GetName()
+		// keeps whitelist building correct in multi-cloud deployments,
+		// where a bare node name alone can't say which cloud to ask.
+		ig, err := ar.cloud.GetInstanceGroupByProviderID(node.Spec.ProviderID)
 		if err != nil {
 			return nil, err
 		}
@@ -252,6 +336,48 @@ func (ar *Approver) findInstanceGroupFromSelector(sel labels.Selector) ([]string
 	return results, nil
 }
 
+const (
+	nodeClientSubresource     = "nodeclient"
+	selfNodeClientSubresource = "selfnodeclient"
+	nodeServerSubresource     = "nodeserver"
+)
+
+// authorizedForSubresource reports whether csr's requester is authorized,
+// via a SubjectAccessReview against the API server, to create a CSR for
+// the given certificates.k8s.io subresource -- mirroring the
+// "nodeclient"/"selfnodeclient"/"nodeserver" subresource checks upstream
+// kube-controller-manager's CSR approver performs before auto-approving.
+// This runs in addition to, not instead of, the cloud-provider instance
+// group and node-identity checks in csr.go.
+func (ar *Approver) authorizedForSubresource(csr *certificates.CertificateSigningRequest, subresource string) bool {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range csr.Spec.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   csr.Spec.Username,
+			UID:    csr.Spec.UID,
+			Groups: csr.Spec.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:       ar.SARGroup,
+				Resource:    ar.SARResource,
+				Subresource: subresource,
+				Verb:        ar.SARVerb,
+			},
+		},
+	}
+
+	result, err := ar.kubeClient.AuthorizationV1().SubjectAccessReviews().Create(sar)
+	if err != nil {
+		glog.Errorf("error creating SubjectAccessReview for %q: %v", csr.Spec.Username, err)
+		return false
+	}
+	return result.Status.Allowed
+}
+
 func (ar *Approver) enqueueCertificateRequest(obj interface{}) {
 	key, err := cache.MetaNamespaceKeyFunc(obj)
 	if err != nil {
@@ -0,0 +1,116 @@
+// Package cloudprovider defines the interface node-csr-approver and the
+// etcd signer's cloud verification use to map a node name to the cloud instance and
+// instance group backing it, plus a registry providers register
+// themselves into by name.
+package cloudprovider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrInstanceNotFound is returned by GetInstanceIDByNodeName when no
+// instance matches the given node name.
+var ErrInstanceNotFound = errors.New("cloudprovider: instance not found")
+
+// ErrInstanceGroupNotFound is returned by GetInstanceGroupByNodeName when
+// no instance group/autoscaling group/VMSS owns the given node name.
+var ErrInstanceGroupNotFound = errors.New("cloudprovider: instance group not found")
+
+// Interface is implemented by every supported cloud backend.
+type Interface interface {
+	// GetInstanceIDByNodeName returns the cloud instance ID backing
+	// nodeName, or ErrInstanceNotFound.
+	GetInstanceIDByNodeName(nodeName string) (string, error)
+	// GetInstanceGroupByNodeName returns the name of the instance
+	// group/ASG/VMSS nodeName belongs to, or ErrInstanceGroupNotFound.
+	// It resolves nodeName to an instance entirely through the cloud
+	// API, so it is the only option while a kubelet is still
+	// bootstrapping and its Node object (and therefore ProviderID)
+	// doesn't exist yet.
+	GetInstanceGroupByNodeName(nodeName string) (string, error)
+	// GetInstanceGroupByProviderID returns the name of the instance
+	// group/ASG/VMSS owning the instance named by providerID (a Node's
+	// Spec.ProviderID, e.g. "aws:///us-west-2a/i-0123abc" or
+	// "gce://project/zone/instance"), or ErrInstanceGroupNotFound. Once a
+	// Node object exists this is the preferred lookup: it works the same
+	// way regardless of which cloud a node-name happens to look like it
+	// belongs to, which matters in federated/multi-cloud deployments.
+	GetInstanceGroupByProviderID(providerID string) (string, error)
+	// GetInstanceAddressesByNodeName returns the IP addresses (private and,
+	// where the cloud assigns one, public) and DNS names the cloud itself
+	// knows for the instance backing nodeName, or ErrInstanceNotFound. This
+	// is consulted by a kubelet-serving CSR's SAN check as a
+	// cloud-API-verified source of truth, since a Node object's
+	// Status.Addresses is self-reported by the kubelet running on that
+	// node and isn't something a compromised/misconfigured node can be
+	// trusted to report honestly about itself.
+	GetInstanceAddressesByNodeName(nodeName string) ([]net.IP, []string, error)
+}
+
+// ParseProviderID splits a Kubernetes Node's Spec.ProviderID into the
+// cloud name that set it and the trailing instance identifier most cloud
+// APIs expect: an instance ID for AWS, an instance/VM name for GCE and
+// Azure. For example "aws:///us-west-2a/i-0123abc" parses to ("aws",
+// "i-0123abc") and "gce://project/zone/instance" parses to ("gce",
+// "instance").
+func ParseProviderID(providerID string) (cloudName, instanceID string, err error) {
+	parts := strings.SplitN(providerID, "://", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("cloudprovider: malformed providerID %q", providerID)
+	}
+	segments := strings.Split(strings.Trim(parts[1], "/"), "/")
+	instanceID = segments[len(segments)-1]
+	if instanceID == "" {
+		return "", "", fmt.Errorf("cloudprovider: malformed providerID %q", providerID)
+	}
+	return parts[0], instanceID, nil
+}
+
+// Factory constructs an Interface from its configuration.
+type Factory func(config io.Reader) (Interface, error)
+
+var (
+	providersMutex sync.Mutex
+	providers      = map[string]Factory{}
+)
+
+// RegisterCloudProvider registers a Factory under name, for later lookup
+// by InitCloudProvider. Providers register themselves from an init
+// function.
+func RegisterCloudProvider(name string, factory Factory) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	if _, found := providers[name]; found {
+		panic(fmt.Sprintf("cloud provider %q was registered twice", name))
+	}
+	providers[name] = factory
+}
+
+// InitCloudProvider looks up the provider registered under name and
+// constructs it from the configuration file at configFilePath, if any.
+func InitCloudProvider(name string, configFilePath string) (Interface, error) {
+	providersMutex.Lock()
+	factory, found := providers[name]
+	providersMutex.Unlock()
+	if !found {
+		return nil, fmt.Errorf("unknown cloud provider %q", name)
+	}
+
+	if configFilePath == "" {
+		return factory(nil)
+	}
+
+	config, err := os.Open(configFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cloud provider config %q: %v", configFilePath, err)
+	}
+	defer config.Close()
+
+	return factory(config)
+}
@@ -1,65 +1,49 @@
 package internal
 
 import (
-	"sync"
 	"time"
 
-	"k8s.io/client-go/tools/cache"
+	"github.com/coreos/kubecsr/pkg/util/timedcache"
 )
 
-type timedcacheEntry struct {
-	key  string
-	data interface{}
-}
-
+// TimedCache is kept here, with its original (value-typed, error-returning
+// GetOrCreate) API, so the existing azure vmInfoCache/negativeCache callers
+// don't need to change. It is now a thin wrapper around the more general
+// timedcache.Cache, which was promoted out of this package so packages
+// with no reason to depend on nodeapprover/cloudprovider (e.g.
+// pkg/certsigner) can use it too.
 type TimedCache struct {
-	store cache.Store
-	lock  sync.Mutex
+	cache *timedcache.Cache
 }
 
 // ttl time.Duration
 func NewTimedCache(ttl time.Duration) TimedCache {
 	return TimedCache{
-		store: cache.NewTTLStore(cacheKeyFunc, ttl),
+		cache: timedcache.New(ttl),
 	}
 }
 
-func cacheKeyFunc(obj interface{}) (string, error) {
-	return obj.(*timedcacheEntry).key, nil
-}
-
 func (t *TimedCache) GetOrCreate(key string, createFunc func() interface{}) (interface{}, error) {
-	entry, exists, err := t.store.GetByKey(key)
-	if err != nil {
-		return nil, err
-	}
-	if exists {
-		return (entry.(*timedcacheEntry)).data, nil
-	}
-
-	t.lock.Lock()
-	defer t.lock.Unlock()
-	entry, exists, err = t.store.GetByKey(key)
-	if err != nil {
-		return nil, err
-	}
-	if exists {
-		return (entry.(*timedcacheEntry)).data, nil
-	}
-
-	if createFunc == nil {
-		return nil, nil
-	}
-	created := createFunc()
-	t.store.Add(&timedcacheEntry{
-		key:  key,
-		data: created,
+	return t.cache.GetOrCreate(key, func() (interface{}, time.Duration, error) {
+		if createFunc == nil {
+			return nil, 0, nil
+		}
+		return createFunc(), 0, nil
 	})
-	return created, nil
 }
 
 func (t *TimedCache) Delete(key string) {
-	_ = t.store.Delete(&timedcacheEntry{
-		key: key,
-	})
+	t.cache.Delete(key)
+}
+
+// Get returns key's cached value, if present and not yet expired by the
+// store's TTL. Unlike GetOrCreate, a miss is simply reported rather than
+// populated.
+func (t *TimedCache) Get(key string) (interface{}, bool) {
+	return t.cache.Get(key)
+}
+
+// Set inserts or overwrites key's cached value, resetting its TTL.
+func (t *TimedCache) Set(key string, value interface{}) {
+	t.cache.Set(key, value)
 }
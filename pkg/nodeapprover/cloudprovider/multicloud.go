@@ -0,0 +1,112 @@
+package cloudprovider
+
+import "net"
+
+// NamedCloud pairs a cloud provider's name with its Interface, so a
+// MultiCloud aggregate can still be addressed by a single member when a
+// caller (e.g. a cloud-specific recognizer) knows which cloud it needs.
+type NamedCloud struct {
+	Name  string
+	Cloud Interface
+}
+
+// MultiCloud aggregates several cloud providers into one Interface. Each
+// lookup fans out to every member in parallel and returns the first
+// non-error result, so a federated cluster spanning multiple clouds can
+// resolve a node regardless of which cloud it actually runs in.
+type MultiCloud []NamedCloud
+
+// NewMultiCloud returns clouds as a single aggregated Interface.
+func NewMultiCloud(clouds ...NamedCloud) MultiCloud {
+	return MultiCloud(clouds)
+}
+
+func (m MultiCloud) GetInstanceIDByNodeName(nodeName string) (string, error) {
+	return m.fanOut(func(c Interface) (string, error) {
+		return c.GetInstanceIDByNodeName(nodeName)
+	})
+}
+
+func (m MultiCloud) GetInstanceGroupByNodeName(nodeName string) (string, error) {
+	return m.fanOut(func(c Interface) (string, error) {
+		return c.GetInstanceGroupByNodeName(nodeName)
+	})
+}
+
+func (m MultiCloud) GetInstanceGroupByProviderID(providerID string) (string, error) {
+	return m.fanOut(func(c Interface) (string, error) {
+		return c.GetInstanceGroupByProviderID(providerID)
+	})
+}
+
+func (m MultiCloud) GetInstanceAddressesByNodeName(nodeName string) ([]net.IP, []string, error) {
+	if len(m) == 1 {
+		return m[0].Cloud.GetInstanceAddressesByNodeName(nodeName)
+	}
+
+	type result struct {
+		ips   []net.IP
+		names []string
+		err   error
+	}
+	results := make(chan result, len(m))
+	for _, c := range m {
+		c := c
+		go func() {
+			ips, names, err := c.Cloud.GetInstanceAddressesByNodeName(nodeName)
+			results <- result{ips: ips, names: names, err: err}
+		}()
+	}
+
+	var lastErr error
+	for i := 0; i < len(m); i++ {
+		r := <-results
+		if r.err == nil {
+			return r.ips, r.names, nil
+		}
+		lastErr = r.err
+	}
+	return nil, nil, lastErr
+}
+
+// Named returns the single member registered under name, if any -- used
+// to route a CSR with a cloud-specific CommonName prefix directly to its
+// cloud instead of fanning out to every member.
+func (m MultiCloud) Named(name string) (Interface, bool) {
+	for _, c := range m {
+		if c.Name == name {
+			return c.Cloud, true
+		}
+	}
+	return nil, false
+}
+
+type cloudLookupResult struct {
+	value string
+	err   error
+}
+
+func (m MultiCloud) fanOut(call func(Interface) (string, error)) (string, error) {
+	if len(m) == 1 {
+		return call(m[0].Cloud)
+	}
+
+	results := make(chan cloudLookupResult, len(m))
+	for _, c := range m {
+		c := c
+		go func() {
+			v, err := call(c.Cloud)
+			results <- cloudLookupResult{value: v, err: err}
+		}()
+	}
+
+	var lastErr error
+	for i := 0; i < len(m); i++ {
+		r := <-results
+		if r.err == nil {
+			return r.value, nil
+		}
+		lastErr = r.err
+	}
+	return "", lastErr
+}
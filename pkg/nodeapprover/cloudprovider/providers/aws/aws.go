@@ -3,6 +3,7 @@ package aws
 import (
 	"fmt"
 	"io"
+	"net"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -93,6 +94,79 @@ func (c *Cloud) GetInstanceGroupByNodeName(nodeName string) (string, error) {
 	return aws.StringValue(instances[0].AutoScalingGroupName), nil
 }
 
+// GetInstanceGroupByProviderID is the providerID-keyed equivalent of
+// GetInstanceGroupByNodeName: it skips the private-dns-name lookup
+// entirely, since the instance ID is already encoded in providerID (e.g.
+// "aws:///us-west-2a/i-0123abc").
+func (c *Cloud) GetInstanceGroupByProviderID(providerID string) (string, error) {
+	cloudName, instanceID, err := cloudprovider.ParseProviderID(providerID)
+	if err != nil {
+		return "", err
+	}
+	if cloudName != ProviderName {
+		return "", fmt.Errorf("providerID %q is not an %s instance", providerID, ProviderName)
+	}
+
+	reqASI := &autoscaling.DescribeAutoScalingInstancesInput{
+		InstanceIds: []*string{
+			aws.String(instanceID),
+		},
+	}
+	instances, err := c.describeAutoScalingInstances(reqASI)
+	if err != nil {
+		return "", err
+	}
+	if len(instances) == 0 {
+		return "", cloudprovider.ErrInstanceGroupNotFound
+	}
+	if len(instances) > 1 {
+		return "", fmt.Errorf("multiple auto scaling instances found for instance id: %s", instanceID)
+	}
+
+	return aws.StringValue(instances[0].AutoScalingGroupName), nil
+}
+
+// GetInstanceAddressesByNodeName returns the private (and, if assigned,
+// public) IP and the private/public DNS names EC2 reports for the
+// instance named by nodeName.
+func (c *Cloud) GetInstanceAddressesByNodeName(nodeName string) ([]net.IP, []string, error) {
+	privateDNSName := nodeName
+	filters := []*ec2.Filter{
+		newEc2Filter("private-dns-name", privateDNSName),
+		newEc2Filter("instance-state-name", "running"),
+	}
+	req := &ec2.DescribeInstancesInput{
+		Filters: filters,
+	}
+	instances, err := c.describeInstances(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(instances) == 0 {
+		return nil, nil, cloudprovider.ErrInstanceNotFound
+	}
+	if len(instances) > 1 {
+		return nil, nil, fmt.Errorf("multiple instances found for name: %s", nodeName)
+	}
+	instance := instances[0]
+
+	var ips []net.IP
+	for _, addr := range []string{aws.StringValue(instance.PrivateIpAddress), aws.StringValue(instance.PublicIpAddress)} {
+		if ip := net.ParseIP(addr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	var names []string
+	for _, name := range []string{aws.StringValue(instance.PrivateDnsName), aws.StringValue(instance.PublicDnsName)} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return ips, names, nil
+}
+
 func (c *Cloud) describeInstances(request *ec2.DescribeInstancesInput) ([]*ec2.Instance, error) {
 	// Instances are paged
 	results := []*ec2.Instance{}
@@ -1,22 +1,17 @@
 package azure
 
 import (
+	"net"
 	"net/http"
-	"sync"
-	"time"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/arm/compute"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
-	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider/internal"
 	"github.com/golang/glog"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-// cache used by getVirtualMachine
-// 15s for expiration duration
-var vmCache = internal.NewTimedCache(15 * time.Second)
-
 type availabilitySet struct {
 	*Cloud
 }
@@ -28,87 +23,101 @@ func newAvailabilitySet(az *Cloud) *availabilitySet {
 }
 
 func (as *availabilitySet) GetInstanceIDByNodeName(name string) (string, error) {
-	var machine compute.VirtualMachine
-	var err error
-
-	machine, err = as.getVirtualMachine(name)
+	entry, err := as.vmCache.get(name, func() (*vmCacheEntry, error) {
+		return as.populateVMInfo(name)
+	})
 	if err != nil {
-		if as.CloudProviderBackoff {
-			glog.V(4).Infof("InstanceID(%s) backing off", name)
-			machine, err = as.getVirtualMachineWithRetry(name)
-			if err != nil {
-				glog.V(4).Infof("InstanceID(%s) abort backoff", name)
-				return "", err
-			}
-		} else {
-			return "", err
-		}
+		return "", err
 	}
-	return *machine.ID, nil
+	return entry.InstanceID, nil
 }
 
-func (as *availabilitySet) GetInstanceGroupByNodeName(name string) (string, error) {
-	var machine compute.VirtualMachine
-	var err error
+// GetInstanceAddressesByNodeName fetches name's VM (not cached, since
+// addresses can change independently of instanceID/instanceGroup) and
+// resolves its primary NIC's IP configurations.
+func (as *availabilitySet) GetInstanceAddressesByNodeName(name string) ([]net.IP, []string, error) {
+	machine, err := as.getVirtualMachineWithRetry(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if machine.VirtualMachineProperties == nil || machine.VirtualMachineProperties.NetworkProfile == nil ||
+		machine.VirtualMachineProperties.NetworkProfile.NetworkInterfaces == nil ||
+		len(*machine.VirtualMachineProperties.NetworkProfile.NetworkInterfaces) == 0 {
+		return nil, nil, cloudprovider.ErrInstanceNotFound
+	}
 
-	machine, err = as.getVirtualMachine(name)
+	nicName, err := nicNameFromID(*(*machine.VirtualMachineProperties.NetworkProfile.NetworkInterfaces)[0].ID)
 	if err != nil {
-		if as.CloudProviderBackoff {
-			glog.V(4).Infof("InstanceID(%s) backing off", name)
-			machine, err = as.getVirtualMachineWithRetry(name)
-			if err != nil {
-				glog.V(4).Infof("InstanceID(%s) abort backoff", name)
-				return "", err
-			}
-		} else {
-			return "", err
-		}
+		return nil, nil, err
+	}
+	nic, err := as.NetworkInterfacesClient.Get(as.ResourceGroup, nicName)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if machine.VirtualMachineProperties == nil && machine.VirtualMachineProperties.AvailabilitySet == nil {
-		return "", cloudprovider.ErrInstanceGroupNotFound
+	computerName := ""
+	if machine.VirtualMachineProperties.OsProfile != nil && machine.VirtualMachineProperties.OsProfile.ComputerName != nil {
+		computerName = *machine.VirtualMachineProperties.OsProfile.ComputerName
 	}
 
-	return *machine.VirtualMachineProperties.AvailabilitySet.ID, nil
+	ips, names := addressesFromNIC(nic, computerName)
+	return ips, names, nil
 }
 
-type vmRequest struct {
-	lock *sync.Mutex
-	vm   *compute.VirtualMachine
+// nicNameFromID extracts the trailing resource name from a NIC's ARM
+// resource ID, e.g.
+// ".../providers/Microsoft.Network/networkInterfaces/my-nic" -> "my-nic".
+func nicNameFromID(id string) (string, error) {
+	segments := strings.Split(strings.Trim(id, "/"), "/")
+	if len(segments) == 0 || segments[len(segments)-1] == "" {
+		return "", cloudprovider.ErrInstanceNotFound
+	}
+	return segments[len(segments)-1], nil
 }
 
-func (az *Cloud) getVirtualMachine(nodeName string) (vm compute.VirtualMachine, err error) {
-	vmName := nodeName
-	cachedRequest, err := vmCache.GetOrCreate(vmName, func() interface{} {
-		return &vmRequest{
-			lock: &sync.Mutex{},
-			vm:   nil,
-		}
+func (as *availabilitySet) GetInstanceGroupByNodeName(name string) (string, error) {
+	entry, err := as.vmCache.get(name, func() (*vmCacheEntry, error) {
+		return as.populateVMInfo(name)
 	})
 	if err != nil {
-		return compute.VirtualMachine{}, err
+		return "", err
 	}
-	request := cachedRequest.(*vmRequest)
+	return entry.InstanceGroup, nil
+}
 
-	if request.vm == nil {
-		request.lock.Lock()
-		defer request.lock.Unlock()
-		vm, err = az.VirtualMachinesClient.Get(az.ResourceGroup, vmName, compute.InstanceView)
-		exists, realErr := checkResourceExistsFromError(err)
-		if realErr != nil {
-			return vm, realErr
-		}
+// populateVMInfo fetches name's VM from ARM (retrying with backoff if
+// configured) and extracts the (instanceID, instanceGroup, providerID)
+// tuple the shared vmCache stores, so a later lookup of either value for
+// the same node is a cache hit.
+func (as *availabilitySet) populateVMInfo(name string) (*vmCacheEntry, error) {
+	machine, err := as.getVirtualMachineWithRetry(name)
+	if err != nil {
+		return nil, err
+	}
 
-		if !exists {
-			return vm, cloudprovider.ErrInstanceNotFound
-		}
+	if machine.VirtualMachineProperties == nil || machine.VirtualMachineProperties.AvailabilitySet == nil {
+		return nil, cloudprovider.ErrInstanceGroupNotFound
+	}
+
+	return &vmCacheEntry{
+		InstanceID:    *machine.ID,
+		InstanceGroup: *machine.VirtualMachineProperties.AvailabilitySet.ID,
+		ProviderID:    vmProviderID(as.Cloud, name),
+	}, nil
+}
+
+func (az *Cloud) getVirtualMachine(nodeName string) (vm compute.VirtualMachine, err error) {
+	vm, err = az.VirtualMachinesClient.Get(az.ResourceGroup, nodeName, compute.InstanceView)
+	exists, realErr := checkResourceExistsFromError(err)
+	if realErr != nil {
+		return vm, realErr
+	}
 
-		request.vm = &vm
-		return *request.vm, nil
+	if !exists {
+		return vm, cloudprovider.ErrInstanceNotFound
 	}
 
-	glog.V(4).Infof("getVirtualMachine hits cache for(%s)", vmName)
-	return *request.vm, nil
+	return vm, nil
 }
 
 func (az *Cloud) getVirtualMachineWithRetry(name string) (compute.VirtualMachine, error) {
@@ -1,11 +1,14 @@
 package azure
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/arm/network"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
 	"github.com/golang/glog"
@@ -46,6 +49,33 @@ type Config struct {
 	CloudProviderRateLimit       bool    `json:"cloudProviderRateLimit" yaml:"cloudProviderRateLimit"`
 	CloudProviderRateLimitQPS    float32 `json:"cloudProviderRateLimitQPS" yaml:"cloudProviderRateLimitQPS"`
 	CloudProviderRateLimitBucket int     `json:"cloudProviderRateLimitBucket" yaml:"cloudProviderRateLimitBucket"`
+
+	// CacheTTLSeconds controls how long the per-node VM cache (see
+	// cache.go) keeps a node's (instanceID, instanceGroup, providerID)
+	// tuple before refreshing it from ARM, and how often the scale set
+	// instance list is re-listed (see azure_vmss.go). If unset, the VM
+	// cache uses defaultCacheTTL and the scale set refresh uses
+	// defaultScaleSetCacheTTL.
+	CacheTTLSeconds int `json:"cacheTTLSeconds" yaml:"cacheTTLSeconds"` // in seconds
+
+	// ResourceGroups additionally scans these (subscriptionID,
+	// resourceGroup) pairs for VMSS instances, beyond SubscriptionID/
+	// ResourceGroup above. OpenShift/AKS-style clusters whose node scale
+	// sets live in a different resource group -- or, in a hub/spoke
+	// layout, a different subscription entirely -- than the one the
+	// approver authenticates against would otherwise have every one of
+	// those nodes silently misclassified as availability-set-managed,
+	// since scaleSet only ever looked in its own SubscriptionID/
+	// ResourceGroup.
+	ResourceGroups []ResourceGroupConfig `json:"resourceGroups" yaml:"resourceGroups"`
+}
+
+// ResourceGroupConfig names one (subscriptionID, resourceGroup) pair to
+// search for VMSS instances in, in addition to Config.SubscriptionID/
+// Config.ResourceGroup.
+type ResourceGroupConfig struct {
+	SubscriptionID string `json:"subscriptionID" yaml:"subscriptionID"`
+	ResourceGroup  string `json:"resourceGroup" yaml:"resourceGroup"`
 }
 
 type Cloud struct {
@@ -53,6 +83,7 @@ type Cloud struct {
 	Environment            azure.Environment
 	resourceRequestBackoff wait.Backoff
 	vmSet                  vmSet
+	vmCache                *vmInfoCache
 
 	// Client for standard.
 	VirtualMachinesClient *azVirtualMachinesClient
@@ -60,6 +91,17 @@ type Cloud struct {
 	// Clients for vmss.
 	VirtualMachineScaleSetsClient   *azVirtualMachineScaleSetsClient
 	VirtualMachineScaleSetVMsClient *azVirtualMachineScaleSetVMsClient
+
+	// NetworkInterfacesClient resolves a VM or VMSS VM's NIC reference
+	// into its actual IP configurations, for GetInstanceAddressesByNodeName.
+	NetworkInterfacesClient *azNetworkInterfacesClient
+
+	// clientConfig is the template (everything but subscriptionID) scaleSet
+	// clones to lazily build a VirtualMachineScaleSetsClient/
+	// VirtualMachineScaleSetVMsClient for each subscription named in
+	// ResourceGroups, since every ARM client above is bound to a single
+	// subscription at construction time.
+	clientConfig *azClientConfig
 }
 
 func NewCloud(configReader io.Reader) (cloudprovider.Interface, error) {
@@ -94,44 +136,54 @@ func NewCloud(configReader io.Reader) (cloudprovider.Interface, error) {
 		glog.V(2).Infof("Azure cloudprovider using rate limit config: QPS=%g, bucket=%d", config.CloudProviderRateLimitQPS, config.CloudProviderRateLimitBucket)
 	}
 
+	// Conditionally configure resource request backoff. Defaults to a
+	// single attempt (no retries) so the ARM client wrappers in
+	// azure_client.go always have a well-formed wait.Backoff to retry
+	// transient ARM errors with, even when CloudProviderBackoff is off.
+	resourceRequestBackoff := wait.Backoff{Steps: 1}
+	if config.CloudProviderBackoff {
+		// Assign backoff defaults if no configuration was passed in
+		if config.CloudProviderBackoffRetries == 0 {
+			config.CloudProviderBackoffRetries = backoffRetriesDefault
+		}
+		if config.CloudProviderBackoffExponent == 0 {
+			config.CloudProviderBackoffExponent = backoffExponentDefault
+		}
+		if config.CloudProviderBackoffDuration == 0 {
+			config.CloudProviderBackoffDuration = backoffDurationDefault
+		}
+		if config.CloudProviderBackoffJitter == 0 {
+			config.CloudProviderBackoffJitter = backoffJitterDefault
+		}
+		resourceRequestBackoff = wait.Backoff{
+			Steps:    config.CloudProviderBackoffRetries,
+			Factor:   config.CloudProviderBackoffExponent,
+			Duration: time.Duration(config.CloudProviderBackoffDuration) * time.Second,
+			Jitter:   config.CloudProviderBackoffJitter,
+		}
+		glog.V(2).Infof("Azure cloudprovider using retry backoff: retries=%d, exponent=%f, duration=%d, jitter=%f", config.CloudProviderBackoffRetries, config.CloudProviderBackoffExponent, config.CloudProviderBackoffDuration, config.CloudProviderBackoffJitter)
+	}
+
 	azClientConfig := &azClientConfig{
 		subscriptionID:          config.SubscriptionID,
 		resourceManagerEndpoint: env.ResourceManagerEndpoint,
 		servicePrincipalToken:   servicePrincipalToken,
 		rateLimiter:             operationPollRateLimiter,
+		resourceRequestBackoff:  resourceRequestBackoff,
 	}
 	az := Cloud{
-		Config:      *config,
-		Environment: *env,
+		Config:                 *config,
+		Environment:            *env,
+		resourceRequestBackoff: resourceRequestBackoff,
 
 		VirtualMachinesClient:           newAzVirtualMachinesClient(azClientConfig),
 		VirtualMachineScaleSetsClient:   newAzVirtualMachineScaleSetsClient(azClientConfig),
 		VirtualMachineScaleSetVMsClient: newAzVirtualMachineScaleSetVMsClient(azClientConfig),
+		NetworkInterfacesClient:         newAzNetworkInterfacesClient(azClientConfig),
+		clientConfig:                   azClientConfig,
 	}
 
-	// Conditionally configure resource request backoff
-	if az.CloudProviderBackoff {
-		// Assign backoff defaults if no configuration was passed in
-		if az.CloudProviderBackoffRetries == 0 {
-			az.CloudProviderBackoffRetries = backoffRetriesDefault
-		}
-		if az.CloudProviderBackoffExponent == 0 {
-			az.CloudProviderBackoffExponent = backoffExponentDefault
-		}
-		if az.CloudProviderBackoffDuration == 0 {
-			az.CloudProviderBackoffDuration = backoffDurationDefault
-		}
-		if az.CloudProviderBackoffJitter == 0 {
-			az.CloudProviderBackoffJitter = backoffJitterDefault
-		}
-		az.resourceRequestBackoff = wait.Backoff{
-			Steps:    az.CloudProviderBackoffRetries,
-			Factor:   az.CloudProviderBackoffExponent,
-			Duration: time.Duration(az.CloudProviderBackoffDuration) * time.Second,
-			Jitter:   az.CloudProviderBackoffJitter,
-		}
-		glog.V(2).Infof("Azure cloudprovider using retry backoff: retries=%d, exponent=%f, duration=%d, jitter=%f", az.CloudProviderBackoffRetries, az.CloudProviderBackoffExponent, az.CloudProviderBackoffDuration, az.CloudProviderBackoffJitter)
-	}
+	az.vmCache = newVMInfoCache(time.Duration(az.CacheTTLSeconds) * time.Second)
 
 	if strings.EqualFold(vmTypeVMSS, az.Config.VMType) {
 		az.vmSet = newScaleSet(&az)
@@ -150,9 +202,59 @@ func (c *Cloud) GetInstanceGroupByNodeName(nodeName string) (string, error) {
 	return c.vmSet.GetInstanceGroupByNodeName(nodeName)
 }
 
+func (c *Cloud) GetInstanceGroupByProviderID(providerID string) (string, error) {
+	cloudName, vmName, err := cloudprovider.ParseProviderID(providerID)
+	if err != nil {
+		return "", err
+	}
+	if cloudName != CloudProviderName {
+		return "", fmt.Errorf("providerID %q is not an %s instance", providerID, CloudProviderName)
+	}
+	return c.vmSet.GetInstanceGroupByNodeName(vmName)
+}
+
+func (c *Cloud) GetInstanceAddressesByNodeName(nodeName string) ([]net.IP, []string, error) {
+	return c.vmSet.GetInstanceAddressesByNodeName(nodeName)
+}
+
 type vmSet interface {
 	GetInstanceIDByNodeName(string) (string, error)
 	GetInstanceGroupByNodeName(string) (string, error)
+	GetInstanceAddressesByNodeName(string) ([]net.IP, []string, error)
+}
+
+// addressesFromNIC extracts the private/public IPs and hostname-derived
+// DNS name node-csr-approver needs out of nic, for
+// GetInstanceAddressesByNodeName. computerName is the VM's OsProfile
+// ComputerName, used as the internal DNS label since ARM doesn't expose
+// a VM's private DNS record directly.
+func addressesFromNIC(nic network.Interface, computerName string) ([]net.IP, []string) {
+	var ips []net.IP
+	if nic.InterfacePropertiesFormat != nil && nic.InterfacePropertiesFormat.IPConfigurations != nil {
+		for _, ipConfig := range *nic.InterfacePropertiesFormat.IPConfigurations {
+			if ipConfig.InterfaceIPConfigurationPropertiesFormat == nil {
+				continue
+			}
+			if addr := ipConfig.InterfaceIPConfigurationPropertiesFormat.PrivateIPAddress; addr != nil {
+				if ip := net.ParseIP(*addr); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+			if pip := ipConfig.InterfaceIPConfigurationPropertiesFormat.PublicIPAddress; pip != nil && pip.PublicIPAddressPropertiesFormat != nil {
+				if addr := pip.PublicIPAddressPropertiesFormat.IPAddress; addr != nil {
+					if ip := net.ParseIP(*addr); ip != nil {
+						ips = append(ips, ip)
+					}
+				}
+			}
+		}
+	}
+
+	var names []string
+	if computerName != "" {
+		names = append(names, strings.ToLower(computerName))
+	}
+	return ips, names
 }
 
 // parseConfig returns a parsed configuration for an Azure cloudprovider config file
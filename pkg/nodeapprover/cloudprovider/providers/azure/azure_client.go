@@ -1,11 +1,15 @@
 package azure
 
 import (
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/azure-sdk-for-go/arm/network"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/adal"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/flowcontrol"
 )
 
@@ -14,11 +18,18 @@ type azClientConfig struct {
 	resourceManagerEndpoint string
 	servicePrincipalToken   *adal.ServicePrincipalToken
 	rateLimiter             flowcontrol.RateLimiter
+
+	// resourceRequestBackoff is consulted by every wrapper method below to
+	// retry a retriable ARM error (429, or a transient 5xx) rather than
+	// failing the CSR outright. It defaults to a single attempt (no
+	// retries) when Config.CloudProviderBackoff is off.
+	resourceRequestBackoff wait.Backoff
 }
 
 type azVirtualMachinesClient struct {
-	client      compute.VirtualMachinesClient
-	rateLimiter flowcontrol.RateLimiter
+	client                 compute.VirtualMachinesClient
+	rateLimiter            flowcontrol.RateLimiter
+	resourceRequestBackoff wait.Backoff
 }
 
 func newAzVirtualMachinesClient(config *azClientConfig) *azVirtualMachinesClient {
@@ -28,20 +39,26 @@ func newAzVirtualMachinesClient(config *azClientConfig) *azVirtualMachinesClient
 	virtualMachinesClient.PollingDelay = 5 * time.Second
 
 	return &azVirtualMachinesClient{
-		rateLimiter: config.rateLimiter,
-		client:      virtualMachinesClient,
+		rateLimiter:            config.rateLimiter,
+		resourceRequestBackoff: config.resourceRequestBackoff,
+		client:                 virtualMachinesClient,
 	}
 }
 
 func (az *azVirtualMachinesClient) Get(resourceGroupName string, VMName string, expand compute.InstanceViewTypes) (result compute.VirtualMachine, err error) {
-	az.rateLimiter.Accept()
-	result, err = az.client.Get(resourceGroupName, VMName, expand)
+	err = withRetry(az.resourceRequestBackoff, func() error {
+		az.rateLimiter.Accept()
+		var innerErr error
+		result, innerErr = az.client.Get(resourceGroupName, VMName, expand)
+		return innerErr
+	})
 	return
 }
 
 type azVirtualMachineScaleSetsClient struct {
-	client      compute.VirtualMachineScaleSetsClient
-	rateLimiter flowcontrol.RateLimiter
+	client                 compute.VirtualMachineScaleSetsClient
+	rateLimiter            flowcontrol.RateLimiter
+	resourceRequestBackoff wait.Backoff
 }
 
 func newAzVirtualMachineScaleSetsClient(config *azClientConfig) *azVirtualMachineScaleSetsClient {
@@ -51,26 +68,36 @@ func newAzVirtualMachineScaleSetsClient(config *azClientConfig) *azVirtualMachin
 	virtualMachineScaleSetsClient.PollingDelay = 5 * time.Second
 
 	return &azVirtualMachineScaleSetsClient{
-		client:      virtualMachineScaleSetsClient,
-		rateLimiter: config.rateLimiter,
+		client:                 virtualMachineScaleSetsClient,
+		rateLimiter:            config.rateLimiter,
+		resourceRequestBackoff: config.resourceRequestBackoff,
 	}
 }
 
 func (az *azVirtualMachineScaleSetsClient) List(resourceGroupName string) (result compute.VirtualMachineScaleSetListResult, err error) {
-	az.rateLimiter.Accept()
-	result, err = az.client.List(resourceGroupName)
+	err = withRetry(az.resourceRequestBackoff, func() error {
+		az.rateLimiter.Accept()
+		var innerErr error
+		result, innerErr = az.client.List(resourceGroupName)
+		return innerErr
+	})
 	return
 }
 
 func (az *azVirtualMachineScaleSetsClient) ListNextResults(lastResults compute.VirtualMachineScaleSetListResult) (result compute.VirtualMachineScaleSetListResult, err error) {
-	az.rateLimiter.Accept()
-	result, err = az.client.ListNextResults(lastResults)
+	err = withRetry(az.resourceRequestBackoff, func() error {
+		az.rateLimiter.Accept()
+		var innerErr error
+		result, innerErr = az.client.ListNextResults(lastResults)
+		return innerErr
+	})
 	return
 }
 
 type azVirtualMachineScaleSetVMsClient struct {
-	client      compute.VirtualMachineScaleSetVMsClient
-	rateLimiter flowcontrol.RateLimiter
+	client                 compute.VirtualMachineScaleSetVMsClient
+	rateLimiter            flowcontrol.RateLimiter
+	resourceRequestBackoff wait.Backoff
 }
 
 func newAzVirtualMachineScaleSetVMsClient(config *azClientConfig) *azVirtualMachineScaleSetVMsClient {
@@ -80,19 +107,146 @@ func newAzVirtualMachineScaleSetVMsClient(config *azClientConfig) *azVirtualMach
 	virtualMachineScaleSetVMsClient.PollingDelay = 5 * time.Second
 
 	return &azVirtualMachineScaleSetVMsClient{
-		client:      virtualMachineScaleSetVMsClient,
-		rateLimiter: config.rateLimiter,
+		client:                 virtualMachineScaleSetVMsClient,
+		rateLimiter:            config.rateLimiter,
+		resourceRequestBackoff: config.resourceRequestBackoff,
 	}
 }
 
 func (az *azVirtualMachineScaleSetVMsClient) List(resourceGroupName string, virtualMachineScaleSetName string, filter string, selectParameter string, expand string) (result compute.VirtualMachineScaleSetVMListResult, err error) {
-	az.rateLimiter.Accept()
-	result, err = az.client.List(resourceGroupName, virtualMachineScaleSetName, filter, selectParameter, expand)
+	err = withRetry(az.resourceRequestBackoff, func() error {
+		az.rateLimiter.Accept()
+		var innerErr error
+		result, innerErr = az.client.List(resourceGroupName, virtualMachineScaleSetName, filter, selectParameter, expand)
+		return innerErr
+	})
 	return
 }
 
 func (az *azVirtualMachineScaleSetVMsClient) ListNextResults(lastResults compute.VirtualMachineScaleSetVMListResult) (result compute.VirtualMachineScaleSetVMListResult, err error) {
-	az.rateLimiter.Accept()
-	result, err = az.client.ListNextResults(lastResults)
+	err = withRetry(az.resourceRequestBackoff, func() error {
+		az.rateLimiter.Accept()
+		var innerErr error
+		result, innerErr = az.client.ListNextResults(lastResults)
+		return innerErr
+	})
+	return
+}
+
+// azNetworkInterfacesClient wraps network.InterfacesClient, used to
+// resolve a VM or VMSS VM's NIC (found by name in the VM's
+// NetworkProfile) into its actual IP configurations -- the VM/VMSS-VM
+// objects themselves only carry a NIC reference, not its addresses.
+type azNetworkInterfacesClient struct {
+	client                 network.InterfacesClient
+	rateLimiter            flowcontrol.RateLimiter
+	resourceRequestBackoff wait.Backoff
+}
+
+func newAzNetworkInterfacesClient(config *azClientConfig) *azNetworkInterfacesClient {
+	interfacesClient := network.NewInterfacesClient(config.subscriptionID)
+	interfacesClient.BaseURI = config.resourceManagerEndpoint
+	interfacesClient.Authorizer = autorest.NewBearerAuthorizer(config.servicePrincipalToken)
+	interfacesClient.PollingDelay = 5 * time.Second
+
+	return &azNetworkInterfacesClient{
+		client:                 interfacesClient,
+		rateLimiter:            config.rateLimiter,
+		resourceRequestBackoff: config.resourceRequestBackoff,
+	}
+}
+
+// Get returns the NIC named nicName in resourceGroupName, belonging to a
+// standalone (non-scale-set) VM.
+func (az *azNetworkInterfacesClient) Get(resourceGroupName, nicName string) (result network.Interface, err error) {
+	err = withRetry(az.resourceRequestBackoff, func() error {
+		az.rateLimiter.Accept()
+		var innerErr error
+		result, innerErr = az.client.Get(resourceGroupName, nicName, "")
+		return innerErr
+	})
 	return
 }
+
+// GetVirtualMachineScaleSetNetworkInterface returns the NIC named
+// nicName belonging to instanceID within scaleSetName.
+func (az *azNetworkInterfacesClient) GetVirtualMachineScaleSetNetworkInterface(resourceGroupName, scaleSetName, instanceID, nicName string) (result network.Interface, err error) {
+	err = withRetry(az.resourceRequestBackoff, func() error {
+		az.rateLimiter.Accept()
+		var innerErr error
+		result, innerErr = az.client.GetVirtualMachineScaleSetNetworkInterface(resourceGroupName, scaleSetName, instanceID, nicName, "")
+		return innerErr
+	})
+	return
+}
+
+// withRetry runs fn, retrying through backoff when fn returns a retriable
+// ARM error (see isRetriableError): a 429, a transient 5xx, or an error
+// autorest didn't shape into a DetailedError at all (e.g. a network
+// timeout). Terminal errors (401, 403, 404, ...) are returned immediately
+// without consuming a retry. When ARM attaches a Retry-After header to
+// the error, the wait before the next attempt is at least that long,
+// overriding backoff's own computed duration for that step.
+func withRetry(backoff wait.Backoff, fn func() error) error {
+	var lastErr error
+	for backoff.Steps > 0 {
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+		if !isRetriableError(lastErr) {
+			return lastErr
+		}
+		if backoff.Steps == 1 {
+			break
+		}
+
+		sleep := backoff.Step()
+		if retryAfter, ok := retryAfterFromError(lastErr); ok && retryAfter > sleep {
+			sleep = retryAfter
+		}
+		time.Sleep(sleep)
+	}
+	return lastErr
+}
+
+// isRetriableError reports whether err, as returned by an ARM SDK call,
+// should be retried rather than returned to the caller immediately. An
+// error autorest didn't shape into a DetailedError (e.g. a network
+// timeout dialing ARM) is treated as retriable too, since it carries no
+// status code to prove it's terminal.
+func isRetriableError(err error) bool {
+	detailed, ok := err.(autorest.DetailedError)
+	if !ok {
+		return true
+	}
+	switch detailed.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfterFromError extracts the Retry-After duration ARM attaches to a
+// 429/503 response, if any, from a DetailedError's Response.
+func retryAfterFromError(err error) (time.Duration, bool) {
+	detailed, ok := err.(autorest.DetailedError)
+	if !ok || detailed.Response == nil {
+		return 0, false
+	}
+
+	retryAfter := detailed.Response.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(retryAfter); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,103 @@
+package azure
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// countingRoundTripper returns a 429 for the first failCount requests it
+// sees, then a 200 for every request after that.
+type countingRoundTripper struct {
+	failCount int
+	calls     int
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	if rt.calls <= rt.failCount {
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"0"}},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func TestWithRetryRetriesOn429ThenSucceeds(t *testing.T) {
+	rt := &countingRoundTripper{failCount: 2}
+	client := &http.Client{Transport: rt}
+
+	calls := 0
+	err := withRetry(wait.Backoff{Steps: 5, Duration: time.Millisecond}, func() error {
+		calls++
+		resp, err := client.Get("http://arm.invalid/")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return autorest.DetailedError{StatusCode: resp.StatusCode, Response: resp}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected eventual success, got: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls (2 failures + 1 success), got %d", calls)
+	}
+}
+
+func TestWithRetryReturnsTerminalErrorImmediately(t *testing.T) {
+	calls := 0
+	terminal := autorest.DetailedError{StatusCode: http.StatusNotFound}
+	err := withRetry(wait.Backoff{Steps: 5, Duration: time.Millisecond}, func() error {
+		calls++
+		return terminal
+	})
+	if err != terminal {
+		t.Fatalf("expected terminal error returned unchanged, got: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected exactly 1 call for a terminal error, got %d", calls)
+	}
+}
+
+func TestIsRetriableError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"429", autorest.DetailedError{StatusCode: http.StatusTooManyRequests}, true},
+		{"500", autorest.DetailedError{StatusCode: http.StatusInternalServerError}, true},
+		{"502", autorest.DetailedError{StatusCode: http.StatusBadGateway}, true},
+		{"503", autorest.DetailedError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"504", autorest.DetailedError{StatusCode: http.StatusGatewayTimeout}, true},
+		{"401", autorest.DetailedError{StatusCode: http.StatusUnauthorized}, false},
+		{"403", autorest.DetailedError{StatusCode: http.StatusForbidden}, false},
+		{"404", autorest.DetailedError{StatusCode: http.StatusNotFound}, false},
+		{"unstructured error", errUnstructured{}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRetriableError(c.err); got != c.want {
+				t.Errorf("isRetriableError(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
+
+type errUnstructured struct{}
+
+func (errUnstructured) Error() string { return "network timeout" }
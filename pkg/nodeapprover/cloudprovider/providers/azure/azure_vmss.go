@@ -1,6 +1,8 @@
 package azure
 
 import (
+	"fmt"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -8,7 +10,6 @@ import (
 	"github.com/Azure/azure-sdk-for-go/arm/compute"
 	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
 	"github.com/golang/glog"
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -18,11 +19,24 @@ type scaleSetInfo struct {
 }
 
 type scaleSetVMInfo struct {
-	ID       string
-	NodeName string
+	ID         string
+	InstanceID string
+	NodeName   string
+	NICName    string
+	// SubscriptionID and ResourceGroup record which of ss.resourceGroups
+	// this VM was discovered in, so GetInstanceAddressesByNodeName can
+	// call back into the right subscription's clients for it.
+	SubscriptionID string
+	ResourceGroup  string
 	scaleSetInfo
 }
 
+// defaultScaleSetCacheTTL is used for the background bulk-list refresh
+// below when Config.CacheTTLSeconds is unset; scale sets see less benefit
+// from a short TTL than the per-node availabilitySet cache does, since
+// every refresh already lists every instance in every scale set.
+const defaultScaleSetCacheTTL = 5 * time.Minute
+
 type scaleSet struct {
 	*Cloud
 
@@ -30,19 +44,48 @@ type scaleSet struct {
 	// (e.g. master nodes) may not belong to any scale sets.
 	availabilitySet vmSet
 
+	// resourceGroups is every (subscriptionID, resourceGroup) pair
+	// updateCache scans for scale sets: the primary Cloud.SubscriptionID/
+	// Cloud.ResourceGroup, followed by Config.ResourceGroups.
+	resourceGroups []ResourceGroupConfig
+
+	// clientsMutex guards scaleSetsClients/scaleSetVMsClients/
+	// networkInterfacesClients, the lazily-built per-subscription ARM
+	// clients used for every resourceGroups entry beyond the primary
+	// subscription.
+	clientsMutex             sync.Mutex
+	scaleSetsClients         map[string]*azVirtualMachineScaleSetsClient
+	scaleSetVMsClients       map[string]*azVirtualMachineScaleSetVMsClient
+	networkInterfacesClients map[string]*azNetworkInterfacesClient
+
 	cacheMutex sync.Mutex
-	// A local cache of scale sets. The key is scale set name and the value is a
-	// list of virtual machines belonging to the scale set.
+	// A local cache of scale sets. The key is "<subscriptionID>/
+	// <resourceGroup>/<scaleSetName>" and the value is a list of virtual
+	// machines belonging to the scale set.
 	cache                     map[string][]scaleSetVMInfo
-	availabilitySetNodesCache sets.String
+	availabilitySetNodesCache *negativeCache
 }
 
 func newScaleSet(az *Cloud) *scaleSet {
+	resourceGroups := append([]ResourceGroupConfig{{
+		SubscriptionID: az.SubscriptionID,
+		ResourceGroup:  az.ResourceGroup,
+	}}, az.Config.ResourceGroups...)
+
+	refreshInterval := defaultScaleSetCacheTTL
+	if az.CacheTTLSeconds > 0 {
+		refreshInterval = time.Duration(az.CacheTTLSeconds) * time.Second
+	}
+
 	ss := &scaleSet{
 		Cloud:                     az,
 		availabilitySet:           newAvailabilitySet(az),
-		availabilitySetNodesCache: sets.NewString(),
-		cache: make(map[string][]scaleSetVMInfo),
+		resourceGroups:            resourceGroups,
+		scaleSetsClients:          make(map[string]*azVirtualMachineScaleSetsClient),
+		scaleSetVMsClients:        make(map[string]*azVirtualMachineScaleSetVMsClient),
+		networkInterfacesClients: make(map[string]*azNetworkInterfacesClient),
+		availabilitySetNodesCache: newNegativeCache(refreshInterval),
+		cache:                     make(map[string][]scaleSetVMInfo),
 	}
 
 	go wait.Until(func() {
@@ -52,11 +95,70 @@ func newScaleSet(az *Cloud) *scaleSet {
 		if err := ss.updateCache(); err != nil {
 			glog.Errorf("updateCache failed: %v", err)
 		}
-	}, 5*time.Minute, wait.NeverStop)
+	}, refreshInterval, wait.NeverStop)
 
 	return ss
 }
 
+// scaleSetsClientFor returns the azVirtualMachineScaleSetsClient bound to
+// subscriptionID, building and caching one from ss.clientConfig the first
+// time a subscription other than the primary one is requested.
+func (ss *scaleSet) scaleSetsClientFor(subscriptionID string) *azVirtualMachineScaleSetsClient {
+	if subscriptionID == "" || subscriptionID == ss.SubscriptionID {
+		return ss.VirtualMachineScaleSetsClient
+	}
+
+	ss.clientsMutex.Lock()
+	defer ss.clientsMutex.Unlock()
+	if client, ok := ss.scaleSetsClients[subscriptionID]; ok {
+		return client
+	}
+	config := *ss.clientConfig
+	config.subscriptionID = subscriptionID
+	client := newAzVirtualMachineScaleSetsClient(&config)
+	ss.scaleSetsClients[subscriptionID] = client
+	return client
+}
+
+// scaleSetVMsClientFor is scaleSetsClientFor's counterpart for
+// azVirtualMachineScaleSetVMsClient.
+func (ss *scaleSet) scaleSetVMsClientFor(subscriptionID string) *azVirtualMachineScaleSetVMsClient {
+	if subscriptionID == "" || subscriptionID == ss.SubscriptionID {
+		return ss.VirtualMachineScaleSetVMsClient
+	}
+
+	ss.clientsMutex.Lock()
+	defer ss.clientsMutex.Unlock()
+	if client, ok := ss.scaleSetVMsClients[subscriptionID]; ok {
+		return client
+	}
+	config := *ss.clientConfig
+	config.subscriptionID = subscriptionID
+	client := newAzVirtualMachineScaleSetVMsClient(&config)
+	ss.scaleSetVMsClients[subscriptionID] = client
+	return client
+}
+
+// networkInterfacesClientFor is scaleSetsClientFor's counterpart for
+// azNetworkInterfacesClient, used by GetInstanceAddressesByNodeName to
+// resolve a NIC discovered in a non-primary subscription.
+func (ss *scaleSet) networkInterfacesClientFor(subscriptionID string) *azNetworkInterfacesClient {
+	if subscriptionID == "" || subscriptionID == ss.SubscriptionID {
+		return ss.NetworkInterfacesClient
+	}
+
+	ss.clientsMutex.Lock()
+	defer ss.clientsMutex.Unlock()
+	if client, ok := ss.networkInterfacesClients[subscriptionID]; ok {
+		return client
+	}
+	config := *ss.clientConfig
+	config.subscriptionID = subscriptionID
+	client := newAzNetworkInterfacesClient(&config)
+	ss.networkInterfacesClients[subscriptionID] = client
+	return client
+}
+
 func (ss *scaleSet) GetInstanceIDByNodeName(name string) (string, error) {
 	vm, err := ss.getCachedVirtualMachine(name)
 	if err != nil {
@@ -83,33 +185,89 @@ func (ss *scaleSet) GetInstanceGroupByNodeName(name string) (string, error) {
 	return vm.scaleSetInfo.ID, nil
 }
 
-func (ss *scaleSet) updateCache() error {
-	scaleSets, err := ss.listScaleSetsWithRetry()
+// GetInstanceAddressesByNodeName resolves name's VMSS VM NIC (falling
+// back to the availabilitySet the same way GetInstanceIDByNodeName does,
+// for instances scale sets don't manage) into its IP configurations.
+func (ss *scaleSet) GetInstanceAddressesByNodeName(name string) ([]net.IP, []string, error) {
+	vm, err := ss.getCachedVirtualMachine(name)
+	if err != nil {
+		if err == cloudprovider.ErrInstanceNotFound {
+			glog.V(4).Infof("GetInstanceAddressesByNodeName: node %q is not found in scale sets, assuming it is managed by availability set", name)
+			return ss.availabilitySet.GetInstanceAddressesByNodeName(name)
+		}
+		return nil, nil, err
+	}
+	if vm.NICName == "" {
+		return nil, nil, cloudprovider.ErrInstanceNotFound
+	}
+
+	nic, err := ss.networkInterfacesClientFor(vm.SubscriptionID).GetVirtualMachineScaleSetNetworkInterface(vm.ResourceGroup, vm.scaleSetInfo.Name, vm.InstanceID, vm.NICName)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
+	ips, names := addressesFromNIC(nic, vm.NodeName)
+	return ips, names, nil
+}
+
+// updateCache rebuilds the scale set cache from scratch by scanning every
+// (subscriptionID, resourceGroup) pair in ss.resourceGroups, so a node
+// belonging to a scale set outside the primary subscription/resource
+// group (an OpenShift/AKS-style cluster split across resource groups, or
+// a hub/spoke subscription layout) is still found instead of silently
+// falling through to availabilitySet.
+func (ss *scaleSet) updateCache() error {
 	localCache := make(map[string][]scaleSetVMInfo)
-	for _, ssi := range scaleSets {
-		if _, ok := localCache[ssi.Name]; !ok {
-			localCache[ssi.Name] = make([]scaleSetVMInfo, 0)
-		}
-		vms, err := ss.listScaleSetVMsWithRetry(ssi.Name)
+
+	for _, rg := range ss.resourceGroups {
+		scaleSets, err := ss.listScaleSetsWithRetry(rg.SubscriptionID, rg.ResourceGroup)
 		if err != nil {
 			return err
 		}
 
-		for _, vm := range vms {
-			nodeName := ""
-			if vm.OsProfile != nil && vm.OsProfile.ComputerName != nil {
-				nodeName = strings.ToLower(*vm.OsProfile.ComputerName)
+		for _, ssi := range scaleSets {
+			key := fmt.Sprintf("%s/%s/%s", rg.SubscriptionID, rg.ResourceGroup, ssi.Name)
+			if _, ok := localCache[key]; !ok {
+				localCache[key] = make([]scaleSetVMInfo, 0)
+			}
+			vms, err := ss.listScaleSetVMsWithRetry(rg.SubscriptionID, rg.ResourceGroup, ssi.Name)
+			if err != nil {
+				return err
 			}
 
-			localCache[ssi.Name] = append(localCache[ssi.Name], scaleSetVMInfo{
-				ID:           *vm.ID,
-				NodeName:     nodeName,
-				scaleSetInfo: ssi,
-			})
+			for _, vm := range vms {
+				nodeName := ""
+				if vm.OsProfile != nil && vm.OsProfile.ComputerName != nil {
+					nodeName = strings.ToLower(*vm.OsProfile.ComputerName)
+				}
+				if nodeName != "" {
+					// The node is scale-set-managed after all: drop any
+					// stale "not in a scale set" entry immediately rather
+					// than waiting out its TTL, so a node reprovisioned
+					// from an availability set into a VMSS is routed
+					// correctly on the very next lookup.
+					ss.availabilitySetNodesCache.Delete(nodeName)
+				}
+
+				nicName := ""
+				if vm.NetworkProfile != nil && vm.NetworkProfile.NetworkInterfaces != nil && len(*vm.NetworkProfile.NetworkInterfaces) > 0 {
+					if id := (*vm.NetworkProfile.NetworkInterfaces)[0].ID; id != nil {
+						if name, err := nicNameFromID(*id); err == nil {
+							nicName = name
+						}
+					}
+				}
+
+				localCache[key] = append(localCache[key], scaleSetVMInfo{
+					ID:             *vm.ID,
+					InstanceID:     *vm.InstanceID,
+					NodeName:       nodeName,
+					NICName:        nicName,
+					SubscriptionID: rg.SubscriptionID,
+					ResourceGroup:  rg.ResourceGroup,
+					scaleSetInfo:   ssi,
+				})
+			}
 		}
 	}
 
@@ -163,7 +321,7 @@ func (ss *scaleSet) getCachedVirtualMachine(nodeName string) (scaleSetVMInfo, er
 	return scaleSetVMInfo{}, cloudprovider.ErrInstanceNotFound
 }
 
-func (ss *scaleSet) listScaleSetsWithRetry() ([]scaleSetInfo, error) {
+func (ss *scaleSet) listScaleSetsWithRetry(subscriptionID, resourceGroup string) ([]scaleSetInfo, error) {
 	var err error
 	var result compute.VirtualMachineScaleSetListResult
 	allScaleSets := make([]scaleSetInfo, 0)
@@ -173,11 +331,12 @@ func (ss *scaleSet) listScaleSetsWithRetry() ([]scaleSetInfo, error) {
 	if ss.CloudProviderBackoff {
 		bf = ss.resourceRequestBackoff
 	}
+	client := ss.scaleSetsClientFor(subscriptionID)
 
 	backoffError := wait.ExponentialBackoff(bf, func() (bool, error) {
-		result, err = ss.VirtualMachineScaleSetsClient.List(ss.ResourceGroup)
+		result, err = client.List(resourceGroup)
 		if err != nil {
-			glog.Errorf("VirtualMachineScaleSetsClient.List for %v failed: %v", ss.ResourceGroup, err)
+			glog.Errorf("VirtualMachineScaleSetsClient.List for %v/%v failed: %v", subscriptionID, resourceGroup, err)
 			return false, err
 		}
 
@@ -196,9 +355,9 @@ func (ss *scaleSet) listScaleSetsWithRetry() ([]scaleSetInfo, error) {
 
 		if result.NextLink != nil {
 			backoffError := wait.ExponentialBackoff(bf, func() (bool, error) {
-				result, err = ss.VirtualMachineScaleSetsClient.ListNextResults(result)
+				result, err = client.ListNextResults(result)
 				if err != nil {
-					glog.Errorf("VirtualMachineScaleSetsClient.ListNextResults for %v failed: %v", ss.ResourceGroup, err)
+					glog.Errorf("VirtualMachineScaleSetsClient.ListNextResults for %v/%v failed: %v", subscriptionID, resourceGroup, err)
 					return false, err
 				}
 
@@ -216,7 +375,7 @@ func (ss *scaleSet) listScaleSetsWithRetry() ([]scaleSetInfo, error) {
 	return allScaleSets, nil
 }
 
-func (ss *scaleSet) listScaleSetVMsWithRetry(scaleSetName string) ([]compute.VirtualMachineScaleSetVM, error) {
+func (ss *scaleSet) listScaleSetVMsWithRetry(subscriptionID, resourceGroup, scaleSetName string) ([]compute.VirtualMachineScaleSetVM, error) {
 	var err error
 	var result compute.VirtualMachineScaleSetVMListResult
 	allVMs := make([]compute.VirtualMachineScaleSetVM, 0)
@@ -226,9 +385,10 @@ func (ss *scaleSet) listScaleSetVMsWithRetry(scaleSetName string) ([]compute.Vir
 	if ss.CloudProviderBackoff {
 		bf = ss.resourceRequestBackoff
 	}
+	client := ss.scaleSetVMsClientFor(subscriptionID)
 
 	backoffError := wait.ExponentialBackoff(bf, func() (bool, error) {
-		result, err = ss.VirtualMachineScaleSetVMsClient.List(ss.ResourceGroup, scaleSetName, "", "", string(compute.InstanceView))
+		result, err = client.List(resourceGroup, scaleSetName, "", "", string(compute.InstanceView))
 		if err != nil {
 			glog.Errorf("VirtualMachineScaleSetVMsClient.List for %v failed: %v", scaleSetName, err)
 			return false, err
@@ -247,7 +407,7 @@ func (ss *scaleSet) listScaleSetVMsWithRetry(scaleSetName string) ([]compute.Vir
 
 		if result.NextLink != nil {
 			backoffError := wait.ExponentialBackoff(bf, func() (bool, error) {
-				result, err = ss.VirtualMachineScaleSetVMsClient.ListNextResults(result)
+				result, err = client.ListNextResults(result)
 				if err != nil {
 					glog.Errorf("VirtualMachineScaleSetVMsClient.ListNextResults for %v failed: %v", scaleSetName, err)
 					return false, err
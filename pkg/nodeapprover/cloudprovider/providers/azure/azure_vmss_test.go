@@ -0,0 +1,66 @@
+package azure
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNegativeCacheExpires(t *testing.T) {
+	c := newNegativeCache(20 * time.Millisecond)
+	c.Insert("worker-1")
+
+	if !c.Has("worker-1") {
+		t.Fatalf("expected worker-1 to be present immediately after Insert")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if c.Has("worker-1") {
+		t.Fatalf("expected worker-1's entry to have expired after its TTL elapsed")
+	}
+}
+
+func TestNegativeCacheEvictionUnderLoad(t *testing.T) {
+	c := newNegativeCache(20 * time.Millisecond)
+
+	for i := 0; i < 500; i++ {
+		c.Insert(fmt.Sprintf("worker-%d", i))
+	}
+	for i := 0; i < 500; i++ {
+		if !c.Has(fmt.Sprintf("worker-%d", i)) {
+			t.Fatalf("expected worker-%d to be present before its TTL elapsed", i)
+		}
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	for i := 0; i < 500; i++ {
+		if c.Has(fmt.Sprintf("worker-%d", i)) {
+			t.Fatalf("expected worker-%d's entry to have been evicted after its TTL elapsed", i)
+		}
+	}
+}
+
+// TestNegativeCacheInvalidatedOnScaleSetDiscovery covers the transition
+// updateCache is responsible for: a node scaleSet previously classified
+// as availability-set-managed (a common worker-pool scaling workflow is
+// later reprovisioned into a VMSS) must stop being treated as
+// availability-set-managed the moment it's discovered in a scale set,
+// rather than staying misrouted until its negative-cache entry's TTL
+// happens to expire.
+func TestNegativeCacheInvalidatedOnScaleSetDiscovery(t *testing.T) {
+	c := newNegativeCache(time.Minute)
+	c.Insert("worker-1")
+
+	if !c.Has("worker-1") {
+		t.Fatalf("expected worker-1 to be present after Insert")
+	}
+
+	// Simulates what updateCache does once it finds the node in a scale set.
+	c.Delete("worker-1")
+
+	if c.Has("worker-1") {
+		t.Fatalf("expected worker-1's entry to be gone immediately after Delete, not after its TTL")
+	}
+}
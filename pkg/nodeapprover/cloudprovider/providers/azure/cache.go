@@ -0,0 +1,130 @@
+package azure
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider/internal"
+)
+
+// defaultCacheTTL is used when Config.CacheTTLSeconds is unset or
+// non-positive.
+const defaultCacheTTL = 15 * time.Second
+
+// vmCacheEntry is everything a CSR needs out of a VM/VMSS-instance
+// lookup: the instance ID, the instance group (availability set or scale
+// set) it belongs to, and its providerID, populated once per TTL window
+// regardless of how many vmSet methods ask for it.
+type vmCacheEntry struct {
+	InstanceID    string
+	InstanceGroup string
+	ProviderID    string
+
+	err error
+}
+
+// vmInfoCache is the shared (instanceID, instanceGroup, providerID) cache
+// consulted by the vmSet implementations before they call out to ARM. It
+// is modeled on the cluster-autoscaler's azure_cache: a TTL-bounded
+// cache, keyed by node name, that force-refreshes on miss and coalesces
+// concurrent misses for the same node behind a per-entry lock, so a
+// burst of CSRs for the same node produces at most one ARM call.
+type vmInfoCache struct {
+	cache internal.TimedCache
+}
+
+// newVMInfoCache returns a vmInfoCache with the given TTL, or
+// defaultCacheTTL if ttl is zero or negative.
+func newVMInfoCache(ttl time.Duration) *vmInfoCache {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &vmInfoCache{cache: internal.NewTimedCache(ttl)}
+}
+
+// vmCacheRequest is the per-node cache slot: a lock guarding the first
+// populate of entry, so concurrent misses for the same node block on one
+// populate call instead of each making their own ARM request.
+type vmCacheRequest struct {
+	lock  sync.Mutex
+	entry *vmCacheEntry
+}
+
+// get returns nodeName's cached entry, calling populate to fill it in on
+// a cache miss (including a prior miss that errored, so a transient ARM
+// failure doesn't get stuck in the cache for the rest of the TTL
+// window). Concurrent misses for the same nodeName share one populate
+// call.
+func (c *vmInfoCache) get(nodeName string, populate func() (*vmCacheEntry, error)) (*vmCacheEntry, error) {
+	cached, err := c.cache.GetOrCreate(nodeName, func() interface{} {
+		return &vmCacheRequest{}
+	})
+	if err != nil {
+		return nil, err
+	}
+	req := cached.(*vmCacheRequest)
+
+	req.lock.Lock()
+	defer req.lock.Unlock()
+	if req.entry != nil && req.entry.err == nil {
+		return req.entry, nil
+	}
+
+	entry, err := populate()
+	if entry == nil {
+		entry = &vmCacheEntry{}
+	}
+	entry.err = err
+	req.entry = entry
+	return entry, err
+}
+
+// negativeCache is a TTL-bounded record of node names scaleSet has
+// confirmed are not managed by any scale set, consulted by
+// getCachedVirtualMachine so a repeated CSR for an availability-set node
+// doesn't force a full scale set re-list every time. Unlike the plain
+// sets.String it replaces, entries expire after ttl and are explicitly
+// invalidated by updateCache the moment it discovers the node in a scale
+// set -- so a node later reprovisioned into a VMSS (a common worker-pool
+// scaling workflow) stops being misrouted to availabilitySet instead of
+// being stuck there forever.
+type negativeCache struct {
+	cache internal.TimedCache
+}
+
+// newNegativeCache returns a negativeCache with the given TTL, or
+// defaultCacheTTL if ttl is zero or negative.
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &negativeCache{cache: internal.NewTimedCache(ttl)}
+}
+
+// Has reports whether nodeName has an unexpired entry in the cache.
+func (c *negativeCache) Has(nodeName string) bool {
+	_, ok := c.cache.Get(nodeName)
+	return ok
+}
+
+// Insert records nodeName as confirmed not to belong to any scale set,
+// for ttl.
+func (c *negativeCache) Insert(nodeName string) {
+	c.cache.Set(nodeName, struct{}{})
+}
+
+// Delete removes nodeName's entry, if any -- called as soon as
+// updateCache finds the node in a scale set, so it stops being treated
+// as availability-set-managed immediately rather than waiting out the
+// TTL.
+func (c *negativeCache) Delete(nodeName string) {
+	c.cache.Delete(nodeName)
+}
+
+// vmProviderID formats the providerID of the VM named vmName in cloud c's
+// subscription and resource group.
+func vmProviderID(c *Cloud, vmName string) string {
+	return fmt.Sprintf("azure:///subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachines/%s",
+		c.SubscriptionID, c.ResourceGroup, vmName)
+}
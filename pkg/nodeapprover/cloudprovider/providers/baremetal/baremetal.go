@@ -0,0 +1,94 @@
+// Package baremetal implements cloudprovider.Interface by reading a
+// static whitelist of node-name-to-instance-group mappings from a config
+// file, for clusters with no cloud API to query. Operators sync the
+// whitelist from a ConfigMap onto the path passed as --cloud-config, the
+// same way node-csr-approver's other file-backed providers are fed.
+package baremetal
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
+)
+
+// ProviderName is the value used for the --cloud-provider flag.
+const ProviderName = "baremetal"
+
+// Config is the whitelist this provider serves: Groups maps a node name
+// to the instance group it belongs to, e.g. {"master-1": "masters"}.
+type Config struct {
+	Groups map[string]string `yaml:"groups"`
+}
+
+// Cloud is a static, config-file-backed implementation of
+// cloudprovider.Interface.
+type Cloud struct {
+	groups map[string]string
+}
+
+func init() {
+	cloudprovider.RegisterCloudProvider(ProviderName, newCloud)
+}
+
+func newCloud(config io.Reader) (cloudprovider.Interface, error) {
+	if config == nil {
+		return nil, fmt.Errorf("%s cloud provider requires a --cloud-config whitelist file", ProviderName)
+	}
+	contents, err := ioutil.ReadAll(config)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(contents, &cfg); err != nil {
+		return nil, err
+	}
+	return &Cloud{groups: cfg.Groups}, nil
+}
+
+// GetInstanceIDByNodeName returns nodeName itself: bare-metal nodes have
+// no separate cloud instance ID to distinguish them by.
+func (c *Cloud) GetInstanceIDByNodeName(nodeName string) (string, error) {
+	if _, ok := c.groups[nodeName]; !ok {
+		return "", cloudprovider.ErrInstanceNotFound
+	}
+	return nodeName, nil
+}
+
+// GetInstanceGroupByNodeName looks nodeName up in the static whitelist.
+func (c *Cloud) GetInstanceGroupByNodeName(nodeName string) (string, error) {
+	group, ok := c.groups[nodeName]
+	if !ok {
+		return "", cloudprovider.ErrInstanceGroupNotFound
+	}
+	return group, nil
+}
+
+// GetInstanceAddressesByNodeName always errors: bare-metal has no cloud
+// API to ask for a node's addresses, so a kubelet-serving CSR's SAN check
+// must fall back to validating against the Node object instead (see
+// approver.isValidServingNode).
+func (c *Cloud) GetInstanceAddressesByNodeName(nodeName string) ([]net.IP, []string, error) {
+	if _, ok := c.groups[nodeName]; !ok {
+		return nil, nil, cloudprovider.ErrInstanceNotFound
+	}
+	return nil, nil, fmt.Errorf("%s cloud provider does not support address lookups", ProviderName)
+}
+
+// GetInstanceGroupByProviderID accepts a providerID of the form
+// "baremetal:///<nodeName>", as well as a bare node name, since
+// bare-metal deployments aren't guaranteed to set Spec.ProviderID at all.
+func (c *Cloud) GetInstanceGroupByProviderID(providerID string) (string, error) {
+	nodeName := providerID
+	if cloudName, id, err := cloudprovider.ParseProviderID(providerID); err == nil {
+		if cloudName != ProviderName {
+			return "", fmt.Errorf("providerID %q is not a %s instance", providerID, ProviderName)
+		}
+		nodeName = id
+	}
+	return c.GetInstanceGroupByNodeName(nodeName)
+}
@@ -1,59 +1,53 @@
-// Code generated by MockGen. DO NOT EDIT.
-// Source: pkg/cloudprovider/cloud.go
-
-// Package fake is a generated GoMock package.
+// Package fake provides a testify/mock implementation of
+// cloudprovider.Interface for use in approver unit tests, replacing the
+// previous hand-rolled mock so expectations can be set up with the same
+// mock.Mock API used elsewhere in the Go ecosystem.
 package fake
 
 import (
-	gomock "github.com/golang/mock/gomock"
-	reflect "reflect"
+	"net"
+
+	"github.com/stretchr/testify/mock"
 )
 
-// Fake is a mock of Interface interface
+// Fake is a mock.Mock-backed stand-in for cloudprovider.Interface.
 type Fake struct {
-	ctrl     *gomock.Controller
-	recorder *FakeMockRecorder
-}
-
-// FakeMockRecorder is the mock recorder for Fake
-type FakeMockRecorder struct {
-	mock *Fake
-}
-
-// NewFake creates a new mock instance
-func NewFake(ctrl *gomock.Controller) *Fake {
-	mock := &Fake{ctrl: ctrl}
-	mock.recorder = &FakeMockRecorder{mock}
-	return mock
+	mock.Mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
-func (m *Fake) EXPECT() *FakeMockRecorder {
-	return m.recorder
+// NewFake creates a new Fake mock instance.
+func NewFake() *Fake {
+	return &Fake{}
 }
 
-// GetInstanceIDByNodeName mocks base method
-func (m *Fake) GetInstanceIDByNodeName(arg0 string) (string, error) {
-	ret := m.ctrl.Call(m, "GetInstanceIDByNodeName", arg0)
-	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+// GetInstanceIDByNodeName mocks cloudprovider.Interface.GetInstanceIDByNodeName.
+func (f *Fake) GetInstanceIDByNodeName(nodeName string) (string, error) {
+	args := f.Called(nodeName)
+	return args.String(0), args.Error(1)
 }
 
-// GetInstanceIDByNodeName indicates an expected call of GetInstanceIDByNodeName
-func (mr *FakeMockRecorder) GetInstanceIDByNodeName(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceIDByNodeName", reflect.TypeOf((*Fake)(nil).GetInstanceIDByNodeName), arg0)
+// GetInstanceGroupByNodeName mocks cloudprovider.Interface.GetInstanceGroupByNodeName.
+func (f *Fake) GetInstanceGroupByNodeName(nodeName string) (string, error) {
+	args := f.Called(nodeName)
+	return args.String(0), args.Error(1)
 }
 
-// GetInstanceGroupByNodeName mocks base method
-func (m *Fake) GetInstanceGroupByNodeName(arg0 string) (string, error) {
-	ret := m.ctrl.Call(m, "GetInstanceGroupByNodeName", arg0)
-	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+// GetInstanceGroupByProviderID mocks cloudprovider.Interface.GetInstanceGroupByProviderID.
+func (f *Fake) GetInstanceGroupByProviderID(providerID string) (string, error) {
+	args := f.Called(providerID)
+	return args.String(0), args.Error(1)
 }
 
-// GetInstanceGroupByNodeName indicates an expected call of GetInstanceGroupByNodeName
-func (mr *FakeMockRecorder) GetInstanceGroupByNodeName(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceGroupByNodeName", reflect.TypeOf((*Fake)(nil).GetInstanceGroupByNodeName), arg0)
+// GetInstanceAddressesByNodeName mocks cloudprovider.Interface.GetInstanceAddressesByNodeName.
+func (f *Fake) GetInstanceAddressesByNodeName(nodeName string) ([]net.IP, []string, error) {
+	args := f.Called(nodeName)
+	var ips []net.IP
+	if v := args.Get(0); v != nil {
+		ips = v.([]net.IP)
+	}
+	var names []string
+	if v := args.Get(1); v != nil {
+		names = v.([]string)
+	}
+	return ips, names, args.Error(2)
 }
@@ -0,0 +1,65 @@
+package fake
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestFakeGetInstanceIDByNodeName(t *testing.T) {
+	cloud := NewFake()
+	cloud.On("GetInstanceIDByNodeName", "worker-1").Return("i-0123456789abcdef", nil)
+
+	id, err := cloud.GetInstanceIDByNodeName("worker-1")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if id != "i-0123456789abcdef" {
+		t.Errorf("got instance ID %q, want %q", id, "i-0123456789abcdef")
+	}
+	cloud.AssertExpectations(t)
+}
+
+func TestFakeGetInstanceGroupByNodeNameError(t *testing.T) {
+	cloud := NewFake()
+	wantErr := errors.New("instance group not found")
+	cloud.On("GetInstanceGroupByNodeName", "unknown").Return("", wantErr)
+
+	if _, err := cloud.GetInstanceGroupByNodeName("unknown"); err != wantErr {
+		t.Errorf("got err %v, want %v", err, wantErr)
+	}
+	cloud.AssertExpectations(t)
+}
+
+func TestFakeGetInstanceAddressesByNodeName(t *testing.T) {
+	cloud := NewFake()
+	wantIPs := []net.IP{net.ParseIP("10.0.0.5")}
+	wantNames := []string{"worker-1.ec2.internal"}
+	cloud.On("GetInstanceAddressesByNodeName", "worker-1").Return(wantIPs, wantNames, nil)
+
+	ips, names, err := cloud.GetInstanceAddressesByNodeName("worker-1")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(wantIPs[0]) {
+		t.Errorf("got IPs %v, want %v", ips, wantIPs)
+	}
+	if len(names) != 1 || names[0] != wantNames[0] {
+		t.Errorf("got names %v, want %v", names, wantNames)
+	}
+	cloud.AssertExpectations(t)
+}
+
+func TestFakeGetInstanceGroupByProviderID(t *testing.T) {
+	cloud := NewFake()
+	cloud.On("GetInstanceGroupByProviderID", "aws:///us-west-2a/i-0123456789abcdef").Return("asg-worker-1", nil)
+
+	group, err := cloud.GetInstanceGroupByProviderID("aws:///us-west-2a/i-0123456789abcdef")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if group != "asg-worker-1" {
+		t.Errorf("got group %q, want %q", group, "asg-worker-1")
+	}
+	cloud.AssertExpectations(t)
+}
@@ -0,0 +1,213 @@
+// Package gce implements cloudprovider.Interface against the GCE Compute
+// API, resolving a node's Managed Instance Group from its
+// Spec.ProviderID rather than its bare node name -- a GCE providerID
+// (e.g. "gce://project/us-central1-a/instance") already carries the zone
+// a bare node name doesn't.
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	yaml "gopkg.in/yaml.v2"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
+
+	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
+)
+
+// ProviderName is the value used for the --cloud-provider flag.
+const ProviderName = "gce"
+
+// Config optionally overrides the project GCE would otherwise auto-detect
+// from the instance metadata server.
+type Config struct {
+	Project string `yaml:"project"`
+}
+
+// Cloud is an implementation of cloudprovider.Interface backed by the GCE
+// Compute API.
+type Cloud struct {
+	service *compute.Service
+	project string
+}
+
+func init() {
+	cloudprovider.RegisterCloudProvider(ProviderName, newGCECloud)
+}
+
+func newGCECloud(config io.Reader) (cloudprovider.Interface, error) {
+	var cfg Config
+	if config != nil {
+		contents, err := ioutil.ReadAll(config)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(contents, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	project := cfg.Project
+	if project == "" {
+		p, err := metadata.ProjectID()
+		if err != nil {
+			return nil, fmt.Errorf("error getting GCE project from instance metadata: %v", err)
+		}
+		project = p
+	}
+
+	client, err := google.DefaultClient(context.Background(), compute.ComputeReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("error building GCE client: %v", err)
+	}
+	service, err := compute.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("error building GCE compute service: %v", err)
+	}
+
+	return &Cloud{service: service, project: project}, nil
+}
+
+// GetInstanceIDByNodeName returns the GCE instance's numeric ID. A bare
+// node name doesn't say which zone to look in, so every zone is tried in
+// turn; callers that already have a providerID should prefer
+// GetInstanceGroupByProviderID, which knows its zone up front.
+func (c *Cloud) GetInstanceIDByNodeName(nodeName string) (string, error) {
+	zones, err := c.listZones()
+	if err != nil {
+		return "", err
+	}
+	for _, zone := range zones {
+		instance, err := c.service.Instances.Get(c.project, zone, nodeName).Do()
+		if err != nil {
+			continue
+		}
+		return fmt.Sprintf("%d", instance.Id), nil
+	}
+	return "", cloudprovider.ErrInstanceNotFound
+}
+
+// GetInstanceGroupByNodeName mirrors GetInstanceGroupByProviderID but must
+// first discover the instance's zone by trying each of them in turn,
+// since a bare node name doesn't carry one.
+func (c *Cloud) GetInstanceGroupByNodeName(nodeName string) (string, error) {
+	zones, err := c.listZones()
+	if err != nil {
+		return "", err
+	}
+	for _, zone := range zones {
+		group, err := c.instanceGroupForInstance(zone, nodeName)
+		if err == cloudprovider.ErrInstanceNotFound {
+			continue
+		}
+		return group, err
+	}
+	return "", cloudprovider.ErrInstanceNotFound
+}
+
+// GetInstanceGroupByProviderID returns the Managed Instance Group that
+// owns the instance named by providerID, e.g.
+// "gce://my-project/us-central1-a/gke-pool-abcd1234-xyz0".
+func (c *Cloud) GetInstanceGroupByProviderID(providerID string) (string, error) {
+	cloudName, instanceName, err := cloudprovider.ParseProviderID(providerID)
+	if err != nil {
+		return "", err
+	}
+	if cloudName != ProviderName {
+		return "", fmt.Errorf("providerID %q is not a %s instance", providerID, ProviderName)
+	}
+
+	zone, err := zoneFromProviderID(providerID)
+	if err != nil {
+		return "", err
+	}
+	return c.instanceGroupForInstance(zone, instanceName)
+}
+
+// GetInstanceAddressesByNodeName returns the internal and (if assigned)
+// external IPs of nodeName's instance, plus its GCE-internal DNS name
+// (<instance>.c.<project>.internal), trying each zone in turn the same
+// way GetInstanceIDByNodeName does.
+func (c *Cloud) GetInstanceAddressesByNodeName(nodeName string) ([]net.IP, []string, error) {
+	zones, err := c.listZones()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, zone := range zones {
+		instance, err := c.service.Instances.Get(c.project, zone, nodeName).Do()
+		if err != nil {
+			continue
+		}
+
+		var ips []net.IP
+		for _, iface := range instance.NetworkInterfaces {
+			if ip := net.ParseIP(iface.NetworkIP); ip != nil {
+				ips = append(ips, ip)
+			}
+			for _, cfg := range iface.AccessConfigs {
+				if ip := net.ParseIP(cfg.NatIP); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+		}
+
+		names := []string{fmt.Sprintf("%s.c.%s.internal", instance.Name, c.project)}
+		return ips, names, nil
+	}
+	return nil, nil, cloudprovider.ErrInstanceNotFound
+}
+
+// instanceGroupForInstance looks up which Managed Instance Group in zone
+// owns instanceName, via the "created-by" metadata item GCE's instance
+// group manager sets on every instance it creates.
+func (c *Cloud) instanceGroupForInstance(zone, instanceName string) (string, error) {
+	instance, err := c.service.Instances.Get(c.project, zone, instanceName).Do()
+	if err != nil {
+		return "", cloudprovider.ErrInstanceNotFound
+	}
+	if instance.Metadata == nil {
+		return "", cloudprovider.ErrInstanceGroupNotFound
+	}
+	for _, item := range instance.Metadata.Items {
+		if item.Key != "created-by" || item.Value == nil {
+			continue
+		}
+		parts := strings.Split(*item.Value, "/instanceGroupManagers/")
+		if len(parts) == 2 && parts[1] != "" {
+			return parts[1], nil
+		}
+	}
+	return "", cloudprovider.ErrInstanceGroupNotFound
+}
+
+func (c *Cloud) listZones() ([]string, error) {
+	var zones []string
+	list, err := c.service.Zones.List(c.project).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error listing GCE zones: %v", err)
+	}
+	for _, z := range list.Items {
+		zones = append(zones, z.Name)
+	}
+	return zones, nil
+}
+
+// zoneFromProviderID extracts the zone segment of a GCE providerID, e.g.
+// "gce://project/us-central1-a/instance" -> "us-central1-a".
+func zoneFromProviderID(providerID string) (string, error) {
+	parts := strings.SplitN(providerID, "://", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("cloudprovider: malformed providerID %q", providerID)
+	}
+	segments := strings.Split(strings.Trim(parts[1], "/"), "/")
+	if len(segments) < 2 {
+		return "", fmt.Errorf("cloudprovider: providerID %q has no zone segment", providerID)
+	}
+	return segments[len(segments)-2], nil
+}
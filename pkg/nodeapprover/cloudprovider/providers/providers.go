@@ -0,0 +1,12 @@
+// Package providers blank-imports every cloudprovider.Interface
+// implementation so that importing this one package (see
+// cmd/nodeapprover/main.go) registers all of them with the cloudprovider
+// registry.
+package providers
+
+import (
+	_ "github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider/providers/aws"
+	_ "github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider/providers/azure"
+	_ "github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider/providers/baremetal"
+	_ "github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider/providers/gce"
+)
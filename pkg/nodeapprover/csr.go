@@ -7,10 +7,14 @@ import (
 	"strings"
 
 	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+
 	certificates "k8s.io/api/certificates/v1beta1"
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	csrutil "k8s.io/client-go/util/certificate/csr"
+
+	"github.com/coreos/kubecsr/pkg/nodeapprover/identity"
 )
 
 const (
@@ -35,6 +39,7 @@ func (ar *Approver) handle(csr *certificates.CertificateSigningRequest) error {
 
 	glog.V(4).Infof("handle: running recognizers on %s", csr.GetName())
 	csrrs := ar.recognizers()
+	matched := false
 	for _, csrr := range csrrs {
 		rs := csrr.recognizers
 		approved := true
@@ -47,6 +52,7 @@ func (ar *Approver) handle(csr *certificates.CertificateSigningRequest) error {
 		if !approved {
 			continue
 		}
+		matched = true
 
 		glog.V(4).Infof("csr %s was approved! message: %s", csr.GetName(), csrr.successMessage)
 		csr.Status.Conditions = append(csr.Status.Conditions, certificates.CertificateSigningRequestCondition{
@@ -54,13 +60,23 @@ func (ar *Approver) handle(csr *certificates.CertificateSigningRequest) error {
 			Reason:  "AutoApproved",
 			Message: csrr.successMessage,
 		})
-		_, err = ar.kubeClient.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(csr)
+		_, err = ar.csr.UpdateApproval(csr)
 		if err != nil {
+			csrDecisionsTotal.WithLabelValues(csrr.kind, "error").Inc()
 			return fmt.Errorf("error updating approval for csr: %v", err)
 		}
+		csrDecisionsTotal.WithLabelValues(csrr.kind, "approved").Inc()
+		if ar.events != nil {
+			ar.events.Eventf(csr, v1.EventTypeNormal, "AutoApproved", csrr.successMessage)
+		}
 		break
 	}
 
+	if !matched {
+		glog.V(4).Infof("csr %s did not match any recognizer", csr.GetName())
+		csrDecisionsTotal.WithLabelValues("unrecognized", "skipped").Inc()
+	}
+
 	return nil
 }
 
@@ -68,40 +84,125 @@ type recognizerFunc func(csr *certificates.CertificateSigningRequest, x509cr *x5
 type csrRecognizer struct {
 	recognizers    []recognizerFunc
 	successMessage string
+	// kind labels the csrDecisionsTotal metric for CSRs matched by this
+	// recognizer, e.g. "self-client-master".
+	kind string
 }
 
 func (ar *Approver) recognizers() []csrRecognizer {
 	return []csrRecognizer{{
-		recognizers:    []recognizerFunc{isSelfNodeClientCert, isRequestingMaster, ar.isValidMaster},
+		recognizers:    []recognizerFunc{isSelfNodeClientCert, isRequestingMaster, ar.isValidMaster, ar.isAuthorizedSelfNodeClient},
 		successMessage: "node-csr-approver auto approved self client cert for master",
+		kind:           "self-client-master",
 	}, {
-		recognizers:    []recognizerFunc{isSelfNodeClientCert, isRequestingWorker, ar.isValidWorker},
+		recognizers:    []recognizerFunc{isSelfNodeClientCert, isRequestingWorker, ar.isValidWorker, ar.isAuthorizedSelfNodeClient},
 		successMessage: "node-csr-approver auto approved self client cert for worker",
+		kind:           "self-client-worker",
 	}, {
-		recognizers:    []recognizerFunc{isNodeClientCert, isRequestingMaster, ar.isValidMaster},
+		recognizers:    []recognizerFunc{isNodeClientCert, isRequestingMaster, ar.isValidMaster, ar.isValidTokenBinding, ar.isAuthorizedNodeClient},
 		successMessage: "node-csr-approver auto approved client cert for master",
+		kind:           "client-master",
 	}, {
-		recognizers:    []recognizerFunc{isNodeClientCert, isRequestingWorker, ar.isValidWorker},
+		recognizers:    []recognizerFunc{isNodeClientCert, isRequestingWorker, ar.isValidWorker, ar.isValidTokenBinding, ar.isAuthorizedNodeClient},
 		successMessage: "node-csr-approver auto approved client cert for worker",
+		kind:           "client-worker",
+	}, {
+		recognizers:    []recognizerFunc{isNodeServerCert, isRequestingMaster, ar.isValidMaster, ar.isValidServingNode, ar.isAuthorizedNodeServer},
+		successMessage: "node-csr-approver auto approved server cert for master",
+		kind:           "server-master",
+	}, {
+		recognizers:    []recognizerFunc{isNodeServerCert, isRequestingWorker, ar.isValidWorker, ar.isValidServingNode, ar.isAuthorizedNodeServer},
+		successMessage: "node-csr-approver auto approved server cert for worker",
+		kind:           "server-worker",
 	}}
 }
 
+// isAuthorizedSelfNodeClient checks the SubjectAccessReview for a
+// self-requested kubelet client certificate.
+func (ar *Approver) isAuthorizedSelfNodeClient(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
+	return ar.authorizedForSubresource(csr, selfNodeClientSubresource)
+}
+
+// isAuthorizedNodeClient checks the SubjectAccessReview for a
+// bootstrap-credential-requested kubelet client certificate.
+func (ar *Approver) isAuthorizedNodeClient(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
+	return ar.authorizedForSubresource(csr, nodeClientSubresource)
+}
+
+// isAuthorizedNodeServer checks the SubjectAccessReview for a kubelet
+// serving certificate.
+func (ar *Approver) isAuthorizedNodeServer(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
+	return ar.authorizedForSubresource(csr, nodeServerSubresource)
+}
+
 func (ar *Approver) isValidMaster(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
 	nodeName := strings.TrimPrefix(x509cr.Subject.CommonName, "system:node:")
-	asg, err := ar.cloud.GetInstanceGroupByNodeName(nodeName)
+	ok, err := ar.identity.ValidateNode(csr, nodeName, identity.RoleMaster)
 	if err != nil {
+		glog.V(4).Infof("isValidMaster: error validating node %q: %v", nodeName, err)
 		return false
 	}
-	return ar.MasterGroup.Has(asg)
+	return ok
 }
 
 func (ar *Approver) isValidWorker(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
 	nodeName := strings.TrimPrefix(x509cr.Subject.CommonName, "system:node:")
-	asg, err := ar.cloud.GetInstanceGroupByNodeName(nodeName)
+	ok, err := ar.identity.ValidateNode(csr, nodeName, identity.RoleWorker)
+	if err != nil {
+		glog.V(4).Infof("isValidWorker: error validating node %q: %v", nodeName, err)
+		return false
+	}
+	return ok
+}
+
+// bootstrapTokenUsernamePrefix precedes a bootstrap token's ID in the
+// requestor username a CSR created from that token authenticates as.
+const bootstrapTokenUsernamePrefix = "system:bootstrap:"
+
+// tokenIDFromUsername extracts the token ID from a bootstrap token
+// requestor's username, or "" if username isn't in that form.
+func tokenIDFromUsername(username string) string {
+	if !strings.HasPrefix(username, bootstrapTokenUsernamePrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(username, bootstrapTokenUsernamePrefix)
+}
+
+// isValidTokenBinding enforces one-shot, per-node bootstrap tokens when
+// ar.TokenBindings is configured: the CSR's bootstrap token must have
+// been bound -- by TokenBindingController, from an annotation external
+// provisioning tooling sets on the token's Secret -- to the same cloud
+// instance ID the requested node name resolves to. Approvers that leave
+// TokenBindings nil keep today's behavior, where any token in the right
+// bootstrappers group can request a certificate for any node name.
+func (ar *Approver) isValidTokenBinding(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
+	if ar.TokenBindings == nil {
+		return true
+	}
+
+	tokenID := tokenIDFromUsername(csr.Spec.Username)
+	if tokenID == "" {
+		glog.V(4).Infof("isValidTokenBinding: requestor %q is not a bootstrap token", csr.Spec.Username)
+		return false
+	}
+
+	nodeName := strings.TrimPrefix(x509cr.Subject.CommonName, "system:node:")
+	instanceID, err := ar.cloud.GetInstanceIDByNodeName(nodeName)
 	if err != nil {
+		glog.V(4).Infof("isValidTokenBinding: error fetching instance ID for %q: %v", nodeName, err)
 		return false
 	}
-	return ar.WorkerGroup.Has(asg)
+
+	boundInstanceID, ok := ar.TokenBindings.InstanceIDFor(tokenID)
+	if !ok {
+		glog.V(4).Infof("isValidTokenBinding: token %q has no recorded instance binding", tokenID)
+		return false
+	}
+	if boundInstanceID != instanceID {
+		glog.V(4).Infof("isValidTokenBinding: token %q is bound to instance %q, not %q", tokenID, boundInstanceID, instanceID)
+		return false
+	}
+	return true
 }
 
 func isRequestingMaster(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
@@ -140,6 +241,76 @@ func isSelfNodeClientCert(csr *certificates.CertificateSigningRequest, x509cr *x
 	return true
 }
 
+// isNodeServerCert recognizes a kubelet-serving CSR: a "system:nodes"
+// request for "system:node:<name>" with the serving key usages and at
+// least one DNS or IP SAN for the node to be reached at.
+func isNodeServerCert(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
+	if !reflect.DeepEqual([]string{"system:nodes"}, x509cr.Subject.Organization) {
+		return false
+	}
+	if !hasExactUsages(csr, kubeletServerUsages) {
+		return false
+	}
+	if !strings.HasPrefix(x509cr.Subject.CommonName, "system:node:") {
+		return false
+	}
+	if len(x509cr.DNSNames) == 0 && len(x509cr.IPAddresses) == 0 {
+		return false
+	}
+	return true
+}
+
+// isValidServingNode verifies that every DNS name and IP address the CSR
+// requests a SAN for actually belongs to the instance the requester
+// claims to be, by asking the cloud provider directly rather than
+// trusting the Node object's Status.Addresses -- those are self-reported
+// by the kubelet running on the node, so a compromised or misconfigured
+// node could otherwise claim any address it likes. Falls back to the
+// Node object whenever the cloud provider lookup returns an error (e.g.
+// bare metal, or ErrInstanceNotFound), so non-cloud deployments keep the
+// previous behavior.
+func (ar *Approver) isValidServingNode(csr *certificates.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
+	nodeName := strings.TrimPrefix(x509cr.Subject.CommonName, "system:node:")
+
+	nodeDNSNames := sets.NewString()
+	nodeIPs := sets.NewString()
+
+	ips, names, err := ar.cloud.GetInstanceAddressesByNodeName(nodeName)
+	if err != nil {
+		glog.V(4).Infof("isValidServingNode: error fetching addresses for %q from cloud provider, falling back to the Node object: %v", nodeName, err)
+		node, nerr := ar.nodeLister.Get(nodeName)
+		if nerr != nil {
+			glog.V(4).Infof("isValidServingNode: error fetching node %q: %v", nodeName, nerr)
+			return false
+		}
+		for _, addr := range node.Status.Addresses {
+			switch addr.Type {
+			case v1.NodeHostName, v1.NodeInternalDNS, v1.NodeExternalDNS:
+				nodeDNSNames.Insert(addr.Address)
+			case v1.NodeInternalIP, v1.NodeExternalIP:
+				nodeIPs.Insert(addr.Address)
+			}
+		}
+	} else {
+		nodeDNSNames.Insert(names...)
+		for _, ip := range ips {
+			nodeIPs.Insert(ip.String())
+		}
+	}
+
+	for _, dnsName := range x509cr.DNSNames {
+		if !nodeDNSNames.Has(dnsName) {
+			return false
+		}
+	}
+	for _, ip := range x509cr.IPAddresses {
+		if !nodeIPs.Has(ip.String()) {
+			return false
+		}
+	}
+	return true
+}
+
 func getCertApprovalCondition(status *certificates.CertificateSigningRequestStatus) (approved bool, denied bool) {
 	for _, c := range status.Conditions {
 		if c.Type == certificates.CertificateApproved {
@@ -158,6 +329,12 @@ var kubeletClientUsages = []certificates.KeyUsage{
 	certificates.UsageClientAuth,
 }
 
+var kubeletServerUsages = []certificates.KeyUsage{
+	certificates.UsageKeyEncipherment,
+	certificates.UsageDigitalSignature,
+	certificates.UsageServerAuth,
+}
+
 func hasExactUsages(csr *certificates.CertificateSigningRequest, usages []certificates.KeyUsage) bool {
 	if len(usages) != len(csr.Spec.Usages) {
 		return false
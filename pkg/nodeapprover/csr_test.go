@@ -11,21 +11,66 @@ import (
 	"net"
 	"testing"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
 	fakecloud "github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider/providers/fake"
-	"github.com/golang/mock/gomock"
+	"github.com/coreos/kubecsr/pkg/nodeapprover/identity"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	certificates "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
 	fakeclient "k8s.io/client-go/kubernetes/fake"
 	testclient "k8s.io/client-go/testing"
 	csrutil "k8s.io/client-go/util/certificate/csr"
 )
 
+// newAllowAllFakeClient returns a fake Clientset whose
+// SubjectAccessReviews always report Allowed, so tests can exercise the
+// cloud-provider recognizers without separately stubbing authorization.
+func newAllowAllFakeClient() *fakeclient.Clientset {
+	client := &fakeclient.Clientset{}
+	client.AddReactor("create", "subjectaccessreviews", func(action testclient.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SubjectAccessReview{
+			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: true},
+		}, nil
+	})
+	return client
+}
+
+// newDenyAllFakeClient returns a fake Clientset whose
+// SubjectAccessReviews always report not Allowed, so tests can verify that
+// a CSR which passes the cloud-provider recognizers is still not approved
+// without authorization.
+func newDenyAllFakeClient() *fakeclient.Clientset {
+	client := &fakeclient.Clientset{}
+	client.AddReactor("create", "subjectaccessreviews", func(action testclient.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SubjectAccessReview{
+			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: false},
+		}, nil
+	})
+	return client
+}
+
+// updateActions filters out the SubjectAccessReview lookups the approver
+// now issues, leaving only the CSR approval updates the tests assert on.
+func updateActions(as []testclient.Action) []testclient.Action {
+	var updates []testclient.Action
+	for _, a := range as {
+		if a.GetVerb() == "update" && a.GetResource().Resource == "certificatesigningrequests" {
+			updates = append(updates, a)
+		}
+	}
+	return updates
+}
+
 func TestHandle(t *testing.T) {
 	cases := []struct {
 		cb     func(b *csrBuilder)
+		arCb   func(ar *Approver)
 		verify func(*testing.T, []testclient.Action)
 	}{{
 		cb: func(b *csrBuilder) {
@@ -200,13 +245,43 @@ func TestHandle(t *testing.T) {
 				t.Errorf("got: %v, expected: %v", got, expected)
 			}
 		},
+	}, {
+		cb: func(b *csrBuilder) {
+			b.cn = "system:node:worker-1"
+			b.requestor = "system:bootstrap:abcdef"
+			b.requestorGroups = []string{"system:bootstrappers:worker"}
+		},
+		arCb: func(ar *Approver) {
+			store := NewInMemoryTokenBindingStore()
+			store.Bind("abcdef", "instance-worker-1")
+			ar.TokenBindings = store
+		},
+		verify: func(t *testing.T, as []testclient.Action) {
+			if len(as) != 1 {
+				t.Errorf("expected one call for a token bound to the right instance but got: %#v", as)
+			}
+		},
+	}, {
+		cb: func(b *csrBuilder) {
+			b.cn = "system:node:worker-1"
+			b.requestor = "system:bootstrap:abcdef"
+			b.requestorGroups = []string{"system:bootstrappers:worker"}
+		},
+		arCb: func(ar *Approver) {
+			store := NewInMemoryTokenBindingStore()
+			store.Bind("abcdef", "instance-some-other-node")
+			ar.TokenBindings = store
+		},
+		verify: func(t *testing.T, as []testclient.Action) {
+			if len(as) != 0 {
+				t.Errorf("expected no calls for a token bound to a different instance but got: %#v", as)
+			}
+		},
 	}}
 	for idx, c := range cases {
 		t.Run(fmt.Sprintf("test #%d", idx), func(t *testing.T) {
-			mockCtrl := gomock.NewController(t)
-			defer mockCtrl.Finish()
-			client := &fakeclient.Clientset{}
-			cloud := fakecloud.NewFake(mockCtrl)
+			client := newAllowAllFakeClient()
+			cloud := fakecloud.NewFake()
 
 			nodetogroup := map[string]string{
 				"master-1": "asg-master-1",
@@ -216,14 +291,20 @@ func TestHandle(t *testing.T) {
 				"evil":     "evil-asg",
 			}
 			for k, v := range nodetogroup {
-				cloud.EXPECT().GetInstanceGroupByNodeName(k).Return(v, nil).AnyTimes()
+				cloud.On("GetInstanceGroupByNodeName", k).Return(v, nil)
 			}
+			cloud.On("GetInstanceIDByNodeName", "worker-1").Return("instance-worker-1", nil)
 			ar := &Approver{
 				kubeClient:  client,
 				cloud:       cloud,
 				MasterGroup: sets.NewString("asg-master-1", "asg-master-2"),
 				WorkerGroup: sets.NewString("asg-worker-1", "asg-worker-2"),
 			}
+			ar.csr = &csrV1beta1Client{client: client}
+			ar.identity = identity.NewCloudProviderIdentity(ar.cloud, ar.MasterGroup, ar.WorkerGroup, nil)
+			if c.arCb != nil {
+				c.arCb(ar)
+			}
 
 			b := csrBuilder{
 				cn:        "system:node:foo",
@@ -240,11 +321,52 @@ func TestHandle(t *testing.T) {
 			if err := ar.handle(csr); err != nil {
 				t.Errorf("unexpected err: %v", err)
 			}
-			c.verify(t, client.Actions())
+			c.verify(t, updateActions(client.Actions()))
 		})
 	}
 }
 
+// TestHandleSARDenied verifies that a CSR which would otherwise match a
+// recognizer's cloud-provider/instance-group checks is still not approved
+// if the SubjectAccessReview for it comes back Allowed: false.
+func TestHandleSARDenied(t *testing.T) {
+	client := newDenyAllFakeClient()
+	cloud := fakecloud.NewFake()
+	cloud.On("GetInstanceGroupByNodeName", "master-1").Return("asg-master-1", nil)
+
+	ar := &Approver{
+		kubeClient:  client,
+		cloud:       cloud,
+		MasterGroup: sets.NewString("asg-master-1", "asg-master-2"),
+		WorkerGroup: sets.NewString("asg-worker-1", "asg-worker-2"),
+		SARGroup:    "certificates.k8s.io",
+		SARResource: "certificatesigningrequests",
+		SARVerb:     "create",
+	}
+	ar.csr = &csrV1beta1Client{client: client}
+	ar.identity = identity.NewCloudProviderIdentity(ar.cloud, ar.MasterGroup, ar.WorkerGroup, nil)
+
+	b := csrBuilder{
+		cn:              "system:node:master-1",
+		orgs:            []string{"system:nodes"},
+		requestor:       "system:node:master-1",
+		requestorGroups: []string{"system:bootstrappers:master"},
+		usages: []certificates.KeyUsage{
+			certificates.UsageKeyEncipherment,
+			certificates.UsageDigitalSignature,
+			certificates.UsageClientAuth,
+		},
+	}
+	csr := makeTestCsr(b)
+	if err := ar.handle(csr); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+
+	if as := updateActions(client.Actions()); len(as) != 0 {
+		t.Errorf("expected no approval update when SAR is denied, got: %#v", as)
+	}
+}
+
 func TestHasKubeletUsages(t *testing.T) {
 	cases := []struct {
 		usages   []certificates.KeyUsage
@@ -294,10 +416,8 @@ func TestHasKubeletUsages(t *testing.T) {
 }
 
 func TestRecognizers(t *testing.T) {
-	mockCtrl := gomock.NewController(t)
-	defer mockCtrl.Finish()
-	client := &fakeclient.Clientset{}
-	cloud := fakecloud.NewFake(mockCtrl)
+	client := newAllowAllFakeClient()
+	cloud := fakecloud.NewFake()
 
 	nodetogroup := map[string]string{
 		"master-1": "asg-master-1",
@@ -307,9 +427,9 @@ func TestRecognizers(t *testing.T) {
 		"evil":     "evil-asg",
 	}
 	for k, v := range nodetogroup {
-		cloud.EXPECT().GetInstanceGroupByNodeName(k).Return(v, nil).AnyTimes()
+		cloud.On("GetInstanceGroupByNodeName", k).Return(v, nil)
 	}
-	cloud.EXPECT().GetInstanceGroupByNodeName("unknown").Return("", cloudprovider.ErrInstanceGroupNotFound).AnyTimes()
+	cloud.On("GetInstanceGroupByNodeName", "unknown").Return("", cloudprovider.ErrInstanceGroupNotFound)
 
 	ar := &Approver{
 		kubeClient:  client,
@@ -317,6 +437,7 @@ func TestRecognizers(t *testing.T) {
 		MasterGroup: sets.NewString("asg-master-1", "asg-master-2"),
 		WorkerGroup: sets.NewString("asg-worker-1", "asg-worker-2"),
 	}
+	ar.identity = identity.NewCloudProviderIdentity(ar.cloud, ar.MasterGroup, ar.WorkerGroup, nil)
 
 	cases := []struct {
 		cbs     []func(b *csrBuilder)
@@ -548,3 +669,87 @@ func makeTestCsr(b csrBuilder) *certificates.CertificateSigningRequest {
 		},
 	}
 }
+
+// TestIsValidServingNode covers isValidServingNode's SAN check against
+// the addresses GetInstanceAddressesByNodeName reports for the node,
+// matching and mismatching DNS/IP SAN sets.
+func TestIsValidServingNode(t *testing.T) {
+	cases := []struct {
+		name      string
+		reqDNS    []string
+		reqIPs    []net.IP
+		cloudDNS  []string
+		cloudIPs  []net.IP
+		cloudErr  error
+		wantValid bool
+	}{{
+		name:      "matching DNS and IP SANs",
+		reqDNS:    []string{"worker-1.ec2.internal"},
+		reqIPs:    []net.IP{net.ParseIP("10.0.0.5")},
+		cloudDNS:  []string{"worker-1.ec2.internal"},
+		cloudIPs:  []net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("54.0.0.5")},
+		wantValid: true,
+	}, {
+		name:      "SAN DNS name not reported by the cloud provider",
+		reqDNS:    []string{"evil.example.com"},
+		cloudDNS:  []string{"worker-1.ec2.internal"},
+		wantValid: false,
+	}, {
+		name:      "SAN IP not reported by the cloud provider",
+		reqIPs:    []net.IP{net.ParseIP("10.0.0.99")},
+		cloudIPs:  []net.IP{net.ParseIP("10.0.0.5")},
+		wantValid: false,
+	}, {
+		name:      "cloud provider error falls back to the Node object, which does not exist",
+		reqDNS:    []string{"worker-1.ec2.internal"},
+		cloudErr:  cloudprovider.ErrInstanceNotFound,
+		wantValid: false,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cloud := fakecloud.NewFake()
+			if c.cloudErr != nil {
+				cloud.On("GetInstanceAddressesByNodeName", "worker-1").Return([]net.IP(nil), []string(nil), c.cloudErr)
+			} else {
+				cloud.On("GetInstanceAddressesByNodeName", "worker-1").Return(c.cloudIPs, c.cloudDNS, nil)
+			}
+
+			client := fakeclient.NewSimpleClientset()
+			ar := &Approver{
+				kubeClient: client,
+				cloud:      cloud,
+			}
+			ar.nodeLister = emptyNodeLister{}
+
+			csr := makeTestCsr(csrBuilder{
+				cn:   "system:node:worker-1",
+				dns:  c.reqDNS,
+				ips:  c.reqIPs,
+				orgs: []string{"system:nodes"},
+			})
+			x509cr, err := csrutil.ParseCSR(csr)
+			if err != nil {
+				t.Fatalf("unexpected err parsing csr: %v", err)
+			}
+
+			if got := ar.isValidServingNode(csr, x509cr); got != c.wantValid {
+				t.Errorf("isValidServingNode() = %v, want %v", got, c.wantValid)
+			}
+			cloud.AssertExpectations(t)
+		})
+	}
+}
+
+// emptyNodeLister is a corelisters.NodeLister that never finds a node,
+// used by TestIsValidServingNode to exercise isValidServingNode's
+// cloud-provider-error fallback path without standing up a real informer.
+type emptyNodeLister struct{}
+
+func (emptyNodeLister) List(selector labels.Selector) ([]*v1.Node, error) {
+	return nil, nil
+}
+
+func (emptyNodeLister) Get(name string) (*v1.Node, error) {
+	return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, name)
+}
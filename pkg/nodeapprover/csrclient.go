@@ -0,0 +1,197 @@
+package approver
+
+import (
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificates "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	certificatesv1listers "k8s.io/client-go/listers/certificates/v1"
+	certificatesv1b1listers "k8s.io/client-go/listers/certificates/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/coreos/kubecsr/pkg/util"
+)
+
+// csrClient abstracts the certificates.k8s.io CSR operations Approver needs
+// -- Get, UpdateApproval, and the informer feeding the work queue -- behind
+// whichever of the v1 or v1beta1 API the cluster actually serves (v1beta1
+// was removed in Kubernetes 1.22+). CSRs are handled internally using the
+// v1beta1-shaped type everywhere else in this package; the v1 client
+// converts at this boundary so syncFunc, handle, and the recognizers below
+// don't need to know which API is in play.
+type csrClient interface {
+	Get(name string) (*certificates.CertificateSigningRequest, error)
+	UpdateApproval(csr *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error)
+	AddEventHandler(handler cache.ResourceEventHandlerFuncs)
+	HasSynced() cache.InformerSynced
+}
+
+// newCSRClient picks a v1 or v1beta1-backed csrClient, based on what the
+// API server behind client serves.
+func newCSRClient(client kubernetes.Interface, factory informers.SharedInformerFactory) (csrClient, error) {
+	v1, err := util.KubeServerSupportsCertificatesV1API(client.Discovery())
+	if err != nil {
+		return nil, err
+	}
+
+	if v1 {
+		informer := factory.Certificates().V1().CertificateSigningRequests()
+		return &csrV1Client{
+			client:   client,
+			lister:   informer.Lister(),
+			informer: informer.Informer(),
+		}, nil
+	}
+
+	informer := factory.Certificates().V1beta1().CertificateSigningRequests()
+	return &csrV1beta1Client{
+		client:   client,
+		lister:   informer.Lister(),
+		informer: informer.Informer(),
+	}, nil
+}
+
+// csrV1beta1Client is the original code path, unchanged: it already speaks
+// the v1beta1-shaped type the rest of this package uses.
+type csrV1beta1Client struct {
+	client   kubernetes.Interface
+	lister   certificatesv1b1listers.CertificateSigningRequestLister
+	informer cache.SharedIndexInformer
+}
+
+func (c *csrV1beta1Client) Get(name string) (*certificates.CertificateSigningRequest, error) {
+	return c.lister.Get(name)
+}
+
+func (c *csrV1beta1Client) UpdateApproval(csr *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error) {
+	return c.client.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(csr)
+}
+
+func (c *csrV1beta1Client) AddEventHandler(handler cache.ResourceEventHandlerFuncs) {
+	c.informer.AddEventHandler(handler)
+}
+
+func (c *csrV1beta1Client) HasSynced() cache.InformerSynced {
+	return c.informer.HasSynced
+}
+
+// csrV1Client drives the certificates.k8s.io/v1 API, converting to/from the
+// v1beta1-shaped type at every call so the rest of Approver is unaffected.
+type csrV1Client struct {
+	client   kubernetes.Interface
+	lister   certificatesv1listers.CertificateSigningRequestLister
+	informer cache.SharedIndexInformer
+}
+
+func (c *csrV1Client) Get(name string) (*certificates.CertificateSigningRequest, error) {
+	v1csr, err := c.lister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return csrFromV1(v1csr), nil
+}
+
+func (c *csrV1Client) UpdateApproval(csr *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error) {
+	v1csr, err := c.client.CertificatesV1().CertificateSigningRequests().Get(csr.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	v1csr.Status.Conditions = csrConditionsToV1(csr.Status.Conditions)
+
+	updated, err := c.client.CertificatesV1().CertificateSigningRequests().UpdateApproval(v1csr.Name, v1csr, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return csrFromV1(updated), nil
+}
+
+func (c *csrV1Client) AddEventHandler(handler cache.ResourceEventHandlerFuncs) {
+	c.informer.AddEventHandler(handler)
+}
+
+func (c *csrV1Client) HasSynced() cache.InformerSynced {
+	return c.informer.HasSynced
+}
+
+// csrFromV1 converts a v1 CertificateSigningRequest into the v1beta1-shaped
+// type the rest of this package operates on. SignerName has no v1beta1
+// equivalent field in use here and is dropped; nothing in this package reads
+// it back off a CSR it already fetched.
+func csrFromV1(in *certificatesv1.CertificateSigningRequest) *certificates.CertificateSigningRequest {
+	out := &certificates.CertificateSigningRequest{
+		ObjectMeta: in.ObjectMeta,
+		Spec: certificates.CertificateSigningRequestSpec{
+			Request:  in.Spec.Request,
+			Usages:   csrUsagesFromV1(in.Spec.Usages),
+			Username: in.Spec.Username,
+			UID:      in.Spec.UID,
+			Groups:   in.Spec.Groups,
+			Extra:    csrExtraFromV1(in.Spec.Extra),
+		},
+		Status: certificates.CertificateSigningRequestStatus{
+			Certificate: in.Status.Certificate,
+			Conditions:  csrConditionsFromV1(in.Status.Conditions),
+		},
+	}
+	return out
+}
+
+func csrUsagesFromV1(in []certificatesv1.KeyUsage) []certificates.KeyUsage {
+	if in == nil {
+		return nil
+	}
+	out := make([]certificates.KeyUsage, len(in))
+	for i, u := range in {
+		out[i] = certificates.KeyUsage(u)
+	}
+	return out
+}
+
+func csrExtraFromV1(in map[string]certificatesv1.ExtraValue) map[string]certificates.ExtraValue {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]certificates.ExtraValue, len(in))
+	for k, v := range in {
+		out[k] = certificates.ExtraValue(v)
+	}
+	return out
+}
+
+func csrConditionsFromV1(in []certificatesv1.CertificateSigningRequestCondition) []certificates.CertificateSigningRequestCondition {
+	if in == nil {
+		return nil
+	}
+	out := make([]certificates.CertificateSigningRequestCondition, len(in))
+	for i, c := range in {
+		out[i] = certificates.CertificateSigningRequestCondition{
+			Type:    certificates.RequestConditionType(c.Type),
+			Reason:  c.Reason,
+			Message: c.Message,
+		}
+	}
+	return out
+}
+
+func csrConditionsToV1(in []certificates.CertificateSigningRequestCondition) []certificatesv1.CertificateSigningRequestCondition {
+	if in == nil {
+		return nil
+	}
+	out := make([]certificatesv1.CertificateSigningRequestCondition, len(in))
+	for i, c := range in {
+		out[i] = certificatesv1.CertificateSigningRequestCondition{
+			Type:    certificatesv1.RequestConditionType(c.Type),
+			Reason:  c.Reason,
+			Message: c.Message,
+			// v1 requires Status on every condition; v1beta1 has no
+			// equivalent field, so ar.handle only ever appends Approved
+			// conditions here, which are always True.
+			Status: corev1.ConditionTrue,
+		}
+	}
+	return out
+}
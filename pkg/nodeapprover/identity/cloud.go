@@ -0,0 +1,80 @@
+package identity
+
+import (
+	"strings"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/coreos/kubecsr/pkg/nodeapprover/cloudprovider"
+)
+
+// cloudAdapter is an IdentityProvider backed by a cloudprovider.Interface,
+// the lookup node-csr-approver has always used. Unlike the other
+// providers it is not looked up through the registry: it wraps the cloud
+// provider and instance-group whitelists the Approver already built for
+// itself, rather than a standalone config file.
+type cloudAdapter struct {
+	cloud       cloudprovider.Interface
+	masterGroup sets.String
+	workerGroup sets.String
+
+	// cloudPrefixes maps a node-name prefix (e.g. "ip-" for AWS, "gke-"
+	// for GCP) to the name of the single cloud, within a
+	// cloudprovider.MultiCloud, that owns nodes with that prefix. When
+	// cloud is a MultiCloud and nodeName matches a configured prefix, only
+	// that cloud is consulted instead of fanning out to all of them,
+	// avoiding cross-cloud false positives in federated deployments. A nil
+	// or non-matching map falls back to querying cloud as given.
+	cloudPrefixes map[string]string
+}
+
+// NewCloudProviderIdentity adapts cloud into an IdentityProvider, treating
+// a node as belonging to role if its instance group is in masterGroup or
+// workerGroup as appropriate. cloudPrefixes may be nil.
+func NewCloudProviderIdentity(cloud cloudprovider.Interface, masterGroup, workerGroup sets.String, cloudPrefixes map[string]string) IdentityProvider {
+	return &cloudAdapter{cloud: cloud, masterGroup: masterGroup, workerGroup: workerGroup, cloudPrefixes: cloudPrefixes}
+}
+
+// ValidateNode resolves nodeName's instance group via
+// GetInstanceGroupByNodeName rather than GetInstanceGroupByProviderID: a
+// kubelet's bootstrap CSR is what creates its Node object in the first
+// place, so no Spec.ProviderID exists yet to resolve through at this
+// point. Approver.setupWhiteLists (pkg/nodeapprover/approver.go) uses the
+// ProviderID-based lookup instead, since it only ever runs against nodes
+// that are already registered.
+func (c *cloudAdapter) ValidateNode(csr *certificates.CertificateSigningRequest, nodeName string, role Role) (bool, error) {
+	asg, err := c.cloudForNode(nodeName).GetInstanceGroupByNodeName(nodeName)
+	if err != nil {
+		return false, err
+	}
+
+	switch role {
+	case RoleMaster:
+		return c.masterGroup.Has(asg), nil
+	case RoleWorker:
+		return c.workerGroup.Has(asg), nil
+	default:
+		return false, nil
+	}
+}
+
+// cloudForNode picks the single cloud nodeName's prefix is pinned to, if
+// cloud is a MultiCloud and a matching prefix is configured, falling back
+// to cloud itself (which fans out on its own if it is a MultiCloud).
+func (c *cloudAdapter) cloudForNode(nodeName string) cloudprovider.Interface {
+	multi, ok := c.cloud.(cloudprovider.MultiCloud)
+	if !ok {
+		return c.cloud
+	}
+
+	for prefix, cloudName := range c.cloudPrefixes {
+		if !strings.HasPrefix(nodeName, prefix) {
+			continue
+		}
+		if cloud, found := multi.Named(cloudName); found {
+			return cloud
+		}
+	}
+	return c.cloud
+}
@@ -0,0 +1,114 @@
+package identity
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+
+	"github.com/golang/glog"
+)
+
+// FileProviderName is the name FileProvider registers itself under.
+const FileProviderName = "file"
+
+// fileEntry is one row of the static identity file: the node name, the
+// role it is allowed to request certificates for, and the fingerprint of
+// the key it is expected to present (currently informational; nothing in
+// this package verifies it yet).
+type fileEntry struct {
+	name        string
+	role        Role
+	fingerprint string
+}
+
+// FileProvider is an IdentityProvider backed by a CSV file of the form
+// "name,role,fingerprint", one node per line. The file is reloaded
+// whenever the process receives SIGHUP, so operators can add or remove
+// nodes without restarting node-csr-approver.
+type FileProvider struct {
+	path string
+
+	mu      sync.RWMutex
+	entries map[string]fileEntry
+}
+
+func init() {
+	RegisterIdentityProvider(FileProviderName, func(configFilePath string) (IdentityProvider, error) {
+		if configFilePath == "" {
+			return nil, fmt.Errorf("identity/file: a config file path is required")
+		}
+		return NewFileProvider(configFilePath)
+	})
+}
+
+// NewFileProvider loads path and begins watching for SIGHUP to reload it.
+func NewFileProvider(path string) (*FileProvider, error) {
+	fp := &FileProvider{path: path}
+	if err := fp.reload(); err != nil {
+		return nil, err
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			glog.V(2).Infof("identity/file: reloading %s on SIGHUP", fp.path)
+			if err := fp.reload(); err != nil {
+				glog.Errorf("identity/file: error reloading %s: %v", fp.path, err)
+			}
+		}
+	}()
+
+	return fp, nil
+}
+
+func (fp *FileProvider) reload() error {
+	f, err := os.Open(fp.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, err := parseEntries(f)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", fp.path, err)
+	}
+
+	fp.mu.Lock()
+	fp.entries = entries
+	fp.mu.Unlock()
+	return nil
+}
+
+func parseEntries(r io.Reader) (map[string]fileEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]fileEntry, len(records))
+	for _, rec := range records {
+		name, role, fingerprint := rec[0], Role(rec[1]), rec[2]
+		entries[name] = fileEntry{name: name, role: role, fingerprint: fingerprint}
+	}
+	return entries, nil
+}
+
+func (fp *FileProvider) ValidateNode(csr *certificates.CertificateSigningRequest, nodeName string, role Role) (bool, error) {
+	fp.mu.RLock()
+	defer fp.mu.RUnlock()
+
+	entry, found := fp.entries[nodeName]
+	if !found {
+		return false, nil
+	}
+	return entry.role == role, nil
+}
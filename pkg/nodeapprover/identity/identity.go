@@ -0,0 +1,89 @@
+// Package identity provides pluggable verification of which node a CSR
+// requester is allowed to act as, as an alternative (or supplement) to
+// asking a cloudprovider.Interface. This lets bare-metal and hybrid
+// clusters, which have no cloud API to query, still auto-approve node
+// CSRs by consulting a static file, LDAP, or an OIDC bootstrap token
+// instead.
+package identity
+
+import (
+	"fmt"
+	"sync"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// Role is the node role a CSR is being validated against.
+type Role string
+
+const (
+	RoleMaster Role = "master"
+	RoleWorker Role = "worker"
+)
+
+// IdentityProvider reports whether csr's requester is a legitimate member
+// of role for the node named nodeName. csr is passed alongside nodeName
+// and role so that providers which need more than the node name -- an
+// LDAP lookup keyed on the requesting username, or an OIDC verifier keyed
+// on a bootstrap JWT in csr.Spec.Extra -- have what they need, mirroring
+// the (csr, x509cr) shape recognizerFunc already uses elsewhere in this
+// package.
+type IdentityProvider interface {
+	ValidateNode(csr *certificates.CertificateSigningRequest, nodeName string, role Role) (bool, error)
+}
+
+// Factory constructs an IdentityProvider from its configuration. What
+// configFilePath names is provider-specific: a CSV file for "file", an
+// LDAP config for "ldap", an OIDC issuer config for "oidc".
+type Factory func(configFilePath string) (IdentityProvider, error)
+
+var (
+	providersMutex sync.Mutex
+	providers      = map[string]Factory{}
+)
+
+// RegisterIdentityProvider registers a Factory under name, for later
+// lookup by InitIdentityProvider. Providers register themselves from an
+// init function, the same way cloudprovider implementations do.
+func RegisterIdentityProvider(name string, factory Factory) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	if _, found := providers[name]; found {
+		panic(fmt.Sprintf("identity provider %q was registered twice", name))
+	}
+	providers[name] = factory
+}
+
+// InitIdentityProvider looks up the provider registered under name and
+// constructs it from configFilePath.
+func InitIdentityProvider(name string, configFilePath string) (IdentityProvider, error) {
+	providersMutex.Lock()
+	factory, found := providers[name]
+	providersMutex.Unlock()
+	if !found {
+		return nil, fmt.Errorf("unknown identity provider %q", name)
+	}
+
+	return factory(configFilePath)
+}
+
+// Chain evaluates a list of IdentityProviders in order, approving as soon
+// as one of them does. It implements IdentityProvider itself so that a
+// repeatable --identity-provider flag collapses into a single provider
+// the rest of the approver need not know is a chain.
+type Chain []IdentityProvider
+
+func (c Chain) ValidateNode(csr *certificates.CertificateSigningRequest, nodeName string, role Role) (bool, error) {
+	var lastErr error
+	for _, p := range c {
+		ok, err := p.ValidateNode(csr, nodeName, role)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, lastErr
+}
@@ -0,0 +1,97 @@
+package identity
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-ldap/ldap/v3"
+	"gopkg.in/yaml.v2"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// LDAPProviderName is the name LDAPProvider registers itself under.
+const LDAPProviderName = "ldap"
+
+// LDAPConfig describes how to reach the directory and which group
+// contains each role's members.
+type LDAPConfig struct {
+	URL          string `yaml:"url"`
+	BindDN       string `yaml:"bindDN"`
+	BindPassword string `yaml:"bindPassword"`
+	BaseDN       string `yaml:"baseDN"`
+
+	MasterGroupDN string `yaml:"masterGroupDN"`
+	WorkerGroupDN string `yaml:"workerGroupDN"`
+}
+
+// LDAPProvider is an IdentityProvider that approves a node by checking
+// whether csr's requesting user is a member of the LDAP group configured
+// for role.
+type LDAPProvider struct {
+	config LDAPConfig
+}
+
+func init() {
+	RegisterIdentityProvider(LDAPProviderName, func(configFilePath string) (IdentityProvider, error) {
+		if configFilePath == "" {
+			return nil, fmt.Errorf("identity/ldap: a config file path is required")
+		}
+		b, err := ioutil.ReadFile(configFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("identity/ldap: error reading config %q: %v", configFilePath, err)
+		}
+		var config LDAPConfig
+		if err := yaml.Unmarshal(b, &config); err != nil {
+			return nil, fmt.Errorf("identity/ldap: error parsing config %q: %v", configFilePath, err)
+		}
+		return &LDAPProvider{config: config}, nil
+	})
+}
+
+// ValidateNode ignores nodeName and instead checks whether csr.Spec.Username
+// is a member of role's configured group -- LDAP has no notion of which
+// node a user administers, only which groups they belong to.
+func (p *LDAPProvider) ValidateNode(csr *certificates.CertificateSigningRequest, nodeName string, role Role) (bool, error) {
+	groupDN, err := p.groupDNForRole(role)
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := ldap.DialURL(p.config.URL, ldap.DialWithTLSConfig(&tls.Config{}))
+	if err != nil {
+		return false, fmt.Errorf("identity/ldap: error connecting to %s: %v", p.config.URL, err)
+	}
+	defer conn.Close()
+
+	if err := conn.Bind(p.config.BindDN, p.config.BindPassword); err != nil {
+		return false, fmt.Errorf("identity/ldap: error binding as %s: %v", p.config.BindDN, err)
+	}
+
+	req := ldap.NewSearchRequest(
+		p.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(uid=%s)(memberOf=%s))", ldap.EscapeFilter(csr.Spec.Username), groupDN),
+		[]string{"dn"},
+		nil,
+	)
+
+	result, err := conn.Search(req)
+	if err != nil {
+		return false, fmt.Errorf("identity/ldap: error searching for %s: %v", csr.Spec.Username, err)
+	}
+
+	return len(result.Entries) > 0, nil
+}
+
+func (p *LDAPProvider) groupDNForRole(role Role) (string, error) {
+	switch role {
+	case RoleMaster:
+		return p.config.MasterGroupDN, nil
+	case RoleWorker:
+		return p.config.WorkerGroupDN, nil
+	default:
+		return "", fmt.Errorf("identity/ldap: unknown role %q", role)
+	}
+}
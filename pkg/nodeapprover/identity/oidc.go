@@ -0,0 +1,90 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	oidc "github.com/coreos/go-oidc"
+	"gopkg.in/yaml.v2"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// OIDCProviderName is the name OIDCProvider registers itself under.
+const OIDCProviderName = "oidc"
+
+// bootstrapTokenExtraKey is the csr.Spec.Extra key the bootstrap JWT is
+// expected under.
+const bootstrapTokenExtraKey = "authentication.kubernetes.io/bootstrap-token"
+
+// OIDCConfig describes the issuer to verify bootstrap tokens against and
+// which claim carries the node name.
+type OIDCConfig struct {
+	IssuerURL     string `yaml:"issuerURL"`
+	ClientID      string `yaml:"clientID"`
+	NodeNameClaim string `yaml:"nodeNameClaim"`
+	RoleClaim     string `yaml:"roleClaim"`
+}
+
+// OIDCProvider is an IdentityProvider that verifies a bootstrap JWT
+// carried in csr.Spec.Extra against an OIDC issuer, and approves the node
+// named by the token's claims for the role the token's claims grant.
+type OIDCProvider struct {
+	config   OIDCConfig
+	verifier *oidc.IDTokenVerifier
+}
+
+func init() {
+	RegisterIdentityProvider(OIDCProviderName, func(configFilePath string) (IdentityProvider, error) {
+		if configFilePath == "" {
+			return nil, fmt.Errorf("identity/oidc: a config file path is required")
+		}
+		b, err := ioutil.ReadFile(configFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("identity/oidc: error reading config %q: %v", configFilePath, err)
+		}
+		var config OIDCConfig
+		if err := yaml.Unmarshal(b, &config); err != nil {
+			return nil, fmt.Errorf("identity/oidc: error parsing config %q: %v", configFilePath, err)
+		}
+		return newOIDCProvider(config)
+	})
+}
+
+func newOIDCProvider(config OIDCConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(context.Background(), config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("identity/oidc: error discovering issuer %s: %v", config.IssuerURL, err)
+	}
+
+	return &OIDCProvider{
+		config:   config,
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}, nil
+}
+
+// ValidateNode verifies the bootstrap JWT in csr.Spec.Extra, approving
+// only if the token's claims name nodeName for role.
+func (p *OIDCProvider) ValidateNode(csr *certificates.CertificateSigningRequest, nodeName string, role Role) (bool, error) {
+	values, ok := csr.Spec.Extra[bootstrapTokenExtraKey]
+	if !ok || len(values) == 0 {
+		return false, nil
+	}
+
+	idToken, err := p.verifier.Verify(context.Background(), values[0])
+	if err != nil {
+		return false, fmt.Errorf("identity/oidc: error verifying bootstrap token for %s: %v", csr.Spec.Username, err)
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return false, fmt.Errorf("identity/oidc: error decoding bootstrap token claims: %v", err)
+	}
+
+	if claimedNode, _ := claims[p.config.NodeNameClaim].(string); claimedNode != nodeName {
+		return false, nil
+	}
+	claimedRole, _ := claims[p.config.RoleClaim].(string)
+	return Role(claimedRole) == role, nil
+}
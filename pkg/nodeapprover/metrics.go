@@ -0,0 +1,15 @@
+package approver
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// csrDecisionsTotal counts every CSR decision node-csr-approver makes, by
+// the recognizer "kind" that matched (or "unrecognized" if none did) and
+// the outcome ("approved", "error", or "skipped").
+var csrDecisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "node_csr_approver_decisions_total",
+	Help: "Total number of CertificateSigningRequest approval decisions made, by recognizer kind and outcome.",
+}, []string{"kind", "outcome"})
+
+func init() {
+	prometheus.MustRegister(csrDecisionsTotal)
+}
@@ -0,0 +1,91 @@
+package approver
+
+import "sync"
+
+// TokenBindingStore maps a bootstrap token's ID -- the part of its
+// "bootstrap-token-<id>" Secret name, also embedded in the
+// "system:bootstrap:<id>" username a CSR created from that token
+// authenticates as -- to the cloud instance ID it was issued for. It is
+// kept up to date by TokenBindingController, which watches bootstrap
+// token Secrets in kube-system, and is consulted by
+// Approver.isValidTokenBinding so a one-shot, per-node bootstrap token
+// can only be used to request a certificate for the instance it was
+// minted for, instead of any node in the cluster's shared bootstrap
+// group.
+type TokenBindingStore interface {
+	// Bind records that tokenID was issued for instanceID.
+	Bind(tokenID, instanceID string)
+	// Unbind removes tokenID's binding, e.g. once its Secret is deleted.
+	Unbind(tokenID string)
+	// InstanceIDFor returns the instance ID tokenID is bound to, if any.
+	InstanceIDFor(tokenID string) (string, bool)
+}
+
+// InMemoryTokenBindingStore is a TokenBindingStore backed by a plain map,
+// populated entirely by TokenBindingController's watch of bootstrap token
+// Secrets. It does not itself talk to the API server.
+type InMemoryTokenBindingStore struct {
+	mu       sync.RWMutex
+	bindings map[string]string
+}
+
+// NewInMemoryTokenBindingStore returns an empty InMemoryTokenBindingStore.
+func NewInMemoryTokenBindingStore() *InMemoryTokenBindingStore {
+	return &InMemoryTokenBindingStore{bindings: make(map[string]string)}
+}
+
+func (s *InMemoryTokenBindingStore) Bind(tokenID, instanceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bindings[tokenID] = instanceID
+}
+
+func (s *InMemoryTokenBindingStore) Unbind(tokenID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bindings, tokenID)
+}
+
+func (s *InMemoryTokenBindingStore) InstanceIDFor(tokenID string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	instanceID, ok := s.bindings[tokenID]
+	return instanceID, ok
+}
+
+// SecretTokenBindingStore is a TokenBindingStore backed directly by the
+// kube-system Secret informer cache TokenBindingController already
+// maintains, rather than a separately-populated map: Bind/Unbind apply
+// straight to the Secret via secretClient, so the binding survives
+// node-csr-approver restarting, and InstanceIDFor reads the same
+// tokenInstanceIDAnnotation the controller itself watches for.
+type SecretTokenBindingStore struct {
+	secretClient secretClient
+	lister       secretLister
+}
+
+// NewSecretTokenBindingStore returns a SecretTokenBindingStore that reads
+// and writes bootstrap token Secrets in kube-system through client,
+// consulting lister's cache for reads.
+func NewSecretTokenBindingStore(client secretClient, lister secretLister) *SecretTokenBindingStore {
+	return &SecretTokenBindingStore{secretClient: client, lister: lister}
+}
+
+func (s *SecretTokenBindingStore) Bind(tokenID, instanceID string) {
+	if err := s.secretClient.SetAnnotation(bootstrapTokenSecretName(tokenID), tokenInstanceIDAnnotation, instanceID); err != nil {
+		return
+	}
+}
+
+func (s *SecretTokenBindingStore) Unbind(tokenID string) {
+	_ = s.secretClient.RemoveAnnotation(bootstrapTokenSecretName(tokenID), tokenInstanceIDAnnotation)
+}
+
+func (s *SecretTokenBindingStore) InstanceIDFor(tokenID string) (string, bool) {
+	secret, err := s.lister.Get(bootstrapTokenSecretName(tokenID))
+	if err != nil {
+		return "", false
+	}
+	instanceID, ok := secret.Annotations[tokenInstanceIDAnnotation]
+	return instanceID, ok && instanceID != ""
+}
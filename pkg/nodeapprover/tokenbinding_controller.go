@@ -0,0 +1,176 @@
+package approver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	kubeSystemNamespace = "kube-system"
+
+	// bootstrapTokenSecretType is the Secret type kubeadm-style bootstrap
+	// tokens are stored as (bootstrapapi.SecretTypeBootstrapToken
+	// upstream).
+	bootstrapTokenSecretType = corev1.SecretType("bootstrap.kubernetes.io/token")
+
+	// bootstrapTokenSecretPrefix is prepended to a token's ID to form its
+	// Secret name (bootstrapapi.BootstrapTokenSecretPrefix upstream).
+	bootstrapTokenSecretPrefix = "bootstrap-token-"
+
+	// tokenInstanceIDAnnotation is the annotation external provisioning
+	// tooling sets on a bootstrap token's Secret to record which cloud
+	// instance ID the token was minted for. TokenBindingController
+	// watches for it and records the binding in the configured
+	// TokenBindingStore.
+	tokenInstanceIDAnnotation = "kubecsr.coreos.com/bound-instance-id"
+)
+
+// bootstrapTokenSecretName returns the Secret name tokenID's bootstrap
+// token is stored under in kube-system.
+func bootstrapTokenSecretName(tokenID string) string {
+	return bootstrapTokenSecretPrefix + tokenID
+}
+
+// tokenIDFromSecretName extracts the token ID from a
+// "bootstrap-token-<id>" Secret name, or "" if name isn't in that form.
+func tokenIDFromSecretName(name string) string {
+	if !strings.HasPrefix(name, bootstrapTokenSecretPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(name, bootstrapTokenSecretPrefix)
+}
+
+// secretLister is the read side SecretTokenBindingStore needs out of a
+// kube-system Secret informer; a corelisters.SecretNamespaceLister scoped
+// to kube-system (e.g. TokenBindingController.SecretLister()) satisfies
+// this directly.
+type secretLister interface {
+	Get(name string) (*corev1.Secret, error)
+}
+
+// secretClient is the write side SecretTokenBindingStore needs to set or
+// clear tokenInstanceIDAnnotation on a bootstrap token Secret.
+type secretClient interface {
+	SetAnnotation(name, key, value string) error
+	RemoveAnnotation(name, key string) error
+}
+
+type kubeSecretClient struct {
+	client kubernetes.Interface
+}
+
+func (c kubeSecretClient) SetAnnotation(name, key, value string) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, key, value))
+	_, err := c.client.CoreV1().Secrets(kubeSystemNamespace).Patch(name, types.MergePatchType, patch)
+	return err
+}
+
+func (c kubeSecretClient) RemoveAnnotation(name, key string) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:null}}}`, key))
+	_, err := c.client.CoreV1().Secrets(kubeSystemNamespace).Patch(name, types.MergePatchType, patch)
+	return err
+}
+
+// TokenBindingController watches bootstrap token Secrets in kube-system,
+// binds each token in a TokenBindingStore to the instance named by its
+// tokenInstanceIDAnnotation, and unbinds it when the Secret is deleted;
+// removing the annotation from a Secret that still exists leaves its
+// binding in place. External provisioning tooling sets that annotation once it
+// knows which cloud instance a given token was minted for, and
+// Approver.isValidTokenBinding consults the store to reject a CSR whose
+// bootstrap token is being used for any instance other than the one it
+// was bound to.
+type TokenBindingController struct {
+	store    TokenBindingStore
+	lister   corelisters.SecretNamespaceLister
+	informer cache.SharedIndexInformer
+	synced   cache.InformerSynced
+}
+
+// NewTokenBindingController returns a TokenBindingController that keeps
+// store updated from client's view of kube-system Secrets. The caller
+// must call Run to start watching.
+func NewTokenBindingController(client kubernetes.Interface, store TokenBindingStore) *TokenBindingController {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, resyncPeriod, informers.WithNamespace(kubeSystemNamespace))
+	secretInformer := factory.Core().V1().Secrets()
+
+	c := &TokenBindingController{
+		store:    store,
+		lister:   secretInformer.Lister().Secrets(kubeSystemNamespace),
+		informer: secretInformer.Informer(),
+		synced:   secretInformer.Informer().HasSynced,
+	}
+
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.handleSecret,
+		UpdateFunc: func(old, new interface{}) {
+			c.handleSecret(new)
+		},
+		DeleteFunc: c.handleSecretDelete,
+	})
+
+	go factory.Start(wait.NeverStop)
+	return c
+}
+
+func (c *TokenBindingController) handleSecret(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || secret.Type != bootstrapTokenSecretType {
+		return
+	}
+	tokenID := tokenIDFromSecretName(secret.Name)
+	if tokenID == "" {
+		return
+	}
+	instanceID := secret.Annotations[tokenInstanceIDAnnotation]
+	if instanceID == "" {
+		return
+	}
+	glog.V(4).Infof("TokenBindingController: binding token %q to instance %q", tokenID, instanceID)
+	c.store.Bind(tokenID, instanceID)
+}
+
+func (c *TokenBindingController) handleSecretDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	tokenID := tokenIDFromSecretName(secret.Name)
+	if tokenID == "" {
+		return
+	}
+	glog.V(4).Infof("TokenBindingController: unbinding token %q, Secret deleted", tokenID)
+	c.store.Unbind(tokenID)
+}
+
+// SecretLister returns the kube-system Secret lister backing this
+// controller's informer, for building a SecretTokenBindingStore that
+// shares its cache instead of starting a second watch.
+func (c *TokenBindingController) SecretLister() secretLister {
+	return c.lister
+}
+
+// Run blocks until the Secret informer's cache has synced, then blocks
+// until stopCh is closed, mirroring Approver.Run's own
+// cache.WaitForCacheSync usage.
+func (c *TokenBindingController) Run(stopCh <-chan struct{}) error {
+	if !cache.WaitForCacheSync(stopCh, c.synced) {
+		return fmt.Errorf("error timeout waiting for bootstrap token secret cache sync")
+	}
+	<-stopCh
+	return nil
+}
@@ -0,0 +1,14 @@
+package renewer
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	certRotationTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cert_rotation_total",
+		Help: "Total number of certificate rotation attempts by the Renewer's rotation loop, by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(certRotationTotal)
+}
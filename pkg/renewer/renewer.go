@@ -0,0 +1,446 @@
+// Package renewer implements an agent-side certificate rotation client for
+// CertServer's plain HTTP signing API (see pkg/certsigner), for deployments
+// that have no Kubernetes API server to talk to at all -- such as etcd
+// nodes bootstrapping before a cluster exists. pkg/certagent's CertAgent is
+// the analogous client for a real certificates.k8s.io API server (including
+// CertServer fronted by a kubeconfig pointed at it); Renewer exists for
+// callers that only have a signer URL, not a kubeconfig, by driving
+// CertServer's POST/watch routes directly with net/http instead of a
+// generated REST client.
+//
+// Renewer is modeled on client-go's util/certificate Manager: it watches
+// the certificate it last wrote to disk, and at a jittered fraction of the
+// way through its NotBefore..NotAfter lifetime, generates a fresh key and
+// CSR, submits it to the signer, and atomically swaps the renewed cert/key
+// into place.
+package renewer
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	certv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	certutil "k8s.io/client-go/util/cert"
+
+	"github.com/coreos/kubecsr/pkg/certagent"
+	"github.com/coreos/kubecsr/pkg/certcheck"
+)
+
+const (
+	// rotationFractionMin and rotationFractionMax are the default bounds
+	// of the fraction of a certificate's NotBefore..NotAfter lifetime
+	// Start lets elapse before rotating it, matching client-go's
+	// certificate.Manager convention; Config.RotateFractionMin/Max
+	// override them.
+	rotationFractionMin = 0.7
+	rotationFractionMax = 0.9
+
+	// initialRotationBackoff and maxRotationBackoff bound Start's retry
+	// delay after a failed rotation attempt, doubling on each consecutive
+	// failure so a signer outage doesn't turn into a tight retry loop.
+	initialRotationBackoff = 10 * time.Second
+	maxRotationBackoff     = 5 * time.Minute
+
+	// defaultWatchTimeout bounds how long watchCSR waits for the signer
+	// to deliver a signed CSR before giving up, so a signer that never
+	// responds doesn't hang a rotation attempt forever.
+	defaultWatchTimeout = 5 * time.Minute
+
+	csrCollectionPath = "/apis/certificates.k8s.io/v1/certificatesigningrequests"
+)
+
+// ReloadFunc signals a renewed certificate's consumer (e.g. restarting a
+// process or sending it SIGHUP) to pick up the refreshed files. It is the
+// same shape as certagent.ReloadFunc so callers such as
+// cmd/kube-client-agent's buildReloadFunc can be shared between both.
+type ReloadFunc func() error
+
+// Config holds the settings a Renewer needs to request and rotate a
+// certificate against a CertServer's HTTP signing API.
+type Config struct {
+	// ServerURL is the base URL of the CertServer, e.g.
+	// "https://signer.example.com:6443" -- no trailing slash or path.
+	ServerURL string
+	// HTTPClient is used for every request to ServerURL. Configuring TLS
+	// trust (and a client certificate, if the signer requires one) on it
+	// is the caller's responsibility; a nil HTTPClient uses
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// WatchTimeout bounds how long a rotation attempt waits for the
+	// signer to deliver a signed CSR over the watch endpoint. Defaults to
+	// defaultWatchTimeout if zero.
+	WatchTimeout time.Duration
+
+	// CSR holds the certificate request fields -- CommonName, OrgName,
+	// DNSNames, IPAddresses, URIs, AssetsDir, CSRName and SignerName --
+	// the same CSRConfig shape the apiserver-backed certagent uses.
+	// MaxRetry is not used by Renewer; retries are governed by Start's
+	// own backoff instead.
+	CSR certagent.CSRConfig
+
+	// RotateFractionMin and RotateFractionMax bound the fraction of a
+	// certificate's lifetime Start lets elapse before rotating it. Both
+	// default to rotationFractionMin/rotationFractionMax when left zero.
+	RotateFractionMin float64
+	RotateFractionMax float64
+}
+
+// Renewer requests and rotates a single certificate against a CertServer
+// over plain HTTP.
+type Renewer struct {
+	client       *http.Client
+	serverURL    string
+	watchTimeout time.Duration
+	csr          certagent.CSRConfig
+	fracMin      float64
+	fracMax      float64
+}
+
+// New returns an initialized Renewer, or an error if config is missing
+// required fields.
+func New(config Config) (*Renewer, error) {
+	if config.ServerURL == "" {
+		return nil, fmt.Errorf("missing required ServerURL")
+	}
+	if config.CSR.AssetsDir == "" {
+		return nil, fmt.Errorf("missing required CSR.AssetsDir")
+	}
+	if config.CSR.CommonName == "" {
+		return nil, fmt.Errorf("missing required CSR.CommonName")
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	watchTimeout := config.WatchTimeout
+	if watchTimeout == 0 {
+		watchTimeout = defaultWatchTimeout
+	}
+
+	fracMin, fracMax := config.RotateFractionMin, config.RotateFractionMax
+	if fracMin == 0 && fracMax == 0 {
+		fracMin, fracMax = rotationFractionMin, rotationFractionMax
+	}
+
+	return &Renewer{
+		client:       client,
+		serverURL:    strings.TrimRight(config.ServerURL, "/"),
+		watchTimeout: watchTimeout,
+		csr:          config.CSR,
+		fracMin:      fracMin,
+		fracMax:      fracMax,
+	}, nil
+}
+
+// RequestCertificate generates a fresh private key and CSR entirely in
+// memory, submits it to the signer, waits for it to be signed, and
+// atomically writes the resulting certificate and key into AssetsDir.
+// Generating the key in memory rather than writing it to disk immediately
+// (the way certagent.GenerateCSRObject does) means a rotation attempt that
+// fails partway through never clobbers the key a running consumer still has
+// open.
+func (r *Renewer) RequestCertificate() error {
+	keyPEM, csrPEM, err := r.generateKeyAndCSR()
+	if err != nil {
+		return fmt.Errorf("error generating key and CSR: %v", err)
+	}
+
+	csr := &certv1.CertificateSigningRequest{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "certificates.k8s.io/v1", Kind: "CertificateSigningRequest"},
+		ObjectMeta: metav1.ObjectMeta{Name: r.csr.CSRName},
+		Spec: certv1.CertificateSigningRequestSpec{
+			Request: csrPEM,
+		},
+	}
+	if r.csr.SignerName != "" {
+		csr.Spec.SignerName = r.csr.SignerName
+	}
+
+	signed, err := r.postCSR(csr)
+	if err != nil {
+		return fmt.Errorf("error submitting CSR to signer: %v", err)
+	}
+	if signed == nil {
+		// the signer couldn't sign synchronously (e.g. a momentarily
+		// unreachable CA); wait for it via the watch endpoint instead
+		// of busy-retrying the POST.
+		signed, err = r.watchCSR(csr.ObjectMeta.Name)
+		if err != nil {
+			return fmt.Errorf("error waiting for signed certificate: %v", err)
+		}
+	}
+	if len(signed.Status.Certificate) == 0 {
+		return fmt.Errorf("signer returned CSR %s without a certificate", csr.ObjectMeta.Name)
+	}
+
+	if err := r.writeCertKey(signed.Status.Certificate, keyPEM); err != nil {
+		return fmt.Errorf("error writing renewed certificate: %v", err)
+	}
+	return nil
+}
+
+// postCSR submits csr to the signer. CertServer's HandlePostCSR signs
+// synchronously when it can, returning the signed CSR directly in the
+// response body; postCSR returns that. When signing is only recoverable,
+// not done yet (http.StatusAccepted), it returns a nil CSR and nil error so
+// the caller falls back to watchCSR instead of busy-retrying the POST.
+func (r *Renewer) postCSR(csr *certv1.CertificateSigningRequest) (*certv1.CertificateSigningRequest, error) {
+	body, err := json.Marshal(csr)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.serverURL+csrCollectionPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signer response: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusAccepted {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signer returned %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	var signed certv1.CertificateSigningRequest
+	if err := json.Unmarshal(respBody, &signed); err != nil {
+		return nil, fmt.Errorf("error decoding signer response: %v", err)
+	}
+	return &signed, nil
+}
+
+// csrWatchEvent mirrors pkg/certsigner's own csrWatchEvent -- the Type/Object
+// shape of a Kubernetes watch.Event -- which is what HandleWatchCSR streams.
+type csrWatchEvent struct {
+	Type   string                            `json:"type"`
+	Object *certv1.CertificateSigningRequest `json:"object"`
+}
+
+// watchCSR blocks until the signer's watch endpoint delivers the signed CSR
+// named name, or r.watchTimeout elapses.
+func (r *Renewer) watchCSR(name string) (*certv1.CertificateSigningRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.watchTimeout)
+	defer cancel()
+
+	watchURL := fmt.Sprintf("%s%s?watch=true&fieldSelector=%s", r.serverURL, csrCollectionPath, url.QueryEscape("metadata.name="+name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, watchURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("signer returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	var event csrWatchEvent
+	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil {
+		return nil, fmt.Errorf("error decoding watch event: %v", err)
+	}
+	if event.Object == nil {
+		return nil, fmt.Errorf("watch closed without delivering a signed CSR")
+	}
+	return event.Object, nil
+}
+
+// generateKeyAndCSR creates a fresh RSA private key and a PEM-encoded CSR
+// for it, entirely in memory.
+func (r *Renewer) generateKeyAndCSR() (keyPEM, csrPEM []byte, err error) {
+	key, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	uris, err := certagent.ParseURIs(r.csr.URIs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing URI SANs: %v", err)
+	}
+
+	subject := &pkix.Name{
+		Organization: []string{r.csr.OrgName},
+		CommonName:   r.csr.CommonName,
+	}
+	csrPEM, err = certagent.MakeCSR(key, subject, r.csr.DNSNames, r.csr.IPAddresses, uris)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyPEM, csrPEM, nil
+}
+
+// writeCertKey replaces the CommonName.crt and CommonName.key files in
+// AssetsDir with certPEM and keyPEM: both are written to temporary files in
+// the same directory, fsynced, and renamed into place, so neither file is
+// ever seen half-written. The two renames are separate, cert first: a
+// consumer reading between them, or a crash between them, sees the new
+// cert beside the old key.
+func (r *Renewer) writeCertKey(certPEM, keyPEM []byte) error {
+	certFile := path.Join(r.csr.AssetsDir, r.csr.CommonName+".crt")
+	keyFile := path.Join(r.csr.AssetsDir, r.csr.CommonName+".key")
+
+	certTmp, err := writeTempFile(certFile, certPEM, 0644)
+	if err != nil {
+		return err
+	}
+	keyTmp, err := writeTempFile(keyFile, keyPEM, 0600)
+	if err != nil {
+		os.Remove(certTmp)
+		return err
+	}
+
+	if err := os.Rename(certTmp, certFile); err != nil {
+		os.Remove(certTmp)
+		os.Remove(keyTmp)
+		return err
+	}
+	if err := os.Rename(keyTmp, keyFile); err != nil {
+		os.Remove(keyTmp)
+		return err
+	}
+	return nil
+}
+
+// writeTempFile writes data to a temporary file alongside path with perm,
+// fsyncing it before returning its name, so the rename that follows can't
+// land a file whose contents aren't actually on disk yet.
+func writeTempFile(path string, data []byte, perm os.FileMode) (string, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return "", fmt.Errorf("error creating temp file for %s: %v", path, err)
+	}
+	defer tmp.Close()
+
+	if err := tmp.Chmod(perm); err != nil {
+		return "", fmt.Errorf("error setting permissions on temp file for %s: %v", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return "", fmt.Errorf("error writing temp file for %s: %v", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("error syncing temp file for %s: %v", path, err)
+	}
+	return tmp.Name(), nil
+}
+
+// nextRotationDelay returns how long to sleep before rotating the
+// certificate this Renewer last wrote to disk, modeled on client-go's
+// certificate.Manager: rather than renewing a fixed interval before
+// NotAfter, it picks a point between r.fracMin and r.fracMax of the way
+// through the certificate's NotBefore..NotAfter lifetime.
+func (r *Renewer) nextRotationDelay() (time.Duration, error) {
+	certFile := path.Join(r.csr.AssetsDir, r.csr.CommonName+".crt")
+	info, err := certcheck.Inspect(certFile)
+	if err != nil {
+		return 0, err
+	}
+
+	lifetime := info.NotAfter.Sub(info.NotBefore)
+	fraction := r.fracMin + rand.Float64()*(r.fracMax-r.fracMin)
+	rotateAt := info.NotBefore.Add(time.Duration(float64(lifetime) * fraction))
+
+	delay := time.Until(rotateAt)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, nil
+}
+
+// Start requests an initial certificate via RequestCertificate, then runs a
+// rotation loop that wakes up at a jittered fraction of the issued
+// certificate's lifetime (see nextRotationDelay), generates a fresh private
+// key and CSR, and atomically swaps in the newly signed certificate,
+// repeating until ctx is done. reload, if non-nil, runs after every
+// successful rotation but not the initial request, so its consumer (e.g. a
+// configurable post-rotate hook that emits SIGHUP) can pick up the
+// refreshed files without restarting.
+//
+// A failed rotation attempt is retried with exponential backoff, up to
+// maxRotationBackoff, instead of waiting for the next scheduled rotation,
+// so a transient signer outage doesn't leave a certificate un-rotated for
+// an entire lifetime.
+func (r *Renewer) Start(ctx context.Context, reload ReloadFunc) error {
+	if err := r.RequestCertificate(); err != nil {
+		certRotationTotal.WithLabelValues("error").Inc()
+		return fmt.Errorf("error requesting initial certificate: %v", err)
+	}
+	certRotationTotal.WithLabelValues("success").Inc()
+
+	for {
+		delay, err := r.nextRotationDelay()
+		if err != nil {
+			return fmt.Errorf("error scheduling next rotation: %v", err)
+		}
+		glog.Infof("certificate %s rotates in %s", r.csr.CommonName, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		backoff := initialRotationBackoff
+		for {
+			err := r.RequestCertificate()
+			if err == nil {
+				certRotationTotal.WithLabelValues("success").Inc()
+				break
+			}
+			certRotationTotal.WithLabelValues("error").Inc()
+			glog.Errorf("error rotating certificate %s: %v. retrying in %s", r.csr.CommonName, err, backoff)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxRotationBackoff {
+				backoff = maxRotationBackoff
+			}
+		}
+
+		if reload == nil {
+			continue
+		}
+		if err := reload(); err != nil {
+			glog.Errorf("error signaling reload after rotating certificate %s: %v", r.csr.CommonName, err)
+		}
+	}
+}
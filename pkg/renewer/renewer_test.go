@@ -0,0 +1,293 @@
+package renewer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	certv1 "k8s.io/api/certificates/v1"
+
+	"github.com/coreos/kubecsr/pkg/certagent"
+)
+
+// writeTestCert writes a self-signed certificate with the given NotAfter to
+// <dir>/<commonName>.crt and returns its path.
+func writeTestCert(t *testing.T, dir, commonName string, notAfter time.Time) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+
+	certFile := path.Join(dir, commonName+".crt")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+	return certFile
+}
+
+func TestNextRotationDelay(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+
+	commonName := "system:etcd-peer:renewer-rotate-test"
+	notAfter := time.Now().Add(10 * time.Hour)
+	certFile := writeTestCert(t, wd, commonName, notAfter)
+	defer os.Remove(certFile)
+
+	r := &Renewer{
+		csr:     certagent.CSRConfig{AssetsDir: wd, CommonName: commonName},
+		fracMin: rotationFractionMin,
+		fracMax: rotationFractionMax,
+	}
+
+	// writeTestCert sets NotBefore an hour in the past, so the 11h
+	// lifetime's 70-90% mark falls 7.7h-9.9h from NotBefore, i.e.
+	// 6.7h-8.9h from now.
+	delay, err := r.nextRotationDelay()
+	if err != nil {
+		t.Fatalf("nextRotationDelay returned error: %v", err)
+	}
+	if delay < 6*time.Hour || delay > 9*time.Hour {
+		t.Errorf("delay = %s, want a value between 6h and 9h", delay)
+	}
+}
+
+func TestNextRotationDelayPastDue(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+
+	commonName := "system:etcd-peer:renewer-rotate-test-pastdue"
+	notAfter := time.Now().Add(time.Minute)
+	certFile := writeTestCert(t, wd, commonName, notAfter)
+	defer os.Remove(certFile)
+
+	r := &Renewer{
+		csr:     certagent.CSRConfig{AssetsDir: wd, CommonName: commonName},
+		fracMin: rotationFractionMin,
+		fracMax: rotationFractionMax,
+	}
+
+	delay, err := r.nextRotationDelay()
+	if err != nil {
+		t.Fatalf("nextRotationDelay returned error: %v", err)
+	}
+	if delay != 0 {
+		t.Errorf("delay = %s, want 0 for a certificate already past its rotation point", delay)
+	}
+}
+
+func TestWriteCertKeyAtomicSwap(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+	commonName := "system:etcd-peer:renewer-writecertkey-test"
+	r := &Renewer{csr: certagent.CSRConfig{AssetsDir: wd, CommonName: commonName}}
+
+	certFile := path.Join(wd, commonName+".crt")
+	keyFile := path.Join(wd, commonName+".key")
+	defer os.Remove(certFile)
+	defer os.Remove(keyFile)
+
+	if err := ioutil.WriteFile(certFile, []byte("old-cert"), 0644); err != nil {
+		t.Fatalf("error seeding cert file: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, []byte("old-key"), 0600); err != nil {
+		t.Fatalf("error seeding key file: %v", err)
+	}
+
+	if err := r.writeCertKey([]byte("new-cert"), []byte("new-key")); err != nil {
+		t.Fatalf("writeCertKey returned error: %v", err)
+	}
+
+	gotCert, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("error reading cert file: %v", err)
+	}
+	if string(gotCert) != "new-cert" {
+		t.Errorf("cert file = %q, want %q", gotCert, "new-cert")
+	}
+
+	gotKey, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatalf("error reading key file: %v", err)
+	}
+	if string(gotKey) != "new-key" {
+		t.Errorf("key file = %q, want %q", gotKey, "new-key")
+	}
+
+	// no leftover temp files in AssetsDir
+	entries, err := ioutil.ReadDir(wd)
+	if err != nil {
+		t.Fatalf("error reading dir: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "."+commonName+".") && strings.Contains(e.Name(), ".tmp") {
+			t.Errorf("unexpected leftover temp file %s", e.Name())
+		}
+	}
+}
+
+func TestRequestCertificateSyncSign(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+	commonName := "system:etcd-peer:renewer-request-test"
+	certFile := path.Join(wd, commonName+".crt")
+	keyFile := path.Join(wd, commonName+".key")
+	defer os.Remove(certFile)
+	defer os.Remove(keyFile)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		var csr certv1.CertificateSigningRequest
+		if err := json.NewDecoder(req.Body).Decode(&csr); err != nil {
+			t.Fatalf("error decoding request body: %v", err)
+		}
+		if len(csr.Spec.Request) == 0 {
+			t.Fatal("request body had no CSR PEM data")
+		}
+		csr.Status.Certificate = []byte("fake-signed-cert")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(csr)
+	}))
+	defer srv.Close()
+
+	r, err := New(Config{
+		ServerURL: srv.URL,
+		CSR: certagent.CSRConfig{
+			CommonName: commonName,
+			OrgName:    "system:etcd-peers",
+			AssetsDir:  wd,
+			CSRName:    commonName,
+		},
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	if err := r.RequestCertificate(); err != nil {
+		t.Fatalf("RequestCertificate returned error: %v", err)
+	}
+
+	gotCert, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("error reading cert file: %v", err)
+	}
+	if string(gotCert) != "fake-signed-cert" {
+		t.Errorf("cert file = %q, want %q", gotCert, "fake-signed-cert")
+	}
+	if _, err := ioutil.ReadFile(keyFile); err != nil {
+		t.Errorf("error reading key file: %v", err)
+	}
+}
+
+func TestRequestCertificateFallsBackToWatch(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting current directory: %v", err)
+	}
+	commonName := "system:etcd-peer:renewer-watch-test"
+	certFile := path.Join(wd, commonName+".crt")
+	keyFile := path.Join(wd, commonName+".key")
+	defer os.Remove(certFile)
+	defer os.Remove(keyFile)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(csrCollectionPath, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("watch") == "true" {
+			selector := req.URL.Query().Get("fieldSelector")
+			if selector != "metadata.name="+commonName {
+				t.Errorf("unexpected fieldSelector %q", selector)
+			}
+			csr := certv1.CertificateSigningRequest{}
+			csr.ObjectMeta.Name = commonName
+			csr.Status.Certificate = []byte("watched-signed-cert")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"type":   "MODIFIED",
+				"object": csr,
+			})
+			return
+		}
+
+		// the initial POST can't sign synchronously
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	r, err := New(Config{
+		ServerURL: srv.URL,
+		CSR: certagent.CSRConfig{
+			CommonName: commonName,
+			OrgName:    "system:etcd-peers",
+			AssetsDir:  wd,
+			CSRName:    commonName,
+		},
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	if err := r.RequestCertificate(); err != nil {
+		t.Fatalf("RequestCertificate returned error: %v", err)
+	}
+
+	gotCert, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("error reading cert file: %v", err)
+	}
+	if string(gotCert) != "watched-signed-cert" {
+		t.Errorf("cert file = %q, want %q", gotCert, "watched-signed-cert")
+	}
+}
+
+func TestNewValidatesRequiredFields(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config Config
+	}{
+		{"missing ServerURL", Config{CSR: certagent.CSRConfig{AssetsDir: "/tmp", CommonName: "foo"}}},
+		{"missing AssetsDir", Config{ServerURL: "http://example.com", CSR: certagent.CSRConfig{CommonName: "foo"}}},
+		{"missing CommonName", Config{ServerURL: "http://example.com", CSR: certagent.CSRConfig{AssetsDir: "/tmp"}}},
+	} {
+		if _, err := New(tc.config); err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package signer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/ratelimit"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	certificatesv1b1listers "k8s.io/client-go/listers/certificates/v1beta1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const resyncPeriod = 10 * time.Second
+
+// Controller watches for CSRs that an Approver has approved but that don't
+// yet have a certificate, and issues one for each by calling signer.
+type Controller struct {
+	kubeClient kubernetes.Interface
+	signer     Signer
+
+	csrLister certificatesv1b1listers.CertificateSigningRequestLister
+	csrSynced cache.InformerSynced
+	queue     workqueue.RateLimitingInterface
+}
+
+// New returns a new Controller that issues certificates via signer.
+func New(client kubernetes.Interface, signer Signer) (*Controller, error) {
+	c := &Controller{
+		kubeClient: client,
+		signer:     signer,
+		queue: workqueue.NewNamedRateLimitingQueue(workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(200*time.Millisecond, 1000*time.Second),
+			&workqueue.BucketRateLimiter{Bucket: ratelimit.NewBucketWithRate(float64(10), int64(100))},
+		), "node-csr-signer"),
+	}
+
+	sharedInformer := informers.NewSharedInformerFactory(c.kubeClient, resyncPeriod)
+	csrInformer := sharedInformer.Certificates().V1beta1().CertificateSigningRequests()
+	csrInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.enqueueCertificateRequest(obj)
+		},
+		UpdateFunc: func(old, new interface{}) {
+			c.enqueueCertificateRequest(new)
+		},
+	})
+	c.csrLister = csrInformer.Lister()
+	c.csrSynced = csrInformer.Informer().HasSynced
+
+	go sharedInformer.Start(wait.NeverStop)
+	return c, nil
+}
+
+func (c *Controller) enqueueCertificateRequest(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run starts workers workers and blocks until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	glog.Info("Starting node-csr-signer")
+	defer glog.Info("Shutting down node-csr-signer")
+
+	if !cache.WaitForCacheSync(stopCh, c.csrSynced) {
+		return fmt.Errorf("error timeout waiting for caches")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+	<-stopCh
+
+	return nil
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.syncFunc(key.(string)); err != nil {
+		c.queue.AddRateLimited(key)
+		glog.V(4).Infof("Sync %v failed with : %v", key, err)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) syncFunc(key string) error {
+	startTime := time.Now()
+	defer func() {
+		glog.V(4).Infof("Finished syncing certificate request %q (%v)", key, time.Now().Sub(startTime))
+	}()
+
+	csr, err := c.csrLister.Get(key)
+	if apierrors.IsNotFound(err) {
+		glog.V(3).Infof("csr has been deleted: %v", key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if csr.Status.Certificate != nil || !isApproved(csr) {
+		return nil
+	}
+	// need to operate on a copy so we don't mutate the csr in the shared cache
+	csr = csr.DeepCopy()
+
+	cert, err := c.signer.Sign(csr)
+	if err != nil {
+		glog.Errorf("error signing certificate request %q: %v", key, err)
+		return err
+	}
+	csr.Status.Certificate = cert
+
+	_, err = c.kubeClient.CertificatesV1beta1().CertificateSigningRequests().UpdateStatus(csr)
+	return err
+}
+
+func isApproved(csr *certificates.CertificateSigningRequest) bool {
+	for _, c := range csr.Status.Conditions {
+		if c.Type == certificates.CertificateApproved {
+			return true
+		}
+		if c.Type == certificates.CertificateDenied {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package signer
+
+import (
+	"fmt"
+	"testing"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	certificatesv1b1listers "k8s.io/client-go/listers/certificates/v1beta1"
+
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	testclient "k8s.io/client-go/testing"
+)
+
+// fakeSigner is a Signer stub that returns a fixed certificate or error,
+// so syncFunc tests can exercise Controller without a real signer backend.
+type fakeSigner struct {
+	cert []byte
+	err  error
+}
+
+func (s *fakeSigner) Sign(csr *certificates.CertificateSigningRequest) ([]byte, error) {
+	return s.cert, s.err
+}
+
+// newTestController returns a Controller whose csrLister is backed by an
+// in-memory indexer seeded with csrs, and whose kubeClient is a fake
+// Clientset seeded the same way so UpdateStatus calls can be asserted on.
+func newTestController(signer Signer, csrs ...*certificates.CertificateSigningRequest) (*Controller, *fakeclient.Clientset) {
+	objs := make([]runtime.Object, len(csrs))
+	for i, csr := range csrs {
+		objs[i] = csr
+	}
+	client := fakeclient.NewSimpleClientset(objs...)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, csr := range csrs {
+		indexer.Add(csr)
+	}
+
+	c := &Controller{
+		kubeClient: client,
+		signer:     signer,
+		csrLister:  certificatesv1b1listers.NewCertificateSigningRequestLister(indexer),
+	}
+	return c, client
+}
+
+func approvedCSR(name string) *certificates.CertificateSigningRequest {
+	return &certificates.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: certificates.CertificateSigningRequestStatus{
+			Conditions: []certificates.CertificateSigningRequestCondition{
+				{Type: certificates.CertificateApproved},
+			},
+		},
+	}
+}
+
+func updateStatusActions(as []testclient.Action) []testclient.Action {
+	var updates []testclient.Action
+	for _, a := range as {
+		if a.GetVerb() == "update" && a.GetResource().Resource == "certificatesigningrequests" {
+			updates = append(updates, a)
+		}
+	}
+	return updates
+}
+
+func TestSyncFunc(t *testing.T) {
+	cases := []struct {
+		name    string
+		csr     *certificates.CertificateSigningRequest
+		signer  Signer
+		wantErr bool
+		wantSig bool
+	}{{
+		name:    "approved csr gets signed",
+		csr:     approvedCSR("approved"),
+		signer:  &fakeSigner{cert: []byte("fake-cert")},
+		wantSig: true,
+	}, {
+		name: "unapproved csr is left alone",
+		csr: &certificates.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending"},
+		},
+		signer: &fakeSigner{cert: []byte("fake-cert")},
+	}, {
+		name: "denied csr is left alone",
+		csr: &certificates.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "denied"},
+			Status: certificates.CertificateSigningRequestStatus{
+				Conditions: []certificates.CertificateSigningRequestCondition{
+					{Type: certificates.CertificateDenied},
+				},
+			},
+		},
+		signer: &fakeSigner{cert: []byte("fake-cert")},
+	}, {
+		name: "already-signed csr is left alone",
+		csr: &certificates.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "already-signed"},
+			Status: certificates.CertificateSigningRequestStatus{
+				Conditions: []certificates.CertificateSigningRequestCondition{
+					{Type: certificates.CertificateApproved},
+				},
+				Certificate: []byte("existing-cert"),
+			},
+		},
+		signer: &fakeSigner{cert: []byte("fake-cert")},
+	}, {
+		name:    "signer error is returned and nothing is updated",
+		csr:     approvedCSR("sign-error"),
+		signer:  &fakeSigner{err: fmt.Errorf("signer unavailable")},
+		wantErr: true,
+	}, {
+		name:    "missing csr is not an error",
+		csr:     approvedCSR("placeholder"),
+		signer:  &fakeSigner{cert: []byte("fake-cert")},
+		wantErr: false,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl, client := newTestController(c.signer, c.csr)
+
+			key := c.csr.Name
+			if c.name == "missing csr is not an error" {
+				key = "does-not-exist"
+			}
+
+			err := ctrl.syncFunc(key)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("syncFunc() error = %v, wantErr %v", err, c.wantErr)
+			}
+
+			updates := updateStatusActions(client.Actions())
+			if c.wantSig && len(updates) != 1 {
+				t.Fatalf("expected exactly one UpdateStatus call, got %d", len(updates))
+			}
+			if !c.wantSig && len(updates) != 0 {
+				t.Fatalf("expected no UpdateStatus calls, got %d", len(updates))
+			}
+		})
+	}
+}
+
+func TestIsApproved(t *testing.T) {
+	cases := []struct {
+		name       string
+		conditions []certificates.CertificateSigningRequestCondition
+		want       bool
+	}{{
+		name: "approved",
+		conditions: []certificates.CertificateSigningRequestCondition{
+			{Type: certificates.CertificateApproved},
+		},
+		want: true,
+	}, {
+		name: "denied",
+		conditions: []certificates.CertificateSigningRequestCondition{
+			{Type: certificates.CertificateDenied},
+		},
+		want: false,
+	}, {
+		name:       "no conditions",
+		conditions: nil,
+		want:       false,
+	}, {
+		name: "denied takes precedence over a later approved condition",
+		conditions: []certificates.CertificateSigningRequestCondition{
+			{Type: certificates.CertificateDenied},
+			{Type: certificates.CertificateApproved},
+		},
+		want: false,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			csr := &certificates.CertificateSigningRequest{
+				Status: certificates.CertificateSigningRequestStatus{Conditions: c.conditions},
+			}
+			if got := isApproved(csr); got != c.want {
+				t.Errorf("isApproved() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package signer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v2"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+
+	"github.com/coreos/kubecsr/pkg/signer/signerpb"
+)
+
+// GRPCBackendName selects grpcSigner, which delegates signing to an
+// external signer service over gRPC instead of holding a CA key itself.
+const GRPCBackendName = "grpc"
+
+// GRPCConfig is the YAML config file InitSigner(GRPCBackendName, ...)
+// expects.
+type GRPCConfig struct {
+	// Address is the external signer service's "host:port".
+	Address string `yaml:"address"`
+	// CAFile verifies the remote signer service's certificate. If unset,
+	// the host's root CA pool is used.
+	CAFile string `yaml:"caFile,omitempty"`
+	// ClientCertFile and ClientKeyFile authenticate this signer to the
+	// remote service via mTLS. Leave both unset to dial with server-only
+	// TLS verification.
+	ClientCertFile string `yaml:"clientCertFile,omitempty"`
+	ClientKeyFile  string `yaml:"clientKeyFile,omitempty"`
+	// Insecure dials the remote signer service with no transport security
+	// at all. CSRs and signed certificates then travel in the clear;
+	// only set this for local testing.
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+type grpcSigner struct {
+	client signerpb.SignerClient
+}
+
+func init() {
+	RegisterSigner(GRPCBackendName, newGRPCSigner)
+}
+
+func newGRPCSigner(configFilePath string) (Signer, error) {
+	if configFilePath == "" {
+		return nil, fmt.Errorf("grpc signer requires a config file")
+	}
+
+	raw, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading grpc config %q: %v", configFilePath, err)
+	}
+	var c GRPCConfig
+	if err := yaml.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("error parsing grpc config %q: %v", configFilePath, err)
+	}
+	return NewGRPCSigner(c)
+}
+
+// NewGRPCSigner dials the external signer service described by c and
+// returns a Signer that delegates to it.
+func NewGRPCSigner(c GRPCConfig) (Signer, error) {
+	dialOpt, err := grpcDialOption(c)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(c.Address, dialOpt)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing signer service at %q: %v", c.Address, err)
+	}
+	return &grpcSigner{client: signerpb.NewSignerClient(conn)}, nil
+}
+
+// grpcDialOption builds the transport credentials dial option for c.
+// Insecure is the only way to get an unencrypted, unauthenticated
+// connection; every other configuration dials over TLS.
+func grpcDialOption(c GRPCConfig) (grpc.DialOption, error) {
+	if c.Insecure {
+		return grpc.WithInsecure(), nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if c.CAFile != "" {
+		caPEM, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading grpc signer CA file %q: %v", c.CAFile, err)
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in grpc signer CA file %q", c.CAFile)
+		}
+		tlsConfig.RootCAs = caPool
+	}
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading grpc signer client cert/key: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), nil
+}
+
+func (s *grpcSigner) Sign(csr *certificates.CertificateSigningRequest) ([]byte, error) {
+	resp, err := s.client.Sign(context.Background(), &signerpb.SignRequest{Request: csr.Spec.Request})
+	if err != nil {
+		return nil, fmt.Errorf("error signing via remote signer service: %v", err)
+	}
+	return resp.Certificate, nil
+}
@@ -0,0 +1,113 @@
+package signer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/cloudflare/cfssl/config"
+	"github.com/cloudflare/cfssl/helpers"
+	cfsslsigner "github.com/cloudflare/cfssl/signer"
+	"github.com/cloudflare/cfssl/signer/local"
+	"gopkg.in/yaml.v2"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// LocalCABackendName selects localCASigner, which signs with an on-disk CA
+// cert/key pair via cfssl, the same approach pkg/certsigner uses for etcd
+// certs.
+const LocalCABackendName = "local-ca"
+
+// LocalCAConfig is the YAML config file InitSigner(LocalCABackendName, ...)
+// expects. CACertFile and CAKeyFile match the --cacrt/--cakey flags
+// kube-etcd-signer-server's root cmd already exposes.
+type LocalCAConfig struct {
+	CACertFile   string `yaml:"caCertFile"`
+	CAKeyFile    string `yaml:"caKeyFile"`
+	CertDuration string `yaml:"certDuration"`
+}
+
+type localCASigner struct {
+	cfsslSigner *local.Signer
+}
+
+func init() {
+	RegisterSigner(LocalCABackendName, newLocalCASigner)
+}
+
+func newLocalCASigner(configFilePath string) (Signer, error) {
+	if configFilePath == "" {
+		return nil, fmt.Errorf("local-ca signer requires a config file")
+	}
+
+	raw, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading local-ca config %q: %v", configFilePath, err)
+	}
+	var c LocalCAConfig
+	if err := yaml.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("error parsing local-ca config %q: %v", configFilePath, err)
+	}
+
+	return NewLocalCASigner(c)
+}
+
+// NewLocalCASigner builds a Signer that signs with the CA cert/key pair and
+// duration given in c.
+func NewLocalCASigner(c LocalCAConfig) (Signer, error) {
+	certDur := c.CertDuration
+	if certDur == "" {
+		certDur = "8760h"
+	}
+	dur, err := time.ParseDuration(certDur)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certDuration %q: %v", certDur, err)
+	}
+
+	caCertPEM, err := ioutil.ReadFile(c.CACertFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA cert file %q: %v", c.CACertFile, err)
+	}
+	caKeyPEM, err := ioutil.ReadFile(c.CAKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA key file %q: %v", c.CAKeyFile, err)
+	}
+	caCert, err := helpers.ParseCertificatePEM(caCertPEM)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CA cert file %q: %v", c.CACertFile, err)
+	}
+	caKey, err := helpers.ParsePrivateKeyPEM(caKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("malformed CA private key: %v", err)
+	}
+
+	policy := &config.Signing{
+		Default: &config.SigningProfile{
+			Usage: []string{
+				string(certificates.UsageKeyEncipherment),
+				string(certificates.UsageDigitalSignature),
+				string(certificates.UsageClientAuth),
+				string(certificates.UsageServerAuth),
+			},
+			Expiry:       dur,
+			ExpiryString: dur.String(),
+		},
+	}
+
+	cfs, err := local.NewSigner(caKey, caCert, cfsslsigner.DefaultSigAlgo(caKey), policy)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up local cfssl signer: %v", err)
+	}
+	return &localCASigner{cfsslSigner: cfs}, nil
+}
+
+func (s *localCASigner) Sign(csr *certificates.CertificateSigningRequest) ([]byte, error) {
+	cert, err := s.cfsslSigner.Sign(cfsslsigner.SignRequest{
+		Request: string(csr.Spec.Request),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certificate signing error: %v", err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,53 @@
+// Package signer issues certificates for CSRs that an Approver has already
+// approved. It mirrors upstream Kubernetes' split of the certificate
+// controller into an approver and a signer: pkg/nodeapprover only decides
+// Approved/Denied, and Controller here watches for Approved CSRs lacking a
+// certificate and hands them to a pluggable Signer backend.
+package signer
+
+import (
+	"fmt"
+	"sync"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// Signer issues a certificate for an already-approved CSR, returning the
+// PEM-encoded certificate.
+type Signer interface {
+	Sign(csr *certificates.CertificateSigningRequest) ([]byte, error)
+}
+
+// Factory builds a Signer from a backend-specific config file path.
+type Factory func(configFilePath string) (Signer, error)
+
+var (
+	signersMutex sync.Mutex
+	signers      = map[string]Factory{}
+)
+
+// RegisterSigner makes a Signer backend available under name, for later
+// selection via InitSigner. It is meant to be called from a backend's
+// init() function.
+func RegisterSigner(name string, factory Factory) {
+	signersMutex.Lock()
+	defer signersMutex.Unlock()
+
+	if _, found := signers[name]; found {
+		panic(fmt.Sprintf("signer backend %q already registered", name))
+	}
+	signers[name] = factory
+}
+
+// InitSigner constructs the Signer backend registered under name, passing
+// it configFilePath.
+func InitSigner(name string, configFilePath string) (Signer, error) {
+	signersMutex.Lock()
+	factory, found := signers[name]
+	signersMutex.Unlock()
+
+	if !found {
+		return nil, fmt.Errorf("unknown signer backend %q", name)
+	}
+	return factory(configFilePath)
+}
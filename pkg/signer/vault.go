@@ -0,0 +1,116 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/yaml.v2"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+)
+
+// VaultBackendName selects vaultSigner, which signs by delegating to a
+// HashiCorp Vault PKI secrets engine over its HTTP API.
+const VaultBackendName = "vault"
+
+// VaultConfig is the YAML config file InitSigner(VaultBackendName, ...)
+// expects.
+type VaultConfig struct {
+	// Address is the base URL of the Vault server, e.g. "https://vault:8200".
+	Address string `yaml:"address"`
+	// Token authenticates the signer to Vault.
+	Token string `yaml:"token"`
+	// Role selects the PKI role Vault signs the request under, i.e. the
+	// signer posts to "<Address>/v1/pki/sign/<Role>".
+	Role string `yaml:"role"`
+}
+
+type vaultSigner struct {
+	client  *http.Client
+	address string
+	token   string
+	role    string
+}
+
+func init() {
+	RegisterSigner(VaultBackendName, newVaultSigner)
+}
+
+func newVaultSigner(configFilePath string) (Signer, error) {
+	if configFilePath == "" {
+		return nil, fmt.Errorf("vault signer requires a config file")
+	}
+
+	raw, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vault config %q: %v", configFilePath, err)
+	}
+	var c VaultConfig
+	if err := yaml.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("error parsing vault config %q: %v", configFilePath, err)
+	}
+	return NewVaultSigner(c), nil
+}
+
+// NewVaultSigner builds a Signer that delegates signing to the Vault PKI
+// secrets engine described by c.
+func NewVaultSigner(c VaultConfig) Signer {
+	return &vaultSigner{
+		client:  http.DefaultClient,
+		address: c.Address,
+		token:   c.Token,
+		role:    c.Role,
+	}
+}
+
+type vaultSignRequest struct {
+	CSR string `json:"csr"`
+}
+
+type vaultSignResponse struct {
+	Data struct {
+		Certificate string `json:"certificate"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (s *vaultSigner) Sign(csr *certificates.CertificateSigningRequest) ([]byte, error) {
+	body, err := json.Marshal(vaultSignRequest{CSR: string(csr.Spec.Request)})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling vault sign request: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/pki/sign/%s", s.address, s.role)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error building vault sign request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling vault: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vault response: %v", err)
+	}
+
+	var sr vaultSignResponse
+	if err := json.Unmarshal(respBody, &sr); err != nil {
+		return nil, fmt.Errorf("error parsing vault response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault sign request failed with status %d: %v", resp.StatusCode, sr.Errors)
+	}
+	if sr.Data.Certificate == "" {
+		return nil, fmt.Errorf("vault response did not include a certificate")
+	}
+	return []byte(sr.Data.Certificate), nil
+}
@@ -0,0 +1,155 @@
+// Package timedcache provides a TTL-bounded key/value cache with
+// generation counters, per-key TTL override, and prefix-based
+// invalidation. It started out as
+// pkg/nodeapprover/cloudprovider/internal.TimedCache (a thin wrapper
+// around client-go's cache.TTLStore used to coalesce cloud API lookups)
+// and was promoted here, with more features, so other packages --
+// notably pkg/certsigner, which has no reason to depend on
+// nodeapprover/cloudprovider -- can reuse it without that import.
+package timedcache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// entry is a single cached value, along with the generation it was
+// written in and when it expires.
+type entry struct {
+	value      interface{}
+	expiresAt  time.Time
+	generation uint64
+}
+
+// Cache is a TTL-bounded key/value cache safe for concurrent use.
+// Entries expire independently, each according to either the Cache's
+// defaultTTL or a per-key override passed to SetWithTTL/GetOrCreate.
+// Every write bumps a monotonically increasing generation counter,
+// letting callers detect "has anything changed since I last looked"
+// without diffing the cache contents themselves.
+type Cache struct {
+	mu         sync.Mutex
+	entries    map[string]*entry
+	defaultTTL time.Duration
+	generation uint64
+}
+
+// New returns an empty Cache whose entries expire after defaultTTL
+// unless a call overrides it with its own TTL.
+func New(defaultTTL time.Duration) *Cache {
+	return &Cache{
+		entries:    map[string]*entry{},
+		defaultTTL: defaultTTL,
+	}
+}
+
+// Generation returns the number of writes (Set/SetWithTTL, a successful
+// GetOrCreate create, a Delete of a present key, or a prefix match in
+// Invalidate) the Cache has
+// seen so far.
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Get returns key's cached value, if present and not yet expired. Unlike
+// GetOrCreate, a miss is simply reported rather than populated.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getLocked(key)
+}
+
+func (c *Cache) getLocked(key string) (interface{}, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set inserts or overwrites key's cached value, resetting its TTL to the
+// Cache's defaultTTL.
+func (c *Cache) Set(key string, value interface{}) {
+	c.SetWithTTL(key, value, c.defaultTTL)
+}
+
+// SetWithTTL inserts or overwrites key's cached value with a TTL other
+// than the Cache's default, e.g. a shorter TTL for a profile that's
+// expected to rotate faster.
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries[key] = &entry{value: value, expiresAt: time.Now().Add(ttl), generation: c.generation}
+}
+
+// GetOrCreate returns key's cached value if present and unexpired.
+// Otherwise it calls createFunc, which returns the value to cache along
+// with the TTL to cache it for (0 means the Cache's defaultTTL) and an
+// error. If createFunc returns a non-nil error, nothing is cached and
+// the error is returned as-is, so a transient failure isn't stuck in
+// the cache for the rest of the TTL window.
+//
+// Every key shares one lock across the whole cache, so concurrent
+// GetOrCreate calls for the same key never run createFunc more than
+// once: the second caller blocks until the first either populates the
+// entry or fails, rather than both racing to do the (often expensive)
+// work createFunc performs.
+func (c *Cache) GetOrCreate(key string, createFunc func() (value interface{}, ttl time.Duration, err error)) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if value, ok := c.getLocked(key); ok {
+		return value, nil
+	}
+
+	if createFunc == nil {
+		return nil, nil
+	}
+	value, ttl, err := createFunc()
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = c.defaultTTL
+	}
+	c.generation++
+	c.entries[key] = &entry{value: value, expiresAt: time.Now().Add(ttl), generation: c.generation}
+	return value, nil
+}
+
+// Delete removes key's entry, if any.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.generation++
+	}
+}
+
+// Invalidate deletes every cached key with the given prefix and returns
+// how many entries were removed. An empty prefix matches every key.
+func (c *Cache) Invalidate(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		c.generation++
+	}
+	return removed
+}
@@ -0,0 +1,186 @@
+package timedcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrCreate(t *testing.T) {
+	c := New(time.Minute)
+
+	var calls int32
+	createFunc := func() (interface{}, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", 0, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := c.GetOrCreate("key", createFunc)
+		if err != nil {
+			t.Fatalf("GetOrCreate() error = %v", err)
+		}
+		if v != "value" {
+			t.Errorf("GetOrCreate() = %v, want %q", v, "value")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("createFunc called %d times, want 1", calls)
+	}
+}
+
+func TestGetOrCreateDoesNotCacheErrors(t *testing.T) {
+	c := New(time.Minute)
+
+	var calls int32
+	createFunc := func() (interface{}, time.Duration, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			return nil, 0, errBoom
+		}
+		return "value", 0, nil
+	}
+
+	if _, err := c.GetOrCreate("key", createFunc); err != errBoom {
+		t.Fatalf("GetOrCreate() error = %v, want errBoom", err)
+	}
+	v, err := c.GetOrCreate("key", createFunc)
+	if err != nil {
+		t.Fatalf("GetOrCreate() error = %v", err)
+	}
+	if v != "value" {
+		t.Errorf("GetOrCreate() = %v, want %q", v, "value")
+	}
+	if calls != 2 {
+		t.Errorf("createFunc called %d times, want 2", calls)
+	}
+}
+
+func TestGetOrCreateSingleFlight(t *testing.T) {
+	c := New(time.Minute)
+
+	const n = 50
+	var calls int32
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			c.GetOrCreate("key", func() (interface{}, time.Duration, error) {
+				atomic.AddInt32(&calls, 1)
+				return "value", 0, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("a burst of %d identical GetOrCreate calls ran createFunc %d times, want 1", n, calls)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New(10 * time.Millisecond)
+	c.Set("key", "value")
+
+	if _, ok := c.Get("key"); !ok {
+		t.Fatal("Get() immediately after Set() = false, want true")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("key"); ok {
+		t.Error("Get() after TTL expiry = true, want false")
+	}
+}
+
+func TestSetWithTTLOverride(t *testing.T) {
+	c := New(time.Hour)
+	c.SetWithTTL("short", "value", 10*time.Millisecond)
+	c.Set("long", "value")
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get("short"); ok {
+		t.Error("Get(\"short\") after its override TTL expired = true, want false")
+	}
+	if _, ok := c.Get("long"); !ok {
+		t.Error("Get(\"long\") before the default TTL expired = false, want true")
+	}
+}
+
+func TestInvalidatePrefix(t *testing.T) {
+	c := New(time.Minute)
+	c.Set("node/a", 1)
+	c.Set("node/b", 2)
+	c.Set("csr/a", 3)
+
+	removed := c.Invalidate("node/")
+	if removed != 2 {
+		t.Errorf("Invalidate(\"node/\") removed %d entries, want 2", removed)
+	}
+	if _, ok := c.Get("node/a"); ok {
+		t.Error("node/a still present after Invalidate(\"node/\")")
+	}
+	if _, ok := c.Get("csr/a"); !ok {
+		t.Error("csr/a removed by Invalidate(\"node/\"), want it left alone")
+	}
+}
+
+func TestGenerationIncrementsOnWrite(t *testing.T) {
+	c := New(time.Minute)
+	g0 := c.Generation()
+
+	c.Set("key", "value")
+	g1 := c.Generation()
+	if g1 <= g0 {
+		t.Errorf("Generation() after Set() = %d, want > %d", g1, g0)
+	}
+
+	// A Get-only hit shouldn't bump the generation.
+	c.Get("key")
+	if g2 := c.Generation(); g2 != g1 {
+		t.Errorf("Generation() after a cache hit = %d, want unchanged %d", g2, g1)
+	}
+
+	c.Invalidate("key")
+	if g3 := c.Generation(); g3 <= g1 {
+		t.Errorf("Generation() after Invalidate() = %d, want > %d", g3, g1)
+	}
+}
+
+var errBoom = &testError{"boom"}
+
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }
+
+func BenchmarkGetOrCreateCacheHit(b *testing.B) {
+	c := New(time.Minute)
+	createFunc := func() (interface{}, time.Duration, error) { return "value", 0, nil }
+	c.GetOrCreate("key", createFunc)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.GetOrCreate("key", createFunc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetOrCreateBurstDedup(b *testing.B) {
+	createFunc := func() (interface{}, time.Duration, error) { return "value", 0, nil }
+
+	for i := 0; i < b.N; i++ {
+		c := New(time.Minute)
+		var wg sync.WaitGroup
+		wg.Add(32)
+		for j := 0; j < 32; j++ {
+			go func() {
+				defer wg.Done()
+				c.GetOrCreate("key", createFunc)
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -7,11 +7,36 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 
 	capi "k8s.io/api/certificates/v1beta1"
+	"k8s.io/client-go/discovery"
 )
 
+// KubeServerSupportsCertificatesV1API reports whether the API server behind
+// client serves certificates.k8s.io/v1, which replaced v1beta1 (removed in
+// Kubernetes 1.22+). Mirrors the same discovery check Pinniped's CSR code
+// performs before picking which API to drive its CSR controller against.
+func KubeServerSupportsCertificatesV1API(client discovery.ServerGroupsInterface) (bool, error) {
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return false, fmt.Errorf("error discovering server API groups: %v", err)
+	}
+	for _, g := range groups.Groups {
+		if g.Name != "certificates.k8s.io" {
+			continue
+		}
+		for _, v := range g.Versions {
+			if v.Version == "v1" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 // IsCertificateRequestApproved returns true if a certificate request has the
 // "Approved" condition and no "Denied" conditions; false otherwise.
 func IsCertificateRequestApproved(csr *capi.CertificateSigningRequest) bool {
@@ -46,8 +71,33 @@ func GeneratePrivateKey(assetsDir, fileName string) ([]byte, error) {
 	})
 
 	keyFile := path.Join(assetsDir, fileName+".key")
-	if err := ioutil.WriteFile(keyFile, pemKeyBytes, 0600); err != nil {
+	if err := WriteFileAtomic(keyFile, pemKeyBytes, 0600); err != nil {
 		return nil, fmt.Errorf("unable to write to %s: %v", keyFile, err)
 	}
 	return pemKeyBytes, nil
 }
+
+// WriteFileAtomic writes data to path with perm by writing to a temporary
+// file in the same directory and renaming it into place, so a reader of
+// path (e.g. etcd picking up a renewed cert) never observes a partial
+// write.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temp file for %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting permissions on temp file for %s: %v", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing temp file for %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temp file for %s: %v", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}